@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+func init() {
+	RegisterTransactionType("assert_balance", func() Transaction { return AssertBalance{} })
+}
+
+// AssertBalance is a transaction that checks an invariant rather than changing state: it fails
+// with ErrAssertionFailed unless Account holds exactly Expected of its native asset when it
+// runs, and otherwise produces no updates. It is read-only on Account, so it observes every
+// transaction writing Account before it in serial order and none after it, and runs in parallel
+// with transactions that don't touch Account. Such checks are useful to validate ordering in
+// tests or to embed in real blocks, where AbortOnError mode turns a failed one into a stop.
+type AssertBalance struct {
+	Account  string `json:"account"`
+	Expected uint   `json:"expected"`
+}
+
+// ReadOnly marks AssertBalance as a read-only transaction
+func (AssertBalance) ReadOnly() {}
+
+// AccessSet implements AccessAware
+func (a AssertBalance) AccessSet() ([]string, []string) {
+	return []string{a.Account}, nil
+}
+
+// Updates implements Transaction
+func (a AssertBalance) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if balance := state.GetAccount(a.Account).Balance; balance != a.Expected {
+		return nil, fmt.Errorf("%w: account %s has %d, expected %d", ErrAssertionFailed, a.Account, balance, a.Expected)
+	}
+	return nil, nil
+}
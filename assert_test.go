@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAssertBalance_BetweenTransfers(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 100}, {Name: "C", Balance: 5}}
+	block := Block{Transactions: []Transaction{
+		AssertBalance{Account: "B", Expected: 0},
+		transfer{from: "A", to: "B", value: 30},
+		AssertBalance{Account: "B", Expected: 30},
+		AssertBalance{Account: "A", Expected: 70},
+		transfer{from: "B", to: "C", value: 10},
+		AssertBalance{Account: "B", Expected: 30}, // fails, B has 20
+		AssertBalance{Account: "C", Expected: 15},
+	}}
+
+	for run := 0; run < 10; run++ {
+		accounts, result, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 4)
+		if err != nil {
+			t.Fatalf("ExecuteBlock failed: %v", err)
+		}
+		verifyResults(t, accounts, map[string]uint{"A": 70, "B": 20, "C": 15})
+		for i, tx := range result.Transactions {
+			if i == 5 {
+				if !errors.Is(tx.Err, ErrAssertionFailed) {
+					t.Fatalf("Run %d: expected transaction 5 to fail its assertion, got %+v", run, tx)
+				}
+			} else if !tx.Applied {
+				t.Fatalf("Run %d: transaction %d failed: %v", run, i, tx.Err)
+			}
+		}
+	}
+}
+
+func TestAssertBalance_ScheduledAsReadOnly(t *testing.T) {
+	scheduler := NewDependencyScheduler([]Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		AssertBalance{Account: "B", Expected: 30},
+		AssertBalance{Account: "B", Expected: 30},
+		transfer{from: "C", to: "D", value: 10},
+		transfer{from: "B", to: "E", value: 10},
+	})
+
+	// Assertions wait for the write before them but not for each other or unrelated transfers,
+	// and the next write to their account waits for them
+	expected := [][]int{nil, {0}, {0}, nil, {0, 1, 2}}
+	for i, deps := range expected {
+		if got := scheduler.Dependencies(i); !reflect.DeepEqual(got, deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, got)
+		}
+	}
+}
+
+func TestAssertBalance_AbortOnError(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		AssertBalance{Account: "A", Expected: 100}, // stale expectation
+		transfer{from: "A", to: "C", value: 30},
+	}}
+
+	_, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Mode: AbortOnError})
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || txErr.Index != 1 || !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("Expected the assertion to stop the block, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 70, "B": 30})
+}
+
+func TestAssertBalance_JSON(t *testing.T) {
+	block := Block{Transactions: []Transaction{AssertBalance{Account: "A", Expected: 7}}}
+
+	var buf bytes.Buffer
+	if err := EncodeBlock(&buf, block); err != nil {
+		t.Fatalf("EncodeBlock failed: %v", err)
+	}
+	decoded, err := DecodeBlock(&buf)
+	if err != nil {
+		t.Fatalf("DecodeBlock failed: %v", err)
+	}
+	if !reflect.DeepEqual(block, decoded) {
+		t.Errorf("Round trip mismatch: expected %+v, got %+v", block, decoded)
+	}
+}
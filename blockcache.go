@@ -0,0 +1,130 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+)
+
+// BlockHash returns a SHA-256 hash identifying a block by its JSON encoding, so every
+// transaction must be of a type registered with RegisterTransactionType. Blocks with the same
+// transactions, in the same order, and the same dependencies have the same hash.
+func BlockHash(block Block) ([32]byte, error) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// blockCache remembers the results of the most recently run blocks, keyed by block hash and
+// the root of the state they ran against
+type blockCache struct {
+	size    int
+	mu      sync.Mutex
+	entries map[blockKey]*list.Element // values are *cachedBlock
+	recent  *list.List                 // most recently used first
+}
+
+// blockKey identifies a block run by the block's hash and a state root
+type blockKey struct {
+	block [32]byte
+	root  [32]byte
+}
+
+// cachedBlock is the result of a block run, available once done is closed
+type cachedBlock struct {
+	keys     []blockKey // keys of the entry: the state roots before and after the run
+	done     chan struct{}
+	snapshot []AccountValue
+	result   BlockResult
+	err      error
+}
+
+func newBlockCache(size int) *blockCache {
+	return &blockCache{
+		size:    size,
+		entries: make(map[blockKey]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// do returns the cached result of the block with the given hash, calling run to compute it if
+// there is none for the state root returned by root. A run is cached under the roots before
+// and after it, so that resubmitting the block against the state it produced returns the
+// result again rather than applying it twice. Concurrent calls for the same block and root
+// wait for the first instead of running the block again. Failed runs aren't cached: the calls
+// waiting for one run the block themselves.
+func (c *blockCache) do(hash [32]byte, root func() [32]byte, run func() ([]AccountValue, BlockResult, error)) ([]AccountValue, BlockResult, error) {
+	for {
+		key := blockKey{block: hash, root: root()}
+		c.mu.Lock()
+		if elem, ok := c.entries[key]; ok {
+			c.recent.MoveToFront(elem)
+			entry := elem.Value.(*cachedBlock)
+			c.mu.Unlock()
+
+			<-entry.done
+			if entry.err == nil {
+				return cloneBlockOutcome(entry.snapshot, entry.result)
+			}
+			continue
+		}
+
+		entry := &cachedBlock{keys: []blockKey{key}, done: make(chan struct{})}
+		elem := c.recent.PushFront(entry)
+		c.entries[key] = elem
+		c.evict()
+		c.mu.Unlock()
+
+		snapshot, result, err := run()
+		after := blockKey{block: hash, root: root()}
+		c.mu.Lock()
+		current, cached := c.entries[key]
+		cached = cached && current == elem
+		switch {
+		case err != nil && cached:
+			c.remove(elem)
+		case err == nil:
+			entry.snapshot, entry.result, _ = cloneBlockOutcome(snapshot, result)
+			if _, ok := c.entries[after]; cached && !ok {
+				c.entries[after] = elem
+				entry.keys = append(entry.keys, after)
+			}
+		}
+		entry.err = err
+		c.mu.Unlock()
+		close(entry.done)
+		return snapshot, result, err
+	}
+}
+
+// evict removes the least recently used entries beyond the cache size. The caller must hold the lock.
+func (c *blockCache) evict() {
+	for c.recent.Len() > c.size {
+		c.remove(c.recent.Back())
+	}
+}
+
+// remove removes an entry and all its keys. The caller must hold the lock.
+func (c *blockCache) remove(elem *list.Element) {
+	entry := c.recent.Remove(elem).(*cachedBlock)
+	for _, key := range entry.keys {
+		if c.entries[key] == elem {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cloneBlockOutcome deep-copies a snapshot and result, so that neither the cache nor its
+// callers can modify what the other holds
+func cloneBlockOutcome(snapshot []AccountValue, result BlockResult) ([]AccountValue, BlockResult, error) {
+	result.Transactions = append([]TxResult(nil), result.Transactions...)
+	for i := range result.Transactions {
+		if updates := result.Transactions[i].Updates; updates != nil {
+			result.Transactions[i].Updates = append([]AccountUpdate(nil), updates...)
+		}
+	}
+	return copySnapshot(snapshot), result, nil
+}
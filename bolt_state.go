@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var balancesBucket = []byte("balances")
+
+// BoltAccountState implements AccountState with balances persisted in a bbolt database,
+// so state survives restarts
+type BoltAccountState struct {
+	db *bolt.DB
+}
+
+// NewBoltAccountState opens or creates the database at path. Accounts in initialAccounts
+// that don't exist in the database yet are created with their given balance; accounts
+// already persisted keep their stored balance.
+func NewBoltAccountState(path string, initialAccounts []AccountValue) (*BoltAccountState, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open state database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(balancesBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, acc := range initialAccounts {
+			if bucket.Get([]byte(acc.Name)) != nil {
+				continue
+			}
+			if err := bucket.Put([]byte(acc.Name), encodeBalance(acc.Balance)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize state database: %w", err)
+	}
+
+	return &BoltAccountState{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *BoltAccountState) Close() error {
+	return s.db.Close()
+}
+
+// GetAccount implements AccountState interface. Nonexistent accounts, as well as accounts
+// that can't be read because the database is closed, have a zero balance.
+func (s *BoltAccountState) GetAccount(name string) AccountValue {
+	var balance uint
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		balance = decodeBalance(tx.Bucket(balancesBucket).Get([]byte(name)))
+		return nil
+	})
+
+	return AccountValue{
+		Name:    name,
+		Balance: balance,
+	}
+}
+
+// HasAccount implements AccountState interface. Accounts that can't be read because the
+// database is closed are reported as nonexistent.
+func (s *BoltAccountState) HasAccount(name string) bool {
+	var ok bool
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(balancesBucket).Get([]byte(name)) != nil
+		return nil
+	})
+	return ok
+}
+
+// ApplyUpdates implements AccountState interface. All updates are written in a single
+// database transaction, so either all of them are persisted or none are.
+func (s *BoltAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(balancesBucket)
+		for _, update := range updates {
+			if update.Asset != NativeAsset {
+				return fmt.Errorf("%w: asset %s for account %s, bolt state only stores the native asset", ErrUnsupportedOperation, update.Asset, update.Name)
+			}
+
+			key := []byte(update.Name)
+			value := bucket.Get(key)
+			entry, err := applyUpdate(accountEntry{balance: decodeBalance(value), exists: value != nil}, update, false)
+			if err != nil {
+				return err
+			}
+
+			if !entry.exists {
+				err = bucket.Delete(key)
+			} else {
+				err = bucket.Put(key, encodeBalance(entry.balance))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSnapshot returns the current state of all accounts, ordered by name
+func (s *BoltAccountState) GetSnapshot() []AccountValue {
+	var result []AccountValue
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(balancesBucket).ForEach(func(name, value []byte) error {
+			result = append(result, AccountValue{
+				Name:    string(name),
+				Balance: decodeBalance(value),
+			})
+			return nil
+		})
+	})
+	return result
+}
+
+func encodeBalance(balance uint) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(balance))
+	return buf
+}
+
+func decodeBalance(value []byte) uint {
+	if len(value) != 8 {
+		return 0
+	}
+	return uint(binary.BigEndian.Uint64(value))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltAccountState_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	initialState := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+	}
+
+	state, err := NewBoltAccountState(path, initialState)
+	if err != nil {
+		t.Fatalf("NewBoltAccountState failed: %v", err)
+	}
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},  // A->B: 5
+		transfer{from: "B", to: "C", value: 10}, // B->C: 10
+		transfer{from: "B", to: "C", value: 30}, // B->C: 30 (should fail)
+	}}
+	if _, _, err := ExecuteBlock(block, state, 4); err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if err := state.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Reopening with the initial accounts doesn't reset persisted balances
+	reopened, err := NewBoltAccountState(path, initialState)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer reopened.Close()
+
+	expected := map[string]uint{
+		"A": 15,
+		"B": 25,
+		"C": 50,
+	}
+	verifyResults(t, reopened.GetSnapshot(), expected)
+
+	if acc := reopened.GetAccount("C"); acc.Balance != 50 {
+		t.Errorf("Expected C to have balance 50, got %d", acc.Balance)
+	}
+	if !reopened.HasAccount("A") || reopened.HasAccount("D") {
+		t.Error("Expected A to exist and D not to exist")
+	}
+}
+
+func TestBoltAccountState_ApplyUpdatesIsAtomic(t *testing.T) {
+	state, err := NewBoltAccountState(filepath.Join(t.TempDir(), "state.db"), []AccountValue{{Name: "A", Balance: 10}})
+	if err != nil {
+		t.Fatalf("NewBoltAccountState failed: %v", err)
+	}
+	defer state.Close()
+
+	err = state.ApplyUpdates([]AccountUpdate{
+		{Name: "B", BalanceChange: 20},
+		{Name: "A", BalanceChange: -20},
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10})
+}
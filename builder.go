@@ -0,0 +1,35 @@
+package main
+
+// BlockBuilder constructs a Block one transaction at a time:
+//
+//	block := NewBlockBuilder().
+//		AddTransfer("alice", "bob", 10).
+//		Add(AssertBalance{Account: "bob", Expected: 10}).
+//		Build()
+//
+// The zero value is an empty builder.
+type BlockBuilder struct {
+	transactions []Transaction
+}
+
+// NewBlockBuilder returns an empty builder
+func NewBlockBuilder() *BlockBuilder {
+	return &BlockBuilder{}
+}
+
+// Add appends a transaction to the block
+func (b *BlockBuilder) Add(tx Transaction) *BlockBuilder {
+	b.transactions = append(b.transactions, tx)
+	return b
+}
+
+// AddTransfer appends a Transfer of amount from one account to another
+func (b *BlockBuilder) AddTransfer(from, to string, amount uint) *BlockBuilder {
+	return b.Add(Transfer{From: from, To: to, Amount: amount})
+}
+
+// Build returns the block built so far. The builder can keep being used; later additions
+// don't affect blocks already built.
+func (b *BlockBuilder) Build() Block {
+	return Block{Transactions: append([]Transaction(nil), b.transactions...)}
+}
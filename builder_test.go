@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBlockBuilder(t *testing.T) {
+	builder := NewBlockBuilder().
+		AddTransfer("A", "B", 30).
+		Add(mint{to: "C", value: 5}).
+		AddTransfer("B", "C", 10).
+		Add(AssertBalance{Account: "C", Expected: 15})
+	block := builder.Build()
+	builder.AddTransfer("A", "D", 1)
+
+	if len(block.Transactions) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d", len(block.Transactions))
+	}
+	if tx, ok := block.Transactions[0].(Transfer); !ok || tx != (Transfer{From: "A", To: "B", Amount: 30}) {
+		t.Errorf("Expected a Transfer, got %#v", block.Transactions[0])
+	}
+
+	snapshot, result, err := ExecuteBlock(block, NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}}), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d failed: %v", tx.Index, tx.Err)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 70, "B": 20, "C": 15})
+
+	if n := len(builder.Build().Transactions); n != 5 {
+		t.Errorf("Expected the builder to keep its transactions, got %d", n)
+	}
+}
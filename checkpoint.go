@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+)
+
+// StateCheckpoint is an immutable copy of an InMemoryAccountState taken by Checkpoint
+type StateCheckpoint struct {
+	accounts map[string]uint
+	assets   map[string]map[string]uint
+	metadata map[string]map[string]string
+	ledger   map[string]int // length of each account's ledger; entries are only ever appended
+}
+
+// Checkpoint captures the current balances, metadata and ledger. Later updates to the state don't affect the checkpoint.
+func (s *InMemoryAccountState) Checkpoint() StateCheckpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ledger := make(map[string]int, len(s.ledger))
+	for name, entries := range s.ledger {
+		ledger[name] = len(entries)
+	}
+	return StateCheckpoint{
+		accounts: copyBalances(s.accounts),
+		assets:   copyAssets(s.assets),
+		metadata: copyAllMetadata(s.metadata),
+		ledger:   ledger,
+	}
+}
+
+// Restore resets the state to the balances and metadata captured by checkpoint. Accounts created after the
+// checkpoint was taken are removed, and so are ledger entries recorded since. A checkpoint can be restored any
+// number of times.
+func (s *InMemoryAccountState) Restore(checkpoint StateCheckpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts = copyBalances(checkpoint.accounts)
+	s.assets = copyAssets(checkpoint.assets)
+	s.metadata = copyAllMetadata(checkpoint.metadata)
+	for name, entries := range s.ledger {
+		if n := checkpoint.ledger[name]; n > 0 {
+			s.ledger[name] = entries[:n:n]
+		} else {
+			delete(s.ledger, name)
+		}
+	}
+}
+
+// ExecuteBlockVerified is like ExecuteBlock but executes the block twice, restoring the initial
+// state in between, and fails with ErrNondeterministicBlock if the two executions end in states
+// that aren't SnapshotsEqual. It is meant for testing custom transaction types. On a mismatch the
+// state is restored to its initial balances and ledger. On error the BlockResult is empty.
+func ExecuteBlockVerified(block Block, state *InMemoryAccountState, numWorkers int) ([]AccountValue, BlockResult, error) {
+	checkpoint := state.Checkpoint()
+
+	first, _, err := ExecuteBlock(block, state, numWorkers)
+	if err != nil {
+		return nil, BlockResult{}, err
+	}
+	state.Restore(checkpoint)
+
+	second, result, err := ExecuteBlock(block, state, numWorkers)
+	if err != nil {
+		return nil, BlockResult{}, err
+	}
+	if !SnapshotsEqual(first, second) {
+		state.Restore(checkpoint)
+		return nil, BlockResult{}, fmt.Errorf("%w: state roots %x and %x differ", ErrNondeterministicBlock, StateRoot(first), StateRoot(second))
+	}
+	return second, result, nil
+}
+
+func copyBalances(accounts map[string]uint) map[string]uint {
+	result := make(map[string]uint, len(accounts))
+	for name, balance := range accounts {
+		result[name] = balance
+	}
+	return result
+}
+
+func copyAssets(assets map[string]map[string]uint) map[string]map[string]uint {
+	result := make(map[string]map[string]uint, len(assets))
+	for name, balances := range assets {
+		result[name] = copyBalances(balances)
+	}
+	return result
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	result := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		result[key] = value
+	}
+	return result
+}
+
+func copyAllMetadata(metadata map[string]map[string]string) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(metadata))
+	for name, entries := range metadata {
+		result[name] = copyMetadata(entries)
+	}
+	return result
+}
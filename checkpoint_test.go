@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckpoint_Restore(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50},
+	})
+
+	checkpoint := state.Checkpoint()
+
+	updates := [][]AccountUpdate{
+		{{Name: "A", BalanceChange: -30}, {Name: "B", BalanceChange: 30}},
+		{{Name: "B", BalanceChange: -80}, {Name: "C", BalanceChange: 80}},
+		{{Name: "C", BalanceChange: 5}},
+	}
+	for _, u := range updates {
+		if err := state.ApplyUpdates(u); err != nil {
+			t.Fatalf("ApplyUpdates failed: %v", err)
+		}
+	}
+
+	state.Restore(checkpoint)
+
+	expected := map[string]uint{
+		"A": 100,
+		"B": 50,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+
+	// The checkpoint is unaffected by updates made after restoring it
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -100}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	state.Restore(checkpoint)
+	verifyResults(t, state.GetSnapshot(), expected)
+}
+
+// luckyDraw pays one of several accounts, chosen by map iteration order
+type luckyDraw struct {
+	from       string
+	candidates map[string]bool
+}
+
+func (d luckyDraw) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	for name := range d.candidates {
+		return []AccountUpdate{{Name: d.from, BalanceChange: -1}, {Name: name, BalanceChange: 1}}, nil
+	}
+	return nil, nil
+}
+
+func TestExecuteBlockVerified(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 100}}
+
+	deterministic := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		transfer{from: "B", to: "C", value: 5},
+	}}
+	state := NewInMemoryAccountState(initialState)
+	state.EnableLedger()
+	snapshot, _, err := ExecuteBlockVerified(deterministic, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlockVerified failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 90, "B": 5, "C": 5})
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 90, "B": 5, "C": 5})
+	if history := state.History("B"); len(history) != 2 {
+		t.Errorf("Expected the ledger to hold one execution, got %+v", history)
+	}
+
+	candidates := make(map[string]bool)
+	for _, name := range []string{"B", "C", "D", "E", "F", "G", "H", "I"} {
+		candidates[name] = true
+	}
+	nondeterministic := Block{Transactions: []Transaction{luckyDraw{from: "A", candidates: candidates}}}
+
+	// Both executions pick the same account with probability 1/8, so retry a few times
+	for attempt := 0; attempt < 20; attempt++ {
+		state := NewInMemoryAccountState(initialState)
+		state.EnableLedger()
+		_, result, err := ExecuteBlockVerified(nondeterministic, state, 2)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrNondeterministicBlock) {
+			t.Fatalf("Expected ErrNondeterministicBlock, got %v", err)
+		}
+		verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100})
+		if history := state.History("A"); len(history) != 0 {
+			t.Errorf("Expected the ledger to be restored, got %+v", history)
+		}
+		if len(result.Transactions) != 0 {
+			t.Errorf("Expected an empty result, got %+v", result)
+		}
+		return
+	}
+	t.Fatal("Expected the nondeterministic block to be detected")
+}
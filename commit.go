@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+)
+
+// blockRun holds the state needed to commit the results of a block's transactions
+type blockRun struct {
+	transactions []Transaction
+	state        AccountState
+	declared     []accessSet
+	opts         BlockOptions
+	observer     ExecutionObserver
+	result       BlockResult
+	touched      map[string]struct{} // accounts touched by applied updates
+	names        func(string) string // normalizes account names as the state does, nil if it doesn't
+	hot          map[string]struct{} // accounts whose credits are deferred, see BlockOptions.HotAccounts
+	deferred     map[hotCredit]uint  // credits deferred to the end of the block
+	final        []bool              // committed results, see BlockOptions.OnResult
+	nextResult   int                 // index of the next result to pass to OnResult in order
+}
+
+func newBlockRun(block Block, state AccountState, declared []accessSet, opts BlockOptions) *blockRun {
+	run := &blockRun{
+		transactions: block.Transactions,
+		state:        state,
+		declared:     declared,
+		names:        nameNormalizerOf(state),
+		opts:         opts,
+		observer:     opts.Observer,
+		result:       BlockResult{Transactions: make([]TxResult, len(declared))},
+		touched:      make(map[string]struct{}),
+		hot:          make(map[string]struct{}, len(opts.HotAccounts)),
+		deferred:     make(map[hotCredit]uint),
+		final:        make([]bool, len(declared)),
+	}
+	for _, name := range opts.HotAccounts {
+		run.hot[name] = struct{}{}
+	}
+	if run.observer == nil {
+		run.observer = noopObserver{}
+	}
+	if opts.Logger != nil {
+		run.observer = logObserver{logger: opts.Logger, block: opts.BlockIndex, transactions: block.Transactions, next: run.observer}
+	}
+	for i := range run.result.Transactions {
+		run.result.Transactions[i].Index = i
+		run.result.Transactions[i].ID = transactionID(block.Transactions[i], i)
+	}
+	return run
+}
+
+// commit records the result of a transaction and applies its updates if it succeeded.
+// It returns an error if the block must stop.
+func (r *blockRun) commit(result txResult) error {
+	i := result.index
+	if result.cancelled {
+		r.observer.OnTransactionFailed(i, r.result.Transactions[i].ID, result.err)
+		return result.err
+	}
+	if r.opts.ValidateAccessSets {
+		if violation := result.access.normalized(r.names).within(r.declared[i]); violation != nil {
+			r.processed(true)
+			r.observer.OnTransactionFailed(i, r.result.Transactions[i].ID, violation)
+			return &TransactionError{Index: i, Err: violation}
+		}
+	}
+
+	if _, readOnly := r.transactions[i].(ReadOnly); readOnly && len(result.updates) > 0 && result.err == nil {
+		violation := fmt.Errorf("%w: read-only transaction returned %d updates", ErrAccessSetViolation, len(result.updates))
+		r.processed(true)
+		r.observer.OnTransactionFailed(i, r.result.Transactions[i].ID, violation)
+		return &TransactionError{Index: i, Err: violation}
+	}
+
+	txResult := &r.result.Transactions[i]
+	txResult.Reads = result.reads
+	if result.err == errConditionFailed || result.err == errDuplicateTransaction {
+		r.processed(false)
+		txResult.Applied, txResult.Skipped = true, true
+		r.observer.OnTransactionApplied(i, txResult.ID, nil)
+		return nil
+	}
+	if result.err == nil {
+		txResult.Writes = countAccounts(result.updates)
+		result.updates, result.err = transformUpdates(r.opts.Transformers, result.updates)
+	}
+	txResult.Updates = result.updates
+
+	if r.opts.CheckSupply && result.err == nil {
+		if violation := checkSupply(r.transactions[i], result.updates); violation != nil {
+			txResult.Err = violation
+			r.processed(true)
+			r.observer.OnTransactionFailed(i, r.result.Transactions[i].ID, violation)
+			return &TransactionError{Index: i, Err: violation}
+		}
+	}
+
+	err := result.err
+	noOp := err == nil && isNoOp(result.updates)
+	if err == nil {
+		// Apply updates if transaction succeeded
+		if !noOp || !r.opts.SkipNoOps {
+			immediate, deferred := r.splitHotCredits(result.updates)
+			if err = r.checkHotCredits(deferred); err == nil {
+				err = applyUpdatesTo(r.state, immediate, r.opts.BlockIndex, i)
+			}
+			if err == nil {
+				r.recordStats(immediate)
+				r.deferCredits(deferred)
+			}
+		}
+	}
+	r.processed(err != nil)
+	if err != nil {
+		txResult.Err = err
+		r.observer.OnTransactionFailed(i, r.result.Transactions[i].ID, err)
+		if r.opts.Mode == AbortOnError || r.opts.Atomic {
+			return &TransactionError{Index: i, Err: err}
+		}
+		return nil
+	}
+
+	txResult.Applied = true
+	txResult.NoOp = noOp
+	r.observer.OnTransactionApplied(i, txResult.ID, result.updates)
+	return nil
+}
+
+// emitResult passes the committed result of transaction i to OnResult, right away or, with
+// OrderedResults, once the results of every transaction before it have been passed
+func (r *blockRun) emitResult(i int) {
+	if r.opts.OnResult == nil {
+		return
+	}
+	if !r.opts.OrderedResults {
+		r.opts.OnResult(r.result.Transactions[i])
+		return
+	}
+	r.final[i] = true
+	for r.nextResult < len(r.final) && r.final[r.nextResult] {
+		r.opts.OnResult(r.result.Transactions[r.nextResult])
+		r.nextResult++
+	}
+}
+
+// flushResults passes the results still held back by OrderedResults to OnResult, in index
+// order, once the block stopped without committing the transactions before them
+func (r *blockRun) flushResults() {
+	if r.opts.OnResult == nil {
+		return
+	}
+	for ; r.nextResult < len(r.final); r.nextResult++ {
+		if r.final[r.nextResult] {
+			r.opts.OnResult(r.result.Transactions[r.nextResult])
+		}
+	}
+}
+
+// countAccounts returns the number of distinct accounts updates touch
+func countAccounts(updates []AccountUpdate) int {
+	accounts := make(map[string]struct{}, len(updates))
+	for _, update := range updates {
+		accounts[update.Name] = struct{}{}
+	}
+	return len(accounts)
+}
+
+// transformUpdates passes updates through each transformer in turn
+func transformUpdates(transformers []UpdateTransformer, updates []AccountUpdate) ([]AccountUpdate, error) {
+	for _, transform := range transformers {
+		var err error
+		if updates, err = transform(updates); err != nil {
+			return nil, err
+		}
+	}
+	return updates, nil
+}
+
+// recordStats adds applied updates to the block's stats
+func (r *blockRun) recordStats(updates []AccountUpdate) {
+	stats := &r.result.Stats
+	stats.Updates += len(updates)
+	for _, update := range updates {
+		r.touched[r.normalize(update.Name)] = struct{}{}
+		switch {
+		case update.Op != OpBalanceChange:
+		case update.BalanceChange < 0:
+			stats.Movement += uint(-update.BalanceChange)
+		default:
+			stats.Movement += uint(update.BalanceChange)
+		}
+	}
+	stats.Accounts = len(r.touched)
+}
+
+// normalize returns name normalized as the state normalizes it
+func (r *blockRun) normalize(name string) string {
+	if r.names == nil {
+		return name
+	}
+	return r.names(name)
+}
+
+// processed reports a committed transaction to the metrics recorder, if any
+func (r *blockRun) processed(failed bool) {
+	if r.opts.Metrics != nil {
+		r.opts.Metrics.TransactionProcessed(failed)
+	}
+}
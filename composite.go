@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+)
+
+// CompositeTransaction executes several transactions in order as a single unit. Each child
+// observes the effects of the children before it, and the updates of all children are applied
+// together, so if any child fails, none of them is applied.
+//
+// The composite's access set is the union of its children's; if any child doesn't implement
+// AccessAware, the composite conflicts with every other transaction. It conserves supply if
+// every child does.
+type CompositeTransaction struct {
+	Transactions []Transaction
+}
+
+// Updates implements Transaction interface
+func (c CompositeTransaction) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	overlay := newOverlayState(state)
+	for i, tx := range c.Transactions {
+		updates, err := tx.Updates(stateView{overlay})
+		if err != nil {
+			return nil, fmt.Errorf("child transaction %d: %w", i, err)
+		}
+		if err := overlay.ApplyUpdates(updates); err != nil {
+			return nil, fmt.Errorf("child transaction %d: %w", i, err)
+		}
+	}
+	updates, _ := overlay.bufferedUpdates()
+	return updates, nil
+}
+
+// ConservesSupply implements SupplyConserving interface
+func (c CompositeTransaction) ConservesSupply() bool {
+	for _, tx := range c.Transactions {
+		if conserving, ok := tx.(SupplyConserving); !ok || !conserving.ConservesSupply() {
+			return false
+		}
+	}
+	return true
+}
+
+// declaredAccess returns the union of the children's declared access sets
+func (c CompositeTransaction) declaredAccess() accessSet {
+	union := newAccessSet()
+	for _, tx := range c.Transactions {
+		access := declaredAccessSet(tx)
+		if access.all {
+			return access
+		}
+		union.readsAll = union.readsAll || access.readsAll
+		for name := range access.reads {
+			union.reads[name] = struct{}{}
+		}
+		for name := range access.writes {
+			union.writes[name] = struct{}{}
+		}
+	}
+	if union.readsAll && len(union.writes) > 0 {
+		// Reading every account while writing some conflicts with everything
+		return accessSet{all: true}
+	}
+	return union
+}
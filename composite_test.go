@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// payroll pays each employee the same salary from the employer's account as one composite
+func payroll(employer string, salary int, employees ...string) CompositeTransaction {
+	var payments []Transaction
+	for _, employee := range employees {
+		payments = append(payments, transfer{from: employer, to: employee, value: salary})
+	}
+	return CompositeTransaction{Transactions: payments}
+}
+
+func TestCompositeTransaction_AllOrNothing(t *testing.T) {
+	tests := []struct {
+		name     string
+		balance  uint
+		applied  bool
+		expected map[string]uint
+	}{
+		{"enough funds", 300, true, map[string]uint{"Corp": 0, "Ann": 100, "Bob": 100, "Cid": 100, "X": 0, "Y": 5}},
+		{"insufficient funds", 250, false, map[string]uint{"Corp": 250, "X": 0, "Y": 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewInMemoryAccountState([]AccountValue{
+				{Name: "Corp", Balance: tt.balance},
+				{Name: "X", Balance: 5},
+				{Name: "Y", Balance: 0},
+			})
+			block := Block{Transactions: []Transaction{
+				payroll("Corp", 100, "Ann", "Bob", "Cid"),
+				transfer{from: "X", to: "Y", value: 5}, // unaffected by the payroll
+			}}
+
+			snapshot, result, err := ExecuteBlock(block, state, 2)
+			if err != nil {
+				t.Fatalf("ExecuteBlock failed: %v", err)
+			}
+			if applied := result.Transactions[0].Applied; applied != tt.applied {
+				t.Errorf("Expected payroll applied=%v, got %+v", tt.applied, result.Transactions[0])
+			}
+			verifyResults(t, snapshot, tt.expected)
+		})
+	}
+}
+
+func TestCompositeTransaction_AccessSet(t *testing.T) {
+	transactions := []Transaction{
+		payroll("Corp", 10, "Ann", "Bob"),
+		transfer{from: "Bob", to: "Cid", value: 1}, // depends on the payroll
+		transfer{from: "Dan", to: "Eve", value: 1}, // independent
+		CompositeTransaction{Transactions: []Transaction{opaqueTransfer{transfer{from: "F", to: "G", value: 1}}}},
+	}
+
+	scheduler := NewDependencyScheduler(transactions)
+
+	expected := [][]int{{}, {0}, {}, {0, 1, 2}}
+	for i, deps := range expected {
+		if got := scheduler.Dependencies(i); fmt.Sprint(got) != fmt.Sprint(deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, got)
+		}
+	}
+}
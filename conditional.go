@@ -0,0 +1,55 @@
+package main
+
+import "errors"
+
+// errConditionFailed is returned by a ConditionalTransaction whose condition doesn't hold,
+// which marks the transaction skipped rather than failed
+var errConditionFailed = errors.New("condition doesn't hold")
+
+// ConditionalTransaction executes Transaction only if Condition holds when it executes. The
+// condition is evaluated against the same state Transaction then reads, so no other transaction
+// can commit in between. If it doesn't hold, the transaction is skipped: it applies nothing,
+// and is reported applied with TxResult.Skipped set rather than failed.
+//
+// The access set is Transaction's plus Reads, which must list the accounts Condition reads. It
+// conserves supply if Transaction does. Inside a CompositeTransaction, a false condition fails
+// the whole composite.
+type ConditionalTransaction struct {
+	Condition   func(ReadOnlyState) bool
+	Reads       []string
+	Transaction Transaction
+}
+
+// Updates implements Transaction interface
+func (c ConditionalTransaction) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if !c.Condition(state) {
+		return nil, errConditionFailed
+	}
+	return c.Transaction.Updates(state)
+}
+
+// ConservesSupply implements SupplyConserving interface
+func (c ConditionalTransaction) ConservesSupply() bool {
+	conserving, ok := c.Transaction.(SupplyConserving)
+	return ok && conserving.ConservesSupply()
+}
+
+// declaredAccess returns the inner transaction's declared access set with Reads added
+func (c ConditionalTransaction) declaredAccess() accessSet {
+	inner := declaredAccessSet(c.Transaction)
+	if inner.all {
+		return inner
+	}
+	access := newAccessSet()
+	access.readsAll = inner.readsAll
+	for name := range inner.reads {
+		access.reads[name] = struct{}{}
+	}
+	for name := range inner.writes {
+		access.writes[name] = struct{}{}
+	}
+	for _, name := range c.Reads {
+		access.reads[name] = struct{}{}
+	}
+	return access
+}
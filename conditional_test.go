@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// whileAbove transfers as long as the sender keeps more than floor
+func whileAbove(floor uint, tx transfer) ConditionalTransaction {
+	return ConditionalTransaction{
+		Condition:   func(state ReadOnlyState) bool { return state.GetAccount(tx.from).Balance > floor },
+		Reads:       []string{tx.from},
+		Transaction: tx,
+	}
+}
+
+func TestConditionalTransaction(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		whileAbove(60, transfer{from: "A", to: "B", value: 50}), // A has 100
+		whileAbove(60, transfer{from: "A", to: "B", value: 50}), // A has 50, skipped
+		transfer{from: "A", to: "C", value: 10},
+	}}
+
+	observer := newRecordingObserver()
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{ValidateAccessSets: true, Observer: observer})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if r := result.Transactions[0]; !r.Applied || r.Skipped || len(r.Updates) != 2 {
+		t.Errorf("Expected the first transfer to be applied, got %+v", r)
+	}
+	if r := result.Transactions[1]; !r.Applied || !r.Skipped || r.Err != nil || len(r.Updates) != 0 {
+		t.Errorf("Expected the second transfer to be skipped, got %+v", r)
+	}
+	if len(observer.failed) != 0 {
+		t.Errorf("Expected no failures reported, got %v", observer.failed)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 40, "B": 50, "C": 10})
+
+	// A false condition fails a composite it is part of
+	composite := CompositeTransaction{Transactions: []Transaction{
+		transfer{from: "A", to: "C", value: 10},
+		whileAbove(60, transfer{from: "A", to: "B", value: 10}),
+	}}
+	_, result, err = ExecuteBlock(Block{Transactions: []Transaction{composite}}, state, 1)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if r := result.Transactions[0]; r.Applied || r.Err == nil {
+		t.Errorf("Expected the composite to fail, got %+v", r)
+	}
+}
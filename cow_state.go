@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// COWAccountState implements AccountState with copy-on-write: the accounts live in an immutable
+// map that ApplyUpdates replaces with an updated copy, published through an atomic pointer.
+// Reads load the current map without locking and never wait for writers, and a snapshot is
+// always consistent. Writers serialize among themselves and copy the whole map, so this state
+// suits read-heavy workloads over a moderate number of accounts.
+type COWAccountState struct {
+	mu       sync.Mutex // serializes writers
+	accounts atomic.Pointer[map[string]accountEntry]
+}
+
+func NewCOWAccountState(initialAccounts []AccountValue) *COWAccountState {
+	accounts := make(map[string]accountEntry, len(initialAccounts))
+	for _, acc := range initialAccounts {
+		entry := accountEntry{balance: acc.Balance, exists: true}
+		if len(acc.Assets) > 0 {
+			entry.assets = copyBalances(acc.Assets)
+		}
+		accounts[acc.Name] = entry
+	}
+
+	state := &COWAccountState{}
+	state.accounts.Store(&accounts)
+	return state
+}
+
+// GetAccount implements AccountState interface
+func (s *COWAccountState) GetAccount(name string) AccountValue {
+	return cowAccountValue(name, (*s.accounts.Load())[name])
+}
+
+// cowAccountValue returns the account with a copy of its asset balances
+func cowAccountValue(name string, entry accountEntry) AccountValue {
+	value := AccountValue{Name: name, Balance: entry.balance}
+	if len(entry.assets) > 0 {
+		value.Assets = copyBalances(entry.assets)
+	}
+	return value
+}
+
+// HasAccount implements AccountState interface
+func (s *COWAccountState) HasAccount(name string) bool {
+	_, ok := (*s.accounts.Load())[name]
+	return ok
+}
+
+// ApplyUpdates implements AccountState interface. Readers keep seeing the previous map until
+// every update has been validated and the new one is published.
+func (s *COWAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := *s.accounts.Load()
+	staged := make(map[string]accountEntry, len(updates))
+	for _, update := range updates {
+		entry, ok := staged[update.Name]
+		if !ok {
+			entry = current[update.Name]
+		}
+
+		entry, err := applyUpdate(entry, update, false)
+		if err != nil {
+			return err
+		}
+		staged[update.Name] = entry
+	}
+
+	// Entries are never modified in place, and applyUpdate copies asset maps before changing
+	// them, so the new map can share entries with the current one
+	next := make(map[string]accountEntry, len(current)+len(staged))
+	for name, entry := range current {
+		next[name] = entry
+	}
+	for name, entry := range staged {
+		if entry.exists {
+			next[name] = entry
+		} else {
+			delete(next, name)
+		}
+	}
+	s.accounts.Store(&next)
+	return nil
+}
+
+// GetSnapshot returns the current state of all accounts, sorted by name
+func (s *COWAccountState) GetSnapshot() []AccountValue {
+	accounts := *s.accounts.Load()
+	result := make([]AccountValue, 0, len(accounts))
+	for name, entry := range accounts {
+		result = append(result, cowAccountValue(name, entry))
+	}
+	sortAccounts(result)
+	return result
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCOWAccountState_MatchesInMemoryState(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100, Assets: map[string]uint{"gold": 5}},
+		{Name: "B", Balance: 50},
+		{Name: "C", Balance: 10},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		transfer{from: "C", to: "D", value: 20}, // fails
+		transfer{from: "B", to: "C", value: 60},
+		transfer{from: "A", to: "E", value: 5},
+	}}
+
+	expected, _, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on in-memory state failed: %v", err)
+	}
+	actual, _, err := ExecuteBlock(block, NewCOWAccountState(initialState), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on copy-on-write state failed: %v", err)
+	}
+	if !SnapshotsEqual(expected, actual) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestCOWAccountState_FailedUpdatesAreNotPublished(t *testing.T) {
+	state := NewCOWAccountState([]AccountValue{{Name: "A", Balance: 10}, {Name: "B", Balance: 10}})
+	before := state.GetSnapshot()
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "A", BalanceChange: 5},
+		{Name: "B", BalanceChange: -20},
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10, "B": 10})
+
+	// Snapshots taken earlier aren't affected by later updates
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", Op: OpDelete, Force: true}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	verifyResults(t, before, map[string]uint{"A": 10, "B": 10})
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"B": 10})
+}
+
+func TestCOWAccountState_ReadersSeeConsistentState(t *testing.T) {
+	const numAccounts = 8
+	const total = numAccounts * 100
+	var initialState []AccountValue
+	for i := 0; i < numAccounts; i++ {
+		initialState = append(initialState, AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 100})
+	}
+	state := NewCOWAccountState(initialState)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				from, to := fmt.Sprintf("A%d", (g+i)%numAccounts), fmt.Sprintf("A%d", (g+i+1)%numAccounts)
+				_ = state.ApplyUpdates([]AccountUpdate{{Name: from, BalanceChange: -1}, {Name: to, BalanceChange: 1}})
+			}
+		}(g)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		var sum uint
+		for _, acc := range state.GetSnapshot() {
+			sum += acc.Balance
+		}
+		if sum != total {
+			t.Fatalf("Snapshot total %d, expected %d", sum, total)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func BenchmarkAccountState_ReadsUnderWrites(b *testing.B) {
+	const numAccounts = 1024
+	var initialState []AccountValue
+	for i := 0; i < numAccounts; i++ {
+		initialState = append(initialState, AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 1 << 40})
+	}
+
+	states := []struct {
+		name  string
+		state AccountState
+	}{
+		{"rwmutex", NewInMemoryAccountState(initialState)},
+		{"copy-on-write", NewCOWAccountState(initialState)},
+	}
+	for _, s := range states {
+		b.Run(s.name, func(b *testing.B) {
+			// A writer keeps moving funds between two accounts while the benchmark reads
+			stop := make(chan struct{})
+			var writer sync.WaitGroup
+			writer.Add(1)
+			go func() {
+				defer writer.Done()
+				updates := []AccountUpdate{{Name: "A0", BalanceChange: -1}, {Name: "A1", BalanceChange: 1}}
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if err := s.state.ApplyUpdates(updates); err != nil {
+						b.Errorf("ApplyUpdates failed: %v", err)
+						return
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					s.state.GetAccount(fmt.Sprintf("A%d", i%numAccounts))
+					i++
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			writer.Wait()
+		})
+	}
+}
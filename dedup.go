@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+// Hashable is implemented by transactions that can be identified by their content, so that
+// duplicate submissions of a transaction can be recognized, see BlockOptions.Deduplicate
+type Hashable interface {
+	// Hash returns a hash of the transaction's type and content. Transactions with the same
+	// hash are considered the same transaction.
+	Hash() [32]byte
+}
+
+// errDuplicateTransaction is returned by a transaction standing in for a duplicate removed by
+// deduplication, which marks it skipped rather than failed
+var errDuplicateTransaction = errors.New("duplicate transaction")
+
+// contentHash hashes tx's JSON encoding together with name, the type it's registered as, so
+// that transactions of different types never share a hash
+func contentHash(name string, tx any) [32]byte {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		// Only called for plain structs, which always encode
+		panic(err)
+	}
+	return sha256.Sum256(append([]byte(name+":"), data...))
+}
+
+// deduplicate replaces every Hashable transaction with the same hash as an earlier one by a
+// duplicateTransaction, keeping its ID
+func deduplicate(transactions []Transaction) []Transaction {
+	seen := make(map[[32]byte]struct{})
+	var deduplicated []Transaction
+	for i, tx := range transactions {
+		hashable, ok := tx.(Hashable)
+		if !ok {
+			continue
+		}
+		hash := hashable.Hash()
+		if _, ok := seen[hash]; !ok {
+			seen[hash] = struct{}{}
+			continue
+		}
+		if deduplicated == nil {
+			deduplicated = append([]Transaction(nil), transactions...)
+		}
+		deduplicated[i] = duplicateTransaction{id: transactionID(tx, i)}
+	}
+	if deduplicated == nil {
+		return transactions
+	}
+	return deduplicated
+}
+
+// duplicateTransaction stands in for a duplicate removed by deduplication, keeping its ID
+type duplicateTransaction struct {
+	id string
+}
+
+func (t duplicateTransaction) ID() string { return t.id }
+
+func (duplicateTransaction) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	return nil, errDuplicateTransaction
+}
+
+func (duplicateTransaction) AccessSet() ([]string, []string) { return nil, nil }
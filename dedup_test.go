@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExecuteBlock_Deduplicate(t *testing.T) {
+	block := NewBlockBuilder().
+		AddTransfer("A", "B", 10).
+		Add(Mint{To: "B", Amount: 10}).
+		AddTransfer("A", "B", 10). // duplicate of the first
+		AddTransfer("A", "B", 20).
+		Add(Mint{To: "B", Amount: 10}).              // duplicate of the mint
+		Add(transfer{from: "A", to: "B", value: 5}). // not Hashable
+		Add(transfer{from: "A", to: "B", value: 5}).
+		Build()
+	initialState := []AccountValue{{Name: "A", Balance: 100}}
+
+	state := NewInMemoryAccountState(initialState)
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Deduplicate: true})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	for i, tx := range result.Transactions {
+		duplicate := i == 2 || i == 4
+		if !tx.Applied || tx.Err != nil || tx.Skipped != duplicate {
+			t.Errorf("Transaction %d: expected applied with Skipped %v, got %+v", i, duplicate, tx)
+		}
+		if duplicate && (len(tx.Updates) > 0 || tx.ID != transactionID(block.Transactions[i], i)) {
+			t.Errorf("Duplicate %d: expected no updates and its own ID, got %+v", i, tx)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 60, "B": 50})
+
+	// Without deduplication every transaction applies
+	snapshot, _, err = ExecuteBlock(block, NewInMemoryAccountState(initialState), 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 50, "B": 70})
+}
+
+func TestHashable_DistinguishesContentAndType(t *testing.T) {
+	hashes := map[[32]byte]Transaction{}
+	for _, tx := range []Hashable{
+		Transfer{From: "A", To: "B", Amount: 10},
+		Transfer{From: "B", To: "A", Amount: 10},
+		Transfer{From: "A", To: "B", Amount: 11},
+		Mint{To: "B", Amount: 10},
+		Burn{From: "B", Amount: 10},
+	} {
+		if other, ok := hashes[tx.Hash()]; ok {
+			t.Errorf("%+v and %+v have the same hash", tx, other)
+		}
+		hashes[tx.Hash()] = tx.(Transaction)
+	}
+	if (Transfer{From: "A", To: "B", Amount: 10}).Hash() != (Transfer{From: "A", To: "B", Amount: 10}).Hash() {
+		t.Error("Identical transfers have different hashes")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"sort"
+)
+
+// AccountDelta describes how an account's native balance differs between two snapshots
+type AccountDelta struct {
+	Name    string
+	Before  uint // balance in the first snapshot, 0 if Created
+	After   uint // balance in the second snapshot, 0 if Deleted
+	Created bool // the account only exists in the second snapshot
+	Deleted bool // the account only exists in the first snapshot
+}
+
+// Change returns the difference between the balances
+func (d AccountDelta) Change() int {
+	return int(d.After) - int(d.Before)
+}
+
+// DiffSnapshots returns the accounts whose native balance differs between before and after,
+// including accounts that appear in only one of them, sorted by name. Accounts present in both
+// with the same balance are omitted.
+func DiffSnapshots(before, after []AccountValue) []AccountDelta {
+	previous := make(map[string]uint, len(before))
+	for _, acc := range before {
+		previous[acc.Name] = acc.Balance
+	}
+
+	var deltas []AccountDelta
+	for _, acc := range after {
+		balance, existed := previous[acc.Name]
+		delete(previous, acc.Name)
+		switch {
+		case !existed:
+			deltas = append(deltas, AccountDelta{Name: acc.Name, After: acc.Balance, Created: true})
+		case balance != acc.Balance:
+			deltas = append(deltas, AccountDelta{Name: acc.Name, Before: balance, After: acc.Balance})
+		}
+	}
+	for name, balance := range previous {
+		deltas = append(deltas, AccountDelta{Name: name, Before: balance, Deleted: true})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	return deltas
+}
+
+// SnapshotOptions configures how SnapshotsEqualWithOptions compares snapshots
+type SnapshotOptions struct {
+	// AbsentAsZero treats an account missing from one snapshot as equal to an account with
+	// no balances in the other
+	AbsentAsZero bool
+	// Tolerance treats balances of the same account and asset as equal if they differ by at
+	// most Tolerance
+	Tolerance uint
+}
+
+// SnapshotsEqual reports whether two snapshots hold the same accounts with the same native
+// and asset balances, regardless of their order. Zero asset balances are ignored, and so is
+// metadata, matching StateRoot.
+func SnapshotsEqual(a, b []AccountValue) bool {
+	return SnapshotsEqualWithOptions(a, b, SnapshotOptions{})
+}
+
+// SnapshotsEqualWithin is SnapshotsEqual, except that balances of the same account and asset
+// differing by at most tolerance are equal, for workloads where fees or rounding make exact
+// reconciliation too strict
+func SnapshotsEqualWithin(a, b []AccountValue, tolerance uint) bool {
+	return SnapshotsEqualWithOptions(a, b, SnapshotOptions{Tolerance: tolerance})
+}
+
+// SnapshotTotalsEqualWithin reports whether the total balances of every asset, native
+// included, across all accounts of two snapshots differ by at most tolerance
+func SnapshotTotalsEqualWithin(a, b []AccountValue, tolerance uint) bool {
+	return assetsWithin(snapshotTotals(a), snapshotTotals(b), tolerance)
+}
+
+// snapshotTotals returns the total balance of each asset in a snapshot, keyed by asset name
+func snapshotTotals(snapshot []AccountValue) map[string]uint {
+	totals := make(map[string]uint)
+	for _, acc := range snapshot {
+		totals[NativeAsset] += acc.Balance
+		for asset, balance := range acc.Assets {
+			totals[asset] += balance
+		}
+	}
+	return totals
+}
+
+// SnapshotsEqualWithOptions is SnapshotsEqual with options controlling the comparison
+func SnapshotsEqualWithOptions(a, b []AccountValue, opts SnapshotOptions) bool {
+	accounts := make(map[string]AccountValue, len(a))
+	for _, acc := range a {
+		accounts[acc.Name] = acc
+	}
+
+	for _, acc := range b {
+		other, ok := accounts[acc.Name]
+		delete(accounts, acc.Name)
+		if !ok && !(opts.AbsentAsZero && isEmptyAccount(acc)) {
+			return false
+		}
+		if !within(acc.Balance, other.Balance, opts.Tolerance) || !assetsWithin(acc.Assets, other.Assets, opts.Tolerance) {
+			return false
+		}
+	}
+	for _, acc := range accounts {
+		if !opts.AbsentAsZero || !isEmptyAccount(acc) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyAccount reports whether an account holds no funds in any asset
+func isEmptyAccount(acc AccountValue) bool {
+	return acc.Balance == 0 && assetsWithin(acc.Assets, nil, 0)
+}
+
+// assetsWithin compares asset balances up to tolerance, treating missing assets as zero
+func assetsWithin(a, b map[string]uint, tolerance uint) bool {
+	for asset, balance := range a {
+		if !within(b[asset], balance, tolerance) {
+			return false
+		}
+	}
+	for asset, balance := range b {
+		if !within(a[asset], balance, tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// within reports whether x and y differ by at most tolerance
+func within(x, y, tolerance uint) bool {
+	if x < y {
+		x, y = y, x
+	}
+	return x-y <= tolerance
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := []AccountValue{
+		{Name: "Unchanged", Balance: 10},
+		{Name: "Increased", Balance: 10},
+		{Name: "Decreased", Balance: 10},
+		{Name: "Deleted", Balance: 5},
+		{Name: "DeletedEmpty", Balance: 0},
+	}
+	after := []AccountValue{
+		{Name: "Created", Balance: 7},
+		{Name: "CreatedEmpty", Balance: 0},
+		{Name: "Decreased", Balance: 4},
+		{Name: "Increased", Balance: 25},
+		{Name: "Unchanged", Balance: 10},
+	}
+
+	expected := []AccountDelta{
+		{Name: "Created", After: 7, Created: true},
+		{Name: "CreatedEmpty", Created: true},
+		{Name: "Decreased", Before: 10, After: 4},
+		{Name: "Deleted", Before: 5, Deleted: true},
+		{Name: "DeletedEmpty", Deleted: true},
+		{Name: "Increased", Before: 10, After: 25},
+	}
+	deltas := DiffSnapshots(before, after)
+	if !reflect.DeepEqual(deltas, expected) {
+		t.Fatalf("Expected deltas %+v, got %+v", expected, deltas)
+	}
+
+	changes := map[string]int{"Created": 7, "Decreased": -6, "Deleted": -5, "Increased": 15}
+	for _, delta := range deltas {
+		if delta.Change() != changes[delta.Name] {
+			t.Errorf("Account %s: expected change %d, got %d", delta.Name, changes[delta.Name], delta.Change())
+		}
+	}
+
+	if deltas := DiffSnapshots(before, before); len(deltas) != 0 {
+		t.Errorf("Expected no deltas between identical snapshots, got %+v", deltas)
+	}
+}
+
+func TestSnapshotsEqual(t *testing.T) {
+	base := []AccountValue{
+		{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 3}},
+		{Name: "B", Balance: 20},
+		{Name: "C", Balance: 0},
+	}
+
+	tests := []struct {
+		name         string
+		other        []AccountValue
+		equal        bool
+		absentAsZero bool // result with AbsentAsZero
+	}{
+		{
+			name: "reordered",
+			other: []AccountValue{
+				{Name: "C", Balance: 0},
+				{Name: "B", Balance: 20, Assets: map[string]uint{"gold": 0}},
+				{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 3}},
+			},
+			equal:        true,
+			absentAsZero: true,
+		},
+		{
+			name: "differing balance",
+			other: []AccountValue{
+				{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 3}},
+				{Name: "B", Balance: 21},
+				{Name: "C", Balance: 0},
+			},
+		},
+		{
+			name: "differing asset balance",
+			other: []AccountValue{
+				{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 4}},
+				{Name: "B", Balance: 20},
+				{Name: "C", Balance: 0},
+			},
+		},
+		{
+			name: "missing empty account",
+			other: []AccountValue{
+				{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 3}},
+				{Name: "B", Balance: 20},
+			},
+			absentAsZero: true,
+		},
+		{
+			name:         "extra empty account",
+			other:        append([]AccountValue{{Name: "D", Assets: map[string]uint{"gold": 0}}}, base...),
+			absentAsZero: true,
+		},
+		{
+			name: "missing funded account",
+			other: []AccountValue{
+				{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 3}},
+				{Name: "C", Balance: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := SnapshotsEqual(base, tt.other); equal != tt.equal {
+				t.Errorf("SnapshotsEqual returned %v, expected %v", equal, tt.equal)
+			}
+			if equal := SnapshotsEqual(tt.other, base); equal != tt.equal {
+				t.Errorf("SnapshotsEqual with swapped arguments returned %v, expected %v", equal, tt.equal)
+			}
+			opts := SnapshotOptions{AbsentAsZero: true}
+			if equal := SnapshotsEqualWithOptions(base, tt.other, opts); equal != tt.absentAsZero {
+				t.Errorf("SnapshotsEqualWithOptions returned %v, expected %v", equal, tt.absentAsZero)
+			}
+			if equal := SnapshotsEqualWithOptions(tt.other, base, opts); equal != tt.absentAsZero {
+				t.Errorf("SnapshotsEqualWithOptions with swapped arguments returned %v, expected %v", equal, tt.absentAsZero)
+			}
+		})
+	}
+}
+
+func TestSnapshotsEqualWithin(t *testing.T) {
+	base := []AccountValue{
+		{Name: "A", Balance: 100, Assets: map[string]uint{"gold": 10}},
+		{Name: "B", Balance: 50},
+	}
+
+	tests := []struct {
+		name   string
+		other  []AccountValue
+		equal  bool
+		totals bool
+	}{
+		{
+			name:   "below tolerance",
+			other:  []AccountValue{{Name: "A", Balance: 101, Assets: map[string]uint{"gold": 9}}, {Name: "B", Balance: 50}},
+			equal:  true,
+			totals: true,
+		},
+		{
+			name:   "at tolerance",
+			other:  []AccountValue{{Name: "A", Balance: 98, Assets: map[string]uint{"gold": 12}}, {Name: "B", Balance: 50}},
+			equal:  true,
+			totals: true,
+		},
+		{
+			name:   "above tolerance",
+			other:  []AccountValue{{Name: "A", Balance: 103, Assets: map[string]uint{"gold": 10}}, {Name: "B", Balance: 50}},
+			equal:  false,
+			totals: false,
+		},
+		{
+			// B is off by 3, but the total only by 1
+			name:   "totals within tolerance",
+			other:  []AccountValue{{Name: "A", Balance: 98, Assets: map[string]uint{"gold": 10}}, {Name: "B", Balance: 53}},
+			equal:  false,
+			totals: true,
+		},
+		{
+			name:   "missing account",
+			other:  []AccountValue{{Name: "A", Balance: 150, Assets: map[string]uint{"gold": 10}}},
+			equal:  false,
+			totals: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SnapshotsEqualWithin(base, tt.other, 2); got != tt.equal {
+				t.Errorf("SnapshotsEqualWithin: expected %v, got %v", tt.equal, got)
+			}
+			if got := SnapshotTotalsEqualWithin(base, tt.other, 2); got != tt.totals {
+				t.Errorf("SnapshotTotalsEqualWithin: expected %v, got %v", tt.totals, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAccessSetViolation is returned when access set validation is enabled and a
+	// transaction accesses accounts outside the set it declared via AccessAware.
+	ErrAccessSetViolation = errors.New("transaction accessed undeclared accounts")
+
+	// ErrInvalidWorkerCount is returned when a block is executed with fewer than one worker.
+	ErrInvalidWorkerCount = errors.New("number of workers must be at least 1")
+
+	// ErrOverflow is returned when applying an update would overflow an account balance.
+	ErrOverflow = errors.New("balance overflow")
+
+	// ErrSupplyViolation is returned when supply checking is enabled and a supply-conserving
+	// transaction creates or destroys funds.
+	ErrSupplyViolation = errors.New("transaction changed total supply")
+
+	// ErrUnknownTransactionType is returned when encoding or decoding a transaction whose type
+	// wasn't registered with RegisterTransactionType.
+	ErrUnknownTransactionType = errors.New("unknown transaction type")
+
+	// ErrTransactionTimeout is recorded for a transaction whose Updates didn't return within
+	// the block's TxTimeout.
+	ErrTransactionTimeout = errors.New("transaction timed out")
+
+	// ErrConflictRejected is recorded for a transaction that the block's OnConflict callback
+	// didn't allow to proceed.
+	ErrConflictRejected = errors.New("transaction rejected by conflict resolution")
+
+	// ErrNondeterministicBlock is returned by ExecuteBlockVerified when executing the same block
+	// twice from the same state produced different states.
+	ErrNondeterministicBlock = errors.New("block execution is nondeterministic")
+
+	// ErrBlockAborted is reported to observers for transactions discarded because their block stopped early.
+	ErrBlockAborted = errors.New("block aborted before transaction was applied")
+
+	// ErrInsufficientBalance is returned when a debit exceeds an account's balance.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+
+	// ErrAccountExists is returned when creating an account that already exists.
+	ErrAccountExists = errors.New("account already exists")
+
+	// ErrAccountNotFound is returned when an operation requires an account that doesn't exist.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrNonZeroBalance is returned when deleting an account that still holds funds without forcing it.
+	ErrNonZeroBalance = errors.New("account has nonzero balance")
+
+	// ErrBelowMinimum is returned when a debit would take an account below its minimum balance.
+	ErrBelowMinimum = errors.New("balance below minimum")
+
+	// ErrCreditLimitExceeded is returned when a debit would take a signed account below its credit limit.
+	ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+
+	// ErrUnsupportedOperation is returned when an update's operation or asset isn't supported
+	// by the account state it is applied to, and by ExecuteBlockResumable for a block it can't
+	// resume.
+	ErrUnsupportedOperation = errors.New("unsupported account operation")
+
+	// ErrNotPrepared is returned when committing or aborting a two-phase transaction ID that
+	// isn't prepared, either because it was never issued or because it already finished.
+	ErrNotPrepared = errors.New("transaction not prepared")
+
+	// ErrDependencyCycle is returned when the explicit dependencies of a block, together with
+	// those inferred from access sets, form a cycle.
+	ErrDependencyCycle = errors.New("transaction dependencies form a cycle")
+
+	// ErrInvalidDependency is returned when a block declares a dependency on or of a
+	// transaction index outside the block.
+	ErrInvalidDependency = errors.New("dependency refers to an unknown transaction")
+
+	// ErrExecutorClosed is returned when using an Executor after Shutdown was called.
+	ErrExecutorClosed = errors.New("executor is shut down")
+
+	// ErrInvalidOption is returned by NewExecutor when an option has an invalid value or
+	// conflicts with another option, and by other functions for invalid configuration
+	// arguments, such as an unknown account Format or a non-positive checkpoint interval.
+	ErrInvalidOption = errors.New("invalid executor option")
+
+	// ErrBlockOutOfRange is returned when a block index doesn't refer to one of the given blocks.
+	ErrBlockOutOfRange = errors.New("block index out of range")
+
+	// ErrBlockTooLarge is returned when a block has more transactions than its MaxTransactions limit.
+	ErrBlockTooLarge = errors.New("block has too many transactions")
+
+	// ErrAssertionFailed is returned by an AssertBalance transaction whose account doesn't hold
+	// the expected balance.
+	ErrAssertionFailed = errors.New("balance assertion failed")
+
+	// ErrTransactionPanic is recorded for a transaction whose Updates panicked. The error also
+	// reports the panic value, which it wraps if it is an error, and the stack of the panic.
+	ErrTransactionPanic = errors.New("transaction panicked")
+
+	// ErrInvalidAccountName is returned when an account name is rejected by a NamePolicy.
+	ErrInvalidAccountName = errors.New("invalid account name")
+
+	// ErrAccountFrozen is returned when an update touches an account that is frozen.
+	ErrAccountFrozen = errors.New("account is frozen")
+
+	// ErrDuplicateAccount is returned by NewInMemoryAccountStateStrict and
+	// NewInMemoryAccountStateFromReader when the initial accounts name the same account more
+	// than once.
+	ErrDuplicateAccount = errors.New("duplicate account")
+
+	// ErrInvalidGroups is returned when a block's Groups don't list every transaction index
+	// exactly once.
+	ErrInvalidGroups = errors.New("groups don't partition the block")
+
+	// ErrInvalidTransaction is returned by ValidateBlock for a transaction whose Validate
+	// method fails, wrapping that error.
+	ErrInvalidTransaction = errors.New("invalid transaction")
+
+	// ErrInvalidScale is returned for a fixed-point scale above MaxScale.
+	ErrInvalidScale = errors.New("invalid fixed-point scale")
+
+	// ErrCheckpointNotFound is returned by Executor.SnapshotAt for a block height that wasn't
+	// checkpointed.
+	ErrCheckpointNotFound = errors.New("no checkpoint at block height")
+)
+
+// TransactionError is returned when a transaction stops its block. It identifies the
+// transaction and wraps the underlying error, which can be inspected with errors.Is.
+type TransactionError struct {
+	// Index is the index of the transaction within its block
+	Index int
+	Err   error
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("transaction %d failed: %v", e.Index, e.Err)
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// joinFailures returns an error joining a TransactionError for each failed transaction, or nil
+// if none failed
+func joinFailures(results []TxResult) error {
+	var failures []error
+	for _, result := range results {
+		if result.Err != nil && !result.Applied {
+			failures = append(failures, &TransactionError{Index: result.Index, Err: result.Err})
+		}
+	}
+	return errors.Join(failures...)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	newState := func() *InMemoryAccountState {
+		return NewInMemoryAccountState([]AccountValue{
+			{Name: "A", Balance: 10},
+			{Name: "Max", Balance: math.MaxUint},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+	}{
+		{
+			"insufficient balance",
+			newState().ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -11}}),
+			ErrInsufficientBalance,
+		},
+		{
+			"account not found",
+			newState().ApplyUpdates([]AccountUpdate{{Name: "B", Op: OpDelete}}),
+			ErrAccountNotFound,
+		},
+		{
+			"overflow",
+			newState().ApplyUpdates([]AccountUpdate{{Name: "Max", BalanceChange: 1}}),
+			ErrOverflow,
+		},
+		{
+			"unsupported operation",
+			newState().ApplyUpdates([]AccountUpdate{{Name: "A", Op: AccountOp(99)}}),
+			ErrUnsupportedOperation,
+		},
+		{
+			"invalid worker count",
+			func() error { _, _, err := ExecuteBlock(Block{}, newState(), 0); return err }(),
+			ErrInvalidWorkerCount,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.sentinel) {
+				t.Errorf("Expected %v, got %v", tt.sentinel, tt.err)
+			}
+		})
+	}
+}
+
+func TestTransactionError_IdentifiesTransaction(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},
+		transfer{from: "A", to: "C", value: 50},
+	}}
+
+	_, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Mode: AbortOnError})
+
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("Expected a TransactionError, got %v", err)
+	}
+	if txErr.Index != 1 {
+		t.Errorf("Expected transaction 1 to fail, got %d", txErr.Index)
+	}
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected the error to wrap ErrInsufficientBalance, got %v", err)
+	}
+}
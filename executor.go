@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Executor executes blocks with a fixed configuration, set with options when it is created.
+// It can run any number of blocks and streams concurrently and be shut down cleanly: blocks
+// are never interrupted, so every state reflects a whole number of blocks. A long-running
+// process would typically call Shutdown once signal.NotifyContext reports SIGINT.
+type Executor struct {
+	numWorkers int
+	opts       BlockOptions
+	cacheSize  int
+	cache      *blockCache // results of recent blocks run with RunBlock, nil unless enabled
+	interval   int
+	history    *snapshotHistory // checkpoints of Run and Stream, nil unless enabled
+
+	mu       sync.Mutex
+	closed   bool
+	closers  []io.Closer    // states of streams, closed by Shutdown
+	inFlight sync.WaitGroup // running Run, RunBlock and Stream calls
+	stopping chan struct{}  // closed by Shutdown to stop streams taking blocks
+	pause    *pauseGate     // holds up streams taking blocks while paused
+	abandon  chan struct{}  // closed when a Shutdown deadline passes, to stop delivering results
+
+	abandonOnce sync.Once
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// Option configures an Executor
+type Option func(*Executor)
+
+// WithWorkers sets the number of workers executing each block's transactions, which must be
+// at least 1. It defaults to GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(e *Executor) { e.numWorkers = n }
+}
+
+// WithMode sets how blocks handle failed transactions, SkipFailed by default
+func WithMode(mode ExecutionMode) Option {
+	return func(e *Executor) { e.opts.Mode = mode }
+}
+
+// WithObserver sets an observer notified of the progress of every block. It is shared by
+// concurrent calls and must be safe for concurrent use if the executor is.
+func WithObserver(observer ExecutionObserver) Option {
+	return func(e *Executor) { e.opts.Observer = observer }
+}
+
+// WithLogger sets the logger recording the progress of every block, see BlockOptions.Logger.
+// Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Executor) { e.opts.Logger = logger }
+}
+
+// WithRetry sets the policy re-executing transactions that fail with a Retryable error.
+// Retries are disabled by default, and can't be combined with AbortOnError mode.
+func WithRetry(policy RetryPolicy) Option {
+	return func(e *Executor) { e.opts.Retry = policy }
+}
+
+// WithTimeout bounds how long each transaction's Updates may run, see BlockOptions.TxTimeout.
+// Transactions aren't bounded by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Executor) { e.opts.TxTimeout = d }
+}
+
+// WithFairScheduling interleaves the transactions of different senders, see
+// BlockOptions.FairScheduling
+func WithFairScheduling() Option {
+	return func(e *Executor) { e.opts.FairScheduling = true }
+}
+
+// WithDeterministicDispatch assigns transactions to workers by index, see
+// BlockOptions.DeterministicDispatch
+func WithDeterministicDispatch() Option {
+	return func(e *Executor) { e.opts.DeterministicDispatch = true }
+}
+
+// WithDeduplication executes repeated Hashable transactions of a block once, see
+// BlockOptions.Deduplicate
+func WithDeduplication() Option {
+	return func(e *Executor) { e.opts.Deduplicate = true }
+}
+
+// WithMaxTransactions rejects blocks with more than n transactions, see BlockOptions.MaxTransactions
+func WithMaxTransactions(n int) Option {
+	return func(e *Executor) { e.opts.MaxTransactions = n }
+}
+
+// WithRateLimit caps transaction dispatches per second, see BlockOptions.RateLimit
+func WithRateLimit(perSecond float64) Option {
+	return func(e *Executor) { e.opts.RateLimit = perSecond }
+}
+
+// WithUpdateTransformer adds a transformer after those already added, see BlockOptions.Transformers
+func WithUpdateTransformer(transform UpdateTransformer) Option {
+	return func(e *Executor) { e.opts.Transformers = append(e.opts.Transformers, transform) }
+}
+
+// WithBlockCache makes RunBlock idempotent for the n most recently run blocks: re-submitting a
+// block with the same BlockHash as one of them, against a state with the StateRoot it had
+// before or after that run, returns its result again instead of applying it twice. Computing
+// the root takes a snapshot of the state; states without GetSnapshot are keyed by the block
+// alone. Only blocks that succeeded are remembered, blocks that can't be hashed fail RunBlock,
+// and identical blocks submitted concurrently against the same state run once.
+func WithBlockCache(n int) Option {
+	return func(e *Executor) { e.cacheSize = n }
+}
+
+// WithCheckpointInterval keeps a snapshot of the state every n blocks run with Run or Stream,
+// starting with the initial state, to be queried with SnapshotAt. Checkpoints are kept for the
+// lifetime of the executor, so n bounds their memory use. Checkpointing is disabled by default.
+func WithCheckpointInterval(n int) Option {
+	return func(e *Executor) { e.interval = n }
+}
+
+// NewExecutor returns an executor configured by the given options, applied in order. It fails
+// with ErrInvalidWorkerCount for fewer than one worker and with ErrInvalidOption for other
+// invalid or conflicting options.
+func NewExecutor(options ...Option) (*Executor, error) {
+	e := &Executor{
+		numWorkers: runtime.GOMAXPROCS(0),
+		stopping:   make(chan struct{}),
+		pause:      newPauseGate(),
+		abandon:    make(chan struct{}),
+	}
+	for _, option := range options {
+		option(e)
+	}
+
+	if e.numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, e.numWorkers)
+	}
+	if err := e.opts.validate(); err != nil {
+		return nil, err
+	}
+	if e.cacheSize < 0 {
+		return nil, fmt.Errorf("%w: negative block cache size %d", ErrInvalidOption, e.cacheSize)
+	}
+	if e.interval < 0 {
+		return nil, fmt.Errorf("%w: negative checkpoint interval %d", ErrInvalidOption, e.interval)
+	}
+	if e.cacheSize > 0 {
+		e.cache = newBlockCache(e.cacheSize)
+	}
+	if e.interval > 0 {
+		e.history = newSnapshotHistory(e.interval)
+	}
+	return e, nil
+}
+
+// begin registers a call in flight, failing with ErrExecutorClosed once Shutdown was called
+func (e *Executor) begin() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrExecutorClosed
+	}
+	e.inFlight.Add(1)
+	return nil
+}
+
+// RunBlock executes a block against state like ExecuteBlockWithOptions. With WithBlockCache,
+// a block that already ran returns its earlier result without being applied again.
+func (e *Executor) RunBlock(block Block, state AccountState) ([]AccountValue, BlockResult, error) {
+	if err := e.begin(); err != nil {
+		return nil, BlockResult{}, err
+	}
+	defer e.inFlight.Done()
+
+	run := func() ([]AccountValue, BlockResult, error) {
+		return ExecuteBlockWithOptions(context.Background(), block, state, e.numWorkers, e.opts)
+	}
+	if e.cache == nil {
+		return run()
+	}
+	hash, err := BlockHash(block)
+	if err != nil {
+		return nil, BlockResult{}, fmt.Errorf("hash block: %w", err)
+	}
+	root := func() [32]byte {
+		if snapshotter, ok := state.(snapshotState); ok {
+			return StateRoot(snapshotter.GetSnapshot())
+		}
+		return [32]byte{}
+	}
+	return e.cache.do(hash, root, run)
+}
+
+// Run processes multiple blocks sequentially, starting from initialState, and returns the
+// final account state like Start
+func (e *Executor) Run(blocks []Block, initialState []AccountValue) ([]AccountValue, error) {
+	if err := e.begin(); err != nil {
+		return nil, err
+	}
+	defer e.inFlight.Done()
+
+	snapshot, _, err := runBlocks(context.Background(), blocks, NewInMemoryAccountState(initialState), e.numWorkers, e.opts, e.history)
+	return snapshot, err
+}
+
+// Stream executes blocks as they arrive on the blocks channel against state and emits the
+// result of each one on the returned channel, in order. The returned channel is closed once
+// blocks is closed and every result has been received, or once Shutdown stops the stream.
+// A block that fails stops the stream too: its result is emitted with Err set, and the rest
+// of blocks is received and discarded until it's closed or Shutdown is called.
+// If state implements io.Closer, Shutdown closes it after the stream has stopped. Pause holds
+// streams up without stopping them.
+func (e *Executor) Stream(blocks <-chan Block, state AccountState) (<-chan BlockResult, error) {
+	if err := e.begin(); err != nil {
+		return nil, err
+	}
+	if closer, ok := state.(io.Closer); ok {
+		e.mu.Lock()
+		e.closers = append(e.closers, closer)
+		e.mu.Unlock()
+	}
+
+	// Only states that can be snapshotted are checkpointed
+	var history *snapshotHistory
+	if snapshotter, ok := state.(snapshotState); ok && e.history != nil {
+		history = e.history
+		history.record(0, snapshotter.GetSnapshot())
+	}
+
+	results := make(chan BlockResult)
+	go func() {
+		defer e.inFlight.Done()
+		streamBlocks(context.Background(), blocks, state, e.numWorkers, e.opts, results, history, e.pause, e.stopping, e.abandon)
+	}()
+	return results, nil
+}
+
+// SnapshotAt returns the state as of the given block height, the state after that many blocks
+// of the most recent Run or Stream call to reach it, for light-client style queries. It fails
+// with ErrCheckpointNotFound unless the height was checkpointed, see WithCheckpointInterval.
+// Streams over states that don't implement GetSnapshot aren't checkpointed.
+func (e *Executor) SnapshotAt(height int) ([]AccountValue, error) {
+	return e.history.at(height)
+}
+
+// Pause stops the executor's streams taking new blocks, e.g. to relieve a consumer that can't
+// keep up, without stopping them: a block being executed finishes and its result is delivered,
+// but blocks are left on their channels until Resume is called. Pausing a paused executor has
+// no effect. Shutdown stops paused streams as usual.
+func (e *Executor) Pause() {
+	e.pause.pause()
+}
+
+// Resume lets streams paused by Pause take blocks again
+func (e *Executor) Resume() {
+	e.pause.resume()
+}
+
+// pauseGate tracks whether an executor is paused, for streams to wait while it is
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  chan struct{} // closed while paused
+	resumed chan struct{} // closed while not paused
+}
+
+func newPauseGate() *pauseGate {
+	g := &pauseGate{paused: make(chan struct{}), resumed: make(chan struct{})}
+	close(g.resumed)
+	return g
+}
+
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.paused:
+	default:
+		close(g.paused)
+		g.resumed = make(chan struct{})
+	}
+}
+
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.resumed:
+	default:
+		close(g.resumed)
+		g.paused = make(chan struct{})
+	}
+}
+
+// state returns channels closed once the gate is paused and resumed respectively; one of them
+// is already closed
+func (g *pauseGate) state() (paused, resumed <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused, g.resumed
+}
+
+// Shutdown stops the executor accepting new calls and its streams taking new blocks, then
+// waits for every block being executed to finish and for streams to deliver their results.
+// Once they have, it closes the states of streams that implement io.Closer, flushing
+// persistent state. If ctx is done first, Shutdown returns ctx.Err() without closing them and
+// streams stop delivering results; the blocks still run to completion, and a later Shutdown
+// call waits for them.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.stopping)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		e.abandonOnce.Do(func() { close(e.abandon) })
+		return ctx.Err()
+	}
+
+	e.closeOnce.Do(func() {
+		for _, closer := range e.closers {
+			if err := closer.Close(); err != nil && e.closeErr == nil {
+				e.closeErr = err
+			}
+		}
+	})
+	return e.closeErr
+}
+
+// validate checks the options for invalid values and combinations
+func (o BlockOptions) validate() error {
+	switch {
+	case o.MaxTransactions < 0:
+		return fmt.Errorf("%w: negative transaction limit %d", ErrInvalidOption, o.MaxTransactions)
+	case o.RateLimit < 0:
+		return fmt.Errorf("%w: negative rate limit %v", ErrInvalidOption, o.RateLimit)
+	case o.TxTimeout < 0:
+		return fmt.Errorf("%w: negative transaction timeout %v", ErrInvalidOption, o.TxTimeout)
+	case o.Retry.MaxAttempts < 0 || o.Retry.Backoff < 0:
+		return fmt.Errorf("%w: negative retry policy %+v", ErrInvalidOption, o.Retry)
+	case o.Retry.MaxAttempts > 1 && o.Mode == AbortOnError:
+		// AbortOnError stops a block at its first failure, which retries with backoff would delay
+		return fmt.Errorf("%w: retries can't be combined with AbortOnError mode", ErrInvalidOption)
+	}
+	return nil
+}
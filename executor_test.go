@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closingState records whether it was closed
+type closingState struct {
+	*InMemoryAccountState
+	closed bool
+}
+
+func (s *closingState) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestExecutor_Options(t *testing.T) {
+	observer := newRecordingObserver()
+	executor, err := NewExecutor(WithWorkers(2), WithMode(AbortOnError), WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 50}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		transfer{from: "A", to: "C", value: 30}, // fails and stops the block
+		transfer{from: "A", to: "D", value: 10},
+	}}
+	_, result, err := executor.RunBlock(block, state)
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || txErr.Index != 1 {
+		t.Fatalf("Expected transaction 1 to stop the block, got %v", err)
+	}
+	if result.Transactions[2].Applied {
+		t.Error("Expected the block to stop at the failed transaction")
+	}
+	if _, ok := observer.applied[0]; !ok {
+		t.Error("Expected the observer to see transaction 0 applied")
+	}
+	if _, ok := observer.failed[1]; !ok {
+		t.Error("Expected the observer to see transaction 1 fail")
+	}
+
+	accounts, err := executor.Run([]Block{{Transactions: block.Transactions[:1]}}, []AccountValue{{Name: "A", Balance: 50}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	verifyResults(t, accounts, map[string]uint{"A": 20, "B": 30})
+}
+
+func TestNewExecutor_InvalidWorkerCount(t *testing.T) {
+	if _, err := NewExecutor(WithWorkers(0)); !errors.Is(err, ErrInvalidWorkerCount) {
+		t.Errorf("Expected ErrInvalidWorkerCount, got %v", err)
+	}
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatalf("NewExecutor with defaults failed: %v", err)
+	}
+	if executor.numWorkers < 1 {
+		t.Errorf("Expected at least one worker by default, got %d", executor.numWorkers)
+	}
+}
+
+func TestNewExecutor_AppliesOptions(t *testing.T) {
+	retry := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	executor, err := NewExecutor(WithWorkers(1), WithWorkers(3), WithRetry(retry), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	if executor.numWorkers != 3 {
+		t.Errorf("Expected the last WithWorkers to win, got %d workers", executor.numWorkers)
+	}
+	if executor.opts.Retry != retry || executor.opts.TxTimeout != time.Second {
+		t.Errorf("Options not applied: %+v", executor.opts)
+	}
+	if executor.opts.Mode != SkipFailed || executor.opts.Observer != nil {
+		t.Errorf("Expected default mode and no observer, got %+v", executor.opts)
+	}
+
+	// Options reach the executed blocks: the flaky transfer succeeds on its third attempt
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+	flaky := flakyTransfer{transfer{from: "A", to: "B", value: 10}, 2, transientError{}, &atomic.Int32{}}
+	if _, result, err := executor.RunBlock(Block{Transactions: []Transaction{flaky}}, state); err != nil || !result.Transactions[0].Applied {
+		t.Fatalf("Expected the retried transaction to be applied, got %+v, %v", result, err)
+	}
+}
+
+func TestNewExecutor_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"retry with abort on error", []Option{WithMode(AbortOnError), WithRetry(RetryPolicy{MaxAttempts: 2})}},
+		{"negative timeout", []Option{WithTimeout(-time.Second)}},
+		{"negative backoff", []Option{WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: -1})}},
+		{"negative rate limit", []Option{WithRateLimit(-1)}},
+		{"negative block cache", []Option{WithBlockCache(-1)}},
+		{"negative checkpoint interval", []Option{WithCheckpointInterval(-1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewExecutor(tt.options...); !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("Expected ErrInvalidOption, got %v", err)
+			}
+		})
+	}
+
+	// A single attempt doesn't retry, so it doesn't conflict with AbortOnError
+	if _, err := NewExecutor(WithMode(AbortOnError), WithRetry(RetryPolicy{MaxAttempts: 1})); err != nil {
+		t.Errorf("Expected a single-attempt policy to be accepted, got %v", err)
+	}
+}
+
+func TestExecutor_ClosedAfterShutdown(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	if err := executor.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	state := NewInMemoryAccountState(nil)
+	if _, _, err := executor.RunBlock(Block{}, state); !errors.Is(err, ErrExecutorClosed) {
+		t.Errorf("Expected ErrExecutorClosed from RunBlock, got %v", err)
+	}
+	if _, err := executor.Stream(make(chan Block), state); !errors.Is(err, ErrExecutorClosed) {
+		t.Errorf("Expected ErrExecutorClosed from Stream, got %v", err)
+	}
+}
+
+func TestExecutor_ShutdownFinishesInFlightBlock(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	blocks := make(chan Block, 2)
+	blocks <- Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		cancellingTransfer{transfer{from: "A", to: "C", value: 10}, func() { close(started) }},
+		blockingTransfer{transfer{from: "A", to: "D", value: 10}, release},
+	}}
+	blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "E", value: 10}}}
+
+	state := &closingState{InMemoryAccountState: NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})}
+	executor, err := NewExecutor(WithWorkers(1))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	results, err := executor.Stream(blocks, state)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	<-started
+	shutdown := make(chan error)
+	go func() { shutdown <- executor.Shutdown(context.Background()) }()
+
+	// Once Shutdown stopped accepting calls, it waits for the first block, which can't finish yet
+	for {
+		if _, _, err := executor.RunBlock(Block{}, state); errors.Is(err, ErrExecutorClosed) {
+			break
+		}
+		runtime.Gosched()
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the in-flight block finished: %v", err)
+	default:
+	}
+	close(release)
+
+	var collected []BlockResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if len(collected) != 1 {
+		t.Fatalf("Expected only the in-flight block's result, got %d results", len(collected))
+	}
+	for _, tx := range collected[0].Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d of the in-flight block wasn't applied: %v", tx.Index, tx.Err)
+		}
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 70, "B": 10, "C": 10, "D": 10})
+	if !state.closed {
+		t.Error("Expected Shutdown to close the state")
+	}
+}
+
+func TestExecutor_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocks := make(chan Block, 1)
+	blocks <- Block{Transactions: []Transaction{
+		cancellingTransfer{transfer{from: "A", to: "B", value: 10}, func() { close(started) }},
+		blockingTransfer{transfer{from: "A", to: "C", value: 10}, release},
+	}}
+
+	state := &closingState{InMemoryAccountState: NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})}
+	executor, err := NewExecutor(WithWorkers(2))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	if _, err := executor.Stream(blocks, state); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := executor.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if state.closed {
+		t.Error("State closed while a block was still executing")
+	}
+
+	// The block still completes in full, and a second Shutdown waits for it
+	close(release)
+	if err := executor.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Second Shutdown failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 80, "B": 10, "C": 10})
+	if !state.closed {
+		t.Error("Expected the second Shutdown to close the state")
+	}
+}
+
+func TestExecutor_BlockCache(t *testing.T) {
+	executor, err := NewExecutor(WithWorkers(2), WithBlockCache(1))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 30}}}
+
+	for i := 0; i < 2; i++ {
+		snapshot, result, err := executor.RunBlock(block, state)
+		if err != nil {
+			t.Fatalf("RunBlock %d failed: %v", i, err)
+		}
+		if !result.Transactions[0].Applied {
+			t.Errorf("RunBlock %d: expected the cached result to report the transfer applied", i)
+		}
+		verifyResults(t, snapshot, map[string]uint{"A": 70, "B": 30})
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 70, "B": 30})
+
+	// Another block evicts the first from a cache of one, so it applies again
+	if _, _, err := executor.RunBlock(Block{Transactions: []Transaction{transfer{from: "B", to: "C", value: 10}}}, state); err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	if _, _, err := executor.RunBlock(block, state); err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 40, "B": 50, "C": 10})
+
+	// Blocks that can't be hashed are rejected rather than risk running twice
+	if _, _, err := executor.RunBlock(Block{Transactions: []Transaction{cancellingTransfer{}}}, state); !errors.Is(err, ErrUnknownTransactionType) {
+		t.Errorf("Expected ErrUnknownTransactionType, got %v", err)
+	}
+}
+
+func TestExecutor_BlockCacheKeyedByState(t *testing.T) {
+	executor, err := NewExecutor(WithWorkers(2), WithBlockCache(4))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	block := Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 30}}}
+
+	first := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	_, result, err := executor.RunBlock(block, first)
+	if err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	result.Transactions[0].Updates[0].BalanceChange = 1000
+
+	// The same block against another state runs against it
+	second := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 50}})
+	snapshot, _, err := executor.RunBlock(block, second)
+	if err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 20, "B": 30})
+
+	// Modifying a returned result doesn't modify the cached one
+	_, result, err = executor.RunBlock(block, first)
+	if err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	if change := result.Transactions[0].Updates[0].BalanceChange; change != -30 {
+		t.Errorf("Expected the cached update to debit 30, got %d", change)
+	}
+	verifyResults(t, first.GetSnapshot(), map[string]uint{"A": 70, "B": 30})
+}
+
+func TestExecutor_PauseAndResume(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocks := make(chan Block, 3)
+	blocks <- Block{Transactions: []Transaction{
+		cancellingTransfer{transfer{from: "A", to: "B", value: 10}, func() { close(started) }},
+		blockingTransfer{transfer{from: "A", to: "C", value: 10}, release},
+	}}
+
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	executor, err := NewExecutor(WithWorkers(2))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	results, err := executor.Stream(blocks, state)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	// Pausing lets the in-flight block finish
+	<-started
+	executor.Pause()
+	executor.Pause()
+	close(release)
+	if result := <-results; len(result.Transactions) != 2 {
+		t.Fatalf("Expected the in-flight block's result, got %+v", result)
+	}
+
+	// Blocks fed while paused stay on the channel: the stream was paused before it delivered
+	// the in-flight block's result, so it waits for Resume before taking another block
+	blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "D", value: 10}}}
+	blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "E", value: 10}}}
+	select {
+	case result := <-results:
+		t.Fatalf("Block executed while paused: %+v", result)
+	default:
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected both blocks to be left on the channel, %d are", len(blocks))
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 80, "B": 10, "C": 10})
+
+	executor.Resume()
+	executor.Resume()
+	close(blocks)
+	var collected []BlockResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+	if len(collected) != 2 {
+		t.Fatalf("Expected the two blocks to run once resumed, got %d results", len(collected))
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 60, "B": 10, "C": 10, "D": 10, "E": 10})
+
+	if err := executor.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+}
+
+func TestExecutor_SnapshotAt(t *testing.T) {
+	executor, err := NewExecutor(WithWorkers(2), WithCheckpointInterval(2))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	var blocks []Block
+	for i := 0; i < 5; i++ {
+		blocks = append(blocks, Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 10}}})
+	}
+	if _, err := executor.Run(blocks, []AccountValue{{Name: "A", Balance: 100}}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for height, expected := range map[int]map[string]uint{
+		0: {"A": 100},
+		2: {"A": 80, "B": 20},
+		4: {"A": 60, "B": 40},
+	} {
+		snapshot, err := executor.SnapshotAt(height)
+		if err != nil {
+			t.Fatalf("SnapshotAt(%d) failed: %v", height, err)
+		}
+		verifyResults(t, snapshot, expected)
+	}
+	for _, height := range []int{1, 3, 5, 6, -2} {
+		if _, err := executor.SnapshotAt(height); !errors.Is(err, ErrCheckpointNotFound) {
+			t.Errorf("SnapshotAt(%d): expected ErrCheckpointNotFound, got %v", height, err)
+		}
+	}
+
+	// Streams are checkpointed too, overwriting earlier checkpoints at the same height
+	stream := make(chan Block, 2)
+	stream <- Block{Transactions: []Transaction{transfer{from: "C", to: "D", value: 1}}}
+	stream <- Block{Transactions: []Transaction{transfer{from: "C", to: "D", value: 1}}}
+	close(stream)
+	results, err := executor.Stream(stream, NewInMemoryAccountState([]AccountValue{{Name: "C", Balance: 5}}))
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	for range results {
+	}
+	snapshot, err := executor.SnapshotAt(2)
+	if err != nil {
+		t.Fatalf("SnapshotAt(2) failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"C": 3, "D": 2})
+
+	// Without an interval nothing is checkpointed
+	executor, err = NewExecutor(WithWorkers(2))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	if _, err := executor.Run(blocks, []AccountValue{{Name: "A", Balance: 100}}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, err := executor.SnapshotAt(0); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("Expected ErrCheckpointNotFound with checkpoints disabled, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFixedPoint_Conversions(t *testing.T) {
+	amount, err := ToFixedPoint(1000, 2)
+	if err != nil {
+		t.Fatalf("ToFixedPoint failed: %v", err)
+	}
+	if amount.Units != 100000 || amount.Whole() != 1000 || amount.String() != "1000.00" {
+		t.Errorf("Unexpected conversion of 1000 at scale 2: %+v (%s)", amount, amount)
+	}
+
+	for _, tc := range []struct {
+		amount FixedPoint
+		whole  uint
+		str    string
+	}{
+		{FixedPoint{Units: 1234, Scale: 2}, 12, "12.34"},
+		{FixedPoint{Units: 5, Scale: 3}, 0, "0.005"},
+		{FixedPoint{Units: 42, Scale: 0}, 42, "42"},
+	} {
+		if whole, str := tc.amount.Whole(), tc.amount.String(); whole != tc.whole || str != tc.str {
+			t.Errorf("%+v: expected %d and %q, got %d and %q", tc.amount, tc.whole, tc.str, whole, str)
+		}
+	}
+
+	if _, err := ToFixedPoint(math.MaxUint, 1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if _, err := ToFixedPoint(1, MaxScale+1); !errors.Is(err, ErrInvalidScale) {
+		t.Errorf("Expected ErrInvalidScale, got %v", err)
+	}
+}
+
+func TestAccrueInterest(t *testing.T) {
+	balance := func(whole uint) uint {
+		amount, err := ToFixedPoint(whole, 2)
+		if err != nil {
+			t.Fatalf("ToFixedPoint failed: %v", err)
+		}
+		return amount.Units
+	}
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: balance(1000)},
+		{Name: "B", Balance: 1234}, // 12.34
+		{Name: "C", Balance: 39},   // 0.39, whose interest rounds to nothing
+		{Name: "D", Balance: math.MaxUint},
+	})
+
+	block := NewBlockBuilder().
+		Add(AccrueInterest{Account: "A", Rate: 250}).
+		Add(AccrueInterest{Account: "A", Rate: 250}). // compounds on the first accrual
+		Add(AccrueInterest{Account: "B", Rate: 250}).
+		Add(AccrueInterest{Account: "C", Rate: 250}).
+		Add(AccrueInterest{Account: "D", Rate: 250}).
+		Add(AccrueInterest{Account: "E", Rate: 250}).
+		Build()
+	snapshot, result, err := ExecuteBlock(block, state, 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for i, expected := range []error{nil, nil, nil, nil, ErrOverflow, ErrAccountNotFound} {
+		if r := result.Transactions[i]; !errors.Is(r.Err, expected) || r.Applied != (expected == nil) {
+			t.Errorf("Transaction %d: expected %v, got %+v", i, expected, r)
+		}
+	}
+	if !result.Transactions[3].NoOp {
+		t.Errorf("Expected the accrual rounding to nothing to be a no-op, got %+v", result.Transactions[3])
+	}
+	// 1000.00 * 1.025 = 1025.00, then 1025.00 * 1.025 = 1050.625, rounded down to 1050.62;
+	// 12.34 * 1.025 = 12.6485, rounded down to 12.64
+	verifyResults(t, snapshot, map[string]uint{"A": 105062, "B": 1264, "C": 39, "D": math.MaxUint})
+	if got := (FixedPoint{Units: 105062, Scale: 2}).String(); got != "1050.62" {
+		t.Errorf("Expected 1050.62, got %s", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// GeneratedBalance is the native balance GenerateBlock assumes every account starts with
+const GeneratedBalance uint = 1000
+
+// maxGeneratedAmount bounds the amount of a generated transfer
+const maxGeneratedAmount = 10
+
+// GenerateBlock returns a block of n pseudo-random Transfers between distinct accounts, for
+// load testing and benchmarks. The same accounts, n and seed always yield the same block.
+// Transfers move between 1 and 10 units and never exceed what their sender holds at that
+// point of the block assuming every account starts with GeneratedBalance, so against
+// accounts funded that way every transfer succeeds; against less funded ones, transfers
+// start failing as accounts run dry. With fewer than two accounts, the block is empty.
+func GenerateBlock(accounts []string, n int, seed int64) Block {
+	if len(accounts) < 2 || n <= 0 {
+		return Block{}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	balances := make([]uint, len(accounts))
+	for i := range balances {
+		balances[i] = GeneratedBalance
+	}
+
+	transactions := make([]Transaction, n)
+	for k := range transactions {
+		// Total supply is constant and positive, so some account can always send
+		from := rng.Intn(len(accounts))
+		for balances[from] == 0 {
+			from = (from + 1) % len(accounts)
+		}
+		to := rng.Intn(len(accounts) - 1)
+		if to >= from {
+			to++
+		}
+		amount := uint(1 + rng.Intn(int(min(balances[from], maxGeneratedAmount))))
+
+		balances[from] -= amount
+		balances[to] += amount
+		transactions[k] = Transfer{From: accounts[from], To: accounts[to], Amount: amount}
+	}
+	return Block{Transactions: transactions}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateBlock(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D", "E"}
+
+	block := GenerateBlock(accounts, 200, 42)
+	if !reflect.DeepEqual(block, GenerateBlock(accounts, 200, 42)) {
+		t.Fatalf("Expected the same seed to yield identical blocks")
+	}
+	if reflect.DeepEqual(block, GenerateBlock(accounts, 200, 43)) {
+		t.Errorf("Expected different seeds to yield different blocks")
+	}
+	if len(block.Transactions) != 200 {
+		t.Fatalf("Expected 200 transactions, got %d", len(block.Transactions))
+	}
+
+	initial := make([]AccountValue, len(accounts))
+	for i, name := range accounts {
+		initial[i] = AccountValue{Name: name, Balance: GeneratedBalance}
+	}
+	snapshot, result, err := ExecuteBlock(block, NewInMemoryAccountState(initial), 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d failed: %v", tx.Index, tx.Err)
+		}
+	}
+	var total uint
+	for _, acc := range snapshot {
+		total += acc.Balance
+	}
+	if expected := uint(len(accounts)) * GeneratedBalance; total != expected {
+		t.Errorf("Expected total supply %d, got %d", expected, total)
+	}
+}
+
+func BenchmarkExecuteBlock_GeneratedBlock(b *testing.B) {
+	accounts := make([]string, 100)
+	initial := make([]AccountValue, len(accounts))
+	for i := range accounts {
+		accounts[i] = fmt.Sprintf("account%d", i)
+		initial[i] = AccountValue{Name: accounts[i], Balance: GeneratedBalance}
+	}
+	block := GenerateBlock(accounts, 1000, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ExecuteBlock(block, NewInMemoryAccountState(initial), 8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
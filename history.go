@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// snapshotHistory keeps snapshots of the state taken every interval blocks, by block height:
+// the snapshot at height h is the state after the first h blocks, height 0 being the initial state
+type snapshotHistory struct {
+	interval  int
+	mu        sync.Mutex
+	snapshots map[int][]AccountValue
+}
+
+func newSnapshotHistory(interval int) *snapshotHistory {
+	return &snapshotHistory{interval: interval, snapshots: make(map[int][]AccountValue)}
+}
+
+// record keeps snapshot as the state at height if height is a checkpoint
+func (h *snapshotHistory) record(height int, snapshot []AccountValue) {
+	if h == nil || height%h.interval != 0 {
+		return
+	}
+	snapshot = copySnapshot(snapshot)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.snapshots[height] = snapshot
+}
+
+// at returns a copy of the snapshot recorded at height
+func (h *snapshotHistory) at(height int) ([]AccountValue, error) {
+	if h == nil {
+		return nil, fmt.Errorf("%w: checkpoints are disabled", ErrCheckpointNotFound)
+	}
+	h.mu.Lock()
+	snapshot, ok := h.snapshots[height]
+	h.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: height %d", ErrCheckpointNotFound, height)
+	}
+	return copySnapshot(snapshot), nil
+}
+
+// copySnapshot returns a copy of snapshot that shares no maps with it
+func copySnapshot(snapshot []AccountValue) []AccountValue {
+	copied := make([]AccountValue, len(snapshot))
+	for i, acc := range snapshot {
+		copied[i] = acc
+		if acc.Assets != nil {
+			copied[i].Assets = copyBalances(acc.Assets)
+		}
+		if acc.Metadata != nil {
+			copied[i].Metadata = copyMetadata(acc.Metadata)
+		}
+	}
+	return copied
+}
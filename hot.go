@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// HotAccounts returns the accounts that at least minWriters transactions of a block declare
+// they write, most written first, then by name. Transactions writing the same account can't
+// run concurrently, so hot accounts such as a central treasury serialize a block; see
+// BlockOptions.HotAccounts. Transactions that don't declare an access set aren't counted.
+func HotAccounts(block Block, minWriters int) []string {
+	writers := make(map[string]int)
+	for _, tx := range block.Transactions {
+		for name := range declaredAccessSet(tx).writes {
+			writers[name]++
+		}
+	}
+
+	var hot []string
+	for name, n := range writers {
+		if n >= minWriters {
+			hot = append(hot, name)
+		}
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if writers[hot[i]] != writers[hot[j]] {
+			return writers[hot[i]] > writers[hot[j]]
+		}
+		return hot[i] < hot[j]
+	})
+	return hot
+}
+
+// hotCredit identifies the balance of an account and asset receiving deferred credits
+type hotCredit struct {
+	name  string
+	asset string
+}
+
+// splitHotCredits separates the credits to hot accounts, which are deferred to the end of the
+// block, from the updates to apply right away
+func (r *blockRun) splitHotCredits(updates []AccountUpdate) (immediate, deferred []AccountUpdate) {
+	if len(r.hot) == 0 {
+		return updates, nil
+	}
+	for _, update := range updates {
+		name := r.normalize(update.Name)
+		if _, hot := r.hot[name]; hot && update.Op == OpBalanceChange && update.BalanceChange > 0 {
+			update.Name = name
+			deferred = append(deferred, update)
+		} else {
+			immediate = append(immediate, update)
+		}
+	}
+	return immediate, deferred
+}
+
+// checkHotCredits checks, when their transaction commits, that credits returned by
+// splitHotCredits can be applied at the end of the block together with those already pending:
+// that the resulting balances don't overflow and that the state accepts them, e.g. that the
+// accounts aren't frozen. A transaction whose credits fail the check fails without any of its
+// updates being applied, rather than its debits being applied and its credits lost.
+func (r *blockRun) checkHotCredits(credits []AccountUpdate) error {
+	validator, _ := r.state.(updateValidator)
+	pending := make(map[hotCredit]uint, len(credits))
+	for _, update := range credits {
+		key := hotCredit{update.Name, update.Asset}
+		total, ok := pending[key]
+		if !ok {
+			total = r.deferred[key]
+		}
+		balance := r.state.GetAccount(update.Name).AssetBalance(update.Asset)
+		if total > math.MaxUint-balance || uint(update.BalanceChange) > math.MaxUint-balance-total {
+			return fmt.Errorf("%w: account %s with its pending credits", ErrOverflow, update.Name)
+		}
+		total += uint(update.BalanceChange)
+		pending[key] = total
+		if validator != nil {
+			if err := validator.validateUpdate(update, balance+total); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deferCredits adds credits returned by splitHotCredits to the block's pending totals
+func (r *blockRun) deferCredits(credits []AccountUpdate) {
+	for _, update := range credits {
+		r.deferred[hotCredit{update.Name, update.Asset}] += uint(update.BalanceChange)
+	}
+}
+
+// flushHotCredits applies the deferred credits, one combined update per account and asset
+func (r *blockRun) flushHotCredits() error {
+	if len(r.deferred) == 0 {
+		return nil
+	}
+	keys := make([]hotCredit, 0, len(r.deferred))
+	for key := range r.deferred {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].asset < keys[j].asset
+	})
+
+	var updates []AccountUpdate
+	for _, key := range keys {
+		// Split totals too large for a single balance change
+		for total := r.deferred[key]; total > 0; {
+			change := min(total, math.MaxInt)
+			updates = append(updates, AccountUpdate{Name: key.name, Asset: key.asset, BalanceChange: int(change)})
+			total -= change
+		}
+	}
+	clear(r.deferred)
+
+	if err := applyUpdatesTo(r.state, updates, r.opts.BlockIndex, -1); err != nil {
+		return err
+	}
+	r.recordStats(updates)
+	return nil
+}
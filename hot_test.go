@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestExecuteBlock_HotAccounts(t *testing.T) {
+	builder := NewBlockBuilder()
+	for i := 0; i < 1000; i++ {
+		builder.Add(mint{to: "treasury", value: i % 7})
+	}
+	builder.AddTransfer("A", "B", 10)
+	builder.AddTransfer("A", "treasury", 5)
+	block := builder.Build()
+
+	if hot := HotAccounts(block, 2); fmt.Sprint(hot) != "[treasury A]" {
+		t.Errorf("Expected hot accounts [treasury A], got %v", hot)
+	}
+
+	state := &loggingState{state: NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})}
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{HotAccounts: []string{"treasury"}})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	var expected uint = 5
+	for i := 0; i < 1000; i++ {
+		expected += uint(i % 7)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 85, "B": 10, "treasury": expected})
+
+	var treasuryUpdates []AccountUpdate
+	for _, updates := range state.log {
+		for _, update := range updates {
+			if update.Name == "treasury" && update.BalanceChange != 0 {
+				treasuryUpdates = append(treasuryUpdates, update)
+			}
+		}
+	}
+	if len(treasuryUpdates) != 1 || treasuryUpdates[0].BalanceChange != int(expected) {
+		t.Errorf("Expected a single combined treasury update of %d, got %v", expected, treasuryUpdates)
+	}
+	if !result.Transactions[0].Applied || len(result.Transactions[0].Updates) != 1 {
+		t.Errorf("Expected deferred credits reported as applied, got %+v", result.Transactions[0])
+	}
+}
+
+func TestExecuteBlock_HotAccountCreditsCheckedOnCommit(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 100}, {Name: "T"}, {Name: "U", Balance: math.MaxUint - 5}})
+	state.Freeze("T")
+	block := NewBlockBuilder().
+		AddTransfer("A", "T", 10). // T is frozen
+		AddTransfer("A", "T", 20).
+		AddTransfer("A", "U", 3).
+		AddTransfer("B", "U", 3). // overflows U together with the pending credit
+		Build()
+
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{HotAccounts: []string{"T", "U"}})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	for i, expected := range []error{ErrAccountFrozen, ErrAccountFrozen, nil, ErrOverflow} {
+		if r := result.Transactions[i]; !errors.Is(r.Err, expected) || r.Applied != (expected == nil) {
+			t.Errorf("Transaction %d: expected %v, got %+v", i, expected, r)
+		}
+	}
+	// The failed transfers debit nothing
+	verifyResults(t, snapshot, map[string]uint{"A": 97, "B": 100, "T": 0, "U": math.MaxUint - 2})
+}
+
+func TestHotAccounts_BlindWritersDontConflict(t *testing.T) {
+	block := NewBlockBuilder().
+		AddTransfer("A", "T", 10).
+		AddTransfer("B", "T", 10).
+		Add(transfer{from: "T", to: "C", value: 5}). // reads T
+		AddTransfer("D", "T", 10).
+		Build()
+
+	scheduler, err := newBlockScheduler(block, false, []string{"T"}, nil)
+	if err != nil {
+		t.Fatalf("newBlockScheduler failed: %v", err)
+	}
+	expected := [][]int{nil, nil, {0, 1}, {2}}
+	for i, deps := range expected {
+		if fmt.Sprint(scheduler.deps[i]) != fmt.Sprint(deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, scheduler.deps[i])
+		}
+	}
+
+	// Without hot accounts, every transfer touching T is serialized
+	scheduler, err = newBlockScheduler(block, false, nil, nil)
+	if err != nil {
+		t.Fatalf("newBlockScheduler failed: %v", err)
+	}
+	if fmt.Sprint(scheduler.deps[1]) != "[0]" {
+		t.Errorf("Expected transaction 1 to depend on 0, got %v", scheduler.deps[1])
+	}
+}
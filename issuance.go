@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+func init() {
+	RegisterTransactionType("mint", func() Transaction { return Mint{} })
+	RegisterTransactionType("burn", func() Transaction { return Burn{} })
+}
+
+// Mint is a transaction creating Amount of the native asset in an account, creating the
+// account if it doesn't exist
+type Mint struct {
+	To     string `json:"to"`
+	Amount uint   `json:"amount"`
+}
+
+// AccessSet implements AccessAware
+func (m Mint) AccessSet() ([]string, []string) {
+	return nil, []string{m.To}
+}
+
+// Hash implements Hashable
+func (m Mint) Hash() [32]byte {
+	return contentHash("mint", m)
+}
+
+// Validate implements Validator, checking that the amount fits a balance change
+func (m Mint) Validate(ReadOnlyState) error {
+	if m.Amount > math.MaxInt {
+		return fmt.Errorf("%w: mint amount %d", ErrOverflow, m.Amount)
+	}
+	return nil
+}
+
+// Updates implements Transaction
+func (m Mint) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if err := m.Validate(state); err != nil {
+		return nil, err
+	}
+	return []AccountUpdate{{Name: m.To, BalanceChange: int(m.Amount)}}, nil
+}
+
+// Burn is a transaction destroying Amount of the native asset held by an account. It fails
+// with ErrAccountNotFound if the account doesn't exist and with ErrInsufficientBalance if it
+// holds less than Amount; like any debit, it also fails with ErrBelowMinimum if it would take
+// the account below its minimum balance.
+type Burn struct {
+	From   string `json:"from"`
+	Amount uint   `json:"amount"`
+}
+
+// AccessSet implements AccessAware
+func (b Burn) AccessSet() ([]string, []string) {
+	return []string{b.From}, []string{b.From}
+}
+
+// Hash implements Hashable
+func (b Burn) Hash() [32]byte {
+	return contentHash("burn", b)
+}
+
+// Validate implements Validator, checking that the account exists and the amount fits a
+// balance change
+func (b Burn) Validate(state ReadOnlyState) error {
+	if b.Amount > math.MaxInt {
+		return fmt.Errorf("%w: burn amount %d", ErrOverflow, b.Amount)
+	}
+	if !state.HasAccount(b.From) {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, b.From)
+	}
+	return nil
+}
+
+// Updates implements Transaction
+func (b Burn) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if err := b.Validate(state); err != nil {
+		return nil, err
+	}
+	if balance := state.GetAccount(b.From).Balance; balance < b.Amount {
+		return nil, fmt.Errorf("%w: account %s has %d, needs %d", ErrInsufficientBalance, b.From, balance, b.Amount)
+	}
+	return []AccountUpdate{{Name: b.From, BalanceChange: -int(b.Amount)}}, nil
+}
+
+// SupplyTracker is an ExecutionObserver auditing the issuance of the native asset. Every
+// applied transaction whose balance changes net to a credit counts as minting that amount,
+// and one netting to a debit as burning it, whatever its type, so transfers count as neither.
+// Creating, deleting or setting the balance of an account isn't counted. In atomic and
+// snapshot isolated blocks, transactions are only counted once the block is committed. A
+// tracker is safe for concurrent use and may observe any number of blocks. Once a total
+// overflows, the tracker's accessors fail with ErrOverflow.
+type SupplyTracker struct {
+	mu       sync.Mutex
+	minted   uint
+	burned   uint
+	overflow bool
+}
+
+// OnTransactionStart implements ExecutionObserver
+func (*SupplyTracker) OnTransactionStart(int, string) {}
+
+// OnTransactionApplied implements ExecutionObserver and records the issuance of the transaction
+func (t *SupplyTracker) OnTransactionApplied(_ int, _ string, updates []AccountUpdate) {
+	var credits, debits uint
+	overflow := false
+	for _, update := range updates {
+		if update.Op != OpBalanceChange || update.Asset != NativeAsset {
+			continue
+		}
+		if update.BalanceChange > 0 {
+			credits, overflow = addChecked(credits, uint(update.BalanceChange), overflow)
+		} else {
+			debits, overflow = addChecked(debits, uint(-(update.BalanceChange+1))+1, overflow)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if credits > debits {
+		t.minted, t.overflow = addChecked(t.minted, credits-debits, t.overflow || overflow)
+	} else {
+		t.burned, t.overflow = addChecked(t.burned, debits-credits, t.overflow || overflow)
+	}
+}
+
+// committedOnly implements committedObserver
+func (*SupplyTracker) committedOnly() {}
+
+// addChecked returns a+b and whether it or an earlier sum, as reported by overflow, wrapped around
+func addChecked(a, b uint, overflow bool) (uint, bool) {
+	return a + b, overflow || b > math.MaxUint-a
+}
+
+// OnTransactionFailed implements ExecutionObserver
+func (*SupplyTracker) OnTransactionFailed(int, string, error) {}
+
+// Minted returns the total amount minted
+func (t *SupplyTracker) Minted() (uint, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.overflow {
+		return 0, fmt.Errorf("%w: issuance totals", ErrOverflow)
+	}
+	return t.minted, nil
+}
+
+// Burned returns the total amount burned
+func (t *SupplyTracker) Burned() (uint, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.overflow {
+		return 0, fmt.Errorf("%w: issuance totals", ErrOverflow)
+	}
+	return t.burned, nil
+}
+
+// NetIssuance returns the amount minted minus the amount burned, failing with ErrOverflow if
+// the difference doesn't fit an int
+func (t *SupplyTracker) NetIssuance() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.overflow:
+		return 0, fmt.Errorf("%w: issuance totals", ErrOverflow)
+	case t.minted >= t.burned && t.minted-t.burned <= math.MaxInt:
+		return int(t.minted - t.burned), nil
+	case t.burned > t.minted && t.burned-t.minted-1 <= math.MaxInt:
+		return -int(t.burned-t.minted-1) - 1, nil
+	}
+	return 0, fmt.Errorf("%w: net issuance of %d minted and %d burned", ErrOverflow, t.minted, t.burned)
+}
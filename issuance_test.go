@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMintAndBurn_SupplyTracker(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	state.SetMinimumBalance("A", 90)
+	tracker := &SupplyTracker{}
+
+	block := NewBlockBuilder().
+		Add(Mint{To: "B", Amount: 50}). // creates B
+		Add(Burn{From: "B", Amount: 20}).
+		AddTransfer("B", "A", 10).        // neither mints nor burns
+		Add(Burn{From: "B", Amount: 40}). // exceeds the balance
+		Add(Burn{From: "A", Amount: 25}). // below the minimum
+		Add(Burn{From: "A", Amount: 15}).
+		Add(Burn{From: "C", Amount: 1}). // C doesn't exist
+		Build()
+
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Observer: tracker})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	for i, expected := range []error{nil, nil, nil, ErrInsufficientBalance, ErrBelowMinimum, nil, ErrAccountNotFound} {
+		if r := result.Transactions[i]; !errors.Is(r.Err, expected) || r.Applied != (expected == nil) {
+			t.Errorf("Transaction %d: expected %v, got %+v", i, expected, r)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 95, "B": 20})
+
+	minted, err := tracker.Minted()
+	if err != nil {
+		t.Fatalf("Minted failed: %v", err)
+	}
+	burned, err := tracker.Burned()
+	if err != nil {
+		t.Fatalf("Burned failed: %v", err)
+	}
+	if minted != 50 || burned != 35 {
+		t.Errorf("Expected 50 minted and 35 burned, got %d and %d", minted, burned)
+	}
+	total, err := TotalBalance(snapshot)
+	if err != nil {
+		t.Fatalf("TotalBalance failed: %v", err)
+	}
+	if net, err := tracker.NetIssuance(); err != nil || net != 15 || net != int(total)-100 {
+		t.Errorf("Expected a net issuance of 15 matching the supply change, got %d and %v", net, err)
+	}
+}
+
+func TestSupplyTracker_RolledBackBlocks(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	tracker := &SupplyTracker{}
+	opts := BlockOptions{Observer: tracker, Atomic: true}
+
+	rolledBack := NewBlockBuilder().Add(Mint{To: "B", Amount: 50}).Add(Burn{From: "C", Amount: 1}).Build()
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), rolledBack, state, 2, opts); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected ErrAccountNotFound, got %v", err)
+	}
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), NewBlockBuilder().Add(Mint{To: "B", Amount: 10}).Build(), state, 2, opts); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if minted, err := tracker.Minted(); err != nil || minted != 10 {
+		t.Errorf("Expected only the committed block's 10 minted, got %d and %v", minted, err)
+	}
+}
+
+func TestSupplyTracker_Overflow(t *testing.T) {
+	tracker := &SupplyTracker{}
+	for i := 0; i < 3; i++ {
+		tracker.OnTransactionApplied(i, "", []AccountUpdate{{Name: "A", BalanceChange: math.MaxInt}})
+	}
+
+	if _, err := tracker.Minted(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Minted: expected ErrOverflow, got %v", err)
+	}
+	if _, err := tracker.NetIssuance(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("NetIssuance: expected ErrOverflow, got %v", err)
+	}
+}
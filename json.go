@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var (
+	transactionTypesMu sync.RWMutex
+	transactionTypes   = make(map[string]func() Transaction) // factories keyed by type name
+	transactionNames   = make(map[reflect.Type]string)       // type names keyed by concrete type
+)
+
+// RegisterTransactionType makes a concrete Transaction type available for JSON encoding and
+// decoding under name. factory must return a zero value of the type; it may return either a
+// value or a pointer, and transactions of exactly that Go type are tagged with name when encoded.
+// Registering the same name or type twice, or a nil factory, panics.
+func RegisterTransactionType(name string, factory func() Transaction) {
+	transactionTypesMu.Lock()
+	defer transactionTypesMu.Unlock()
+
+	if factory == nil {
+		panic("RegisterTransactionType: factory is nil")
+	}
+	if _, dup := transactionTypes[name]; dup {
+		panic("RegisterTransactionType: called twice for " + name)
+	}
+
+	typ := reflect.TypeOf(factory())
+	if other, dup := transactionNames[typ]; dup {
+		panic(fmt.Sprintf("RegisterTransactionType: type %v already registered as %s", typ, other))
+	}
+
+	transactionTypes[name] = factory
+	transactionNames[typ] = name
+}
+
+// encodedTransaction is the JSON representation of a transaction, tagged with its registered type
+type encodedTransaction struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// encodedBlock is the JSON representation of a block
+type encodedBlock struct {
+	Transactions []encodedTransaction `json:"transactions"`
+	Dependencies map[int][]int        `json:"dependencies,omitempty"`
+	Groups       [][]int              `json:"groups,omitempty"`
+}
+
+// MarshalJSON encodes the block with every transaction tagged with its registered type name
+func (b Block) MarshalJSON() ([]byte, error) {
+	encoded := encodedBlock{
+		Transactions: make([]encodedTransaction, 0, len(b.Transactions)),
+		Dependencies: b.Dependencies,
+		Groups:       b.Groups,
+	}
+	for i, tx := range b.Transactions {
+		transactionTypesMu.RLock()
+		name, ok := transactionNames[reflect.TypeOf(tx)]
+		transactionTypesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("transaction %d: %w: %T", i, ErrUnknownTransactionType, tx)
+		}
+
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		encoded.Transactions = append(encoded.Transactions, encodedTransaction{Type: name, Data: data})
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a block, reconstructing each transaction from its registered type
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var encoded encodedBlock
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	transactions := make([]Transaction, 0, len(encoded.Transactions))
+	for i, etx := range encoded.Transactions {
+		tx, err := decodeTransaction(etx)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	b.Transactions = transactions
+	b.Dependencies = encoded.Dependencies
+	b.Groups = encoded.Groups
+	return nil
+}
+
+// decodeTransaction reconstructs a transaction of the registered type named by etx
+func decodeTransaction(etx encodedTransaction) (Transaction, error) {
+	transactionTypesMu.RLock()
+	factory, ok := transactionTypes[etx.Type]
+	transactionTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTransactionType, etx.Type)
+	}
+
+	tx := factory()
+	typ := reflect.TypeOf(tx)
+	if typ.Kind() == reflect.Pointer {
+		if err := json.Unmarshal(etx.Data, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+
+	// Value types are decoded through a pointer to a copy of the zero value
+	ptr := reflect.New(typ)
+	ptr.Elem().Set(reflect.ValueOf(tx))
+	if err := json.Unmarshal(etx.Data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface().(Transaction), nil
+}
+
+// EncodeBlock writes the JSON encoding of b to w
+func EncodeBlock(w io.Writer, b Block) error {
+	return json.NewEncoder(w).Encode(b)
+}
+
+// DecodeBlock reads a JSON encoded block from r
+func DecodeBlock(r io.Reader) (Block, error) {
+	var b Block
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return Block{}, fmt.Errorf("decode block: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterTransactionType("test_transfer", func() Transaction { return transfer{} })
+	RegisterTransactionType("test_mint", func() Transaction { return &mint{} })
+}
+
+type transferJSON struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value int    `json:"value"`
+}
+
+func (t transfer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transferJSON{From: t.from, To: t.to, Value: t.value})
+}
+
+func (t *transfer) UnmarshalJSON(data []byte) error {
+	var decoded transferJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*t = transfer{from: decoded.From, to: decoded.To, value: decoded.Value}
+	return nil
+}
+
+type mintJSON struct {
+	To    string `json:"to"`
+	Value int    `json:"value"`
+}
+
+func (m mint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mintJSON{To: m.to, Value: m.value})
+}
+
+func (m *mint) UnmarshalJSON(data []byte) error {
+	var decoded mintJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = mint{to: decoded.To, value: decoded.Value}
+	return nil
+}
+
+func TestEncodeDecodeBlock_RoundTrip(t *testing.T) {
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},
+		&mint{to: "C", value: 7},
+		transfer{from: "B", to: "C", value: 10},
+	}, Dependencies: map[int][]int{1: {0}}}
+
+	var buf bytes.Buffer
+	if err := EncodeBlock(&buf, block); err != nil {
+		t.Fatalf("EncodeBlock failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"type":"test_transfer"`) {
+		t.Errorf("Expected encoded transactions to be tagged with their type, got %s", buf.String())
+	}
+
+	decoded, err := DecodeBlock(&buf)
+	if err != nil {
+		t.Fatalf("DecodeBlock failed: %v", err)
+	}
+	if !reflect.DeepEqual(block, decoded) {
+		t.Errorf("Round trip mismatch:\nexpected %#v\ngot %#v", block, decoded)
+	}
+}
+
+func TestEncodeBlock_UnregisteredType(t *testing.T) {
+	block := Block{Transactions: []Transaction{opaqueTransfer{}}}
+
+	err := EncodeBlock(&bytes.Buffer{}, block)
+	if !errors.Is(err, ErrUnknownTransactionType) {
+		t.Errorf("Expected ErrUnknownTransactionType, got %v", err)
+	}
+}
+
+func TestDecodeBlock_UnknownType(t *testing.T) {
+	_, err := DecodeBlock(strings.NewReader(`{"transactions":[{"type":"bogus","data":{}}]}`))
+	if !errors.Is(err, ErrUnknownTransactionType) {
+		t.Errorf("Expected ErrUnknownTransactionType, got %v", err)
+	}
+}
+
+func TestAccountValue_JSON(t *testing.T) {
+	value := AccountValue{Name: "A", Balance: 10, Assets: map[string]uint{"USD": 5}}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"name":"A","balance":10,"assets":{"USD":5}}` {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+
+	var decoded AccountValue
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(value, decoded) {
+		t.Errorf("Round trip mismatch: expected %+v, got %+v", value, decoded)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+// LedgerEntry records a change to an account's native balance
+type LedgerEntry struct {
+	Account    string
+	Delta      int
+	NewBalance uint
+	// BlockIndex is the BlockOptions.BlockIndex of the block that made the change, and TxIndex
+	// the index of the transaction within it, also for updates buffered by atomic and snapshot
+	// isolated blocks. Both are -1 for updates applied directly through ApplyUpdates, and
+	// TxIndex is -1 for the combined credits to BlockOptions.HotAccounts.
+	BlockIndex int
+	TxIndex    int
+}
+
+// EnableLedger starts recording every change to native balances, retrievable with History.
+// The ledger is disabled by default since it grows with every update.
+func (s *InMemoryAccountState) EnableLedger() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ledger == nil {
+		s.ledger = make(map[string][]LedgerEntry)
+	}
+}
+
+// History returns the recorded changes to an account's native balance, oldest first
+func (s *InMemoryAccountState) History(name string) []LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if normalized, err := s.normalizeName(name); err == nil {
+		name = normalized
+	}
+	return append([]LedgerEntry(nil), s.ledger[name]...)
+}
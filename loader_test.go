@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewInMemoryAccountStateFromReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"csv", FormatCSV, "name,balance\nA,100\nB, 50\nC,0\n"},
+		{"json lines", FormatJSONLines, `{"name":"A","balance":100}` + "\n\n" +
+			`{"name":"B","balance":50}` + "\n" + `{"name":"C","balance":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := NewInMemoryAccountStateFromReader(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("NewInMemoryAccountStateFromReader failed: %v", err)
+			}
+			verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100, "B": 50, "C": 0})
+			if !state.HasAccount("C") {
+				t.Error("Expected zero-balance account C to exist")
+			}
+		})
+	}
+}
+
+func TestNewInMemoryAccountStateFromReader_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		input    string
+		line     string
+		sentinel error
+	}{
+		{"csv invalid balance", FormatCSV, "A,100\nB,lots\n", "line 2:", nil},
+		{"csv negative balance", FormatCSV, "A,100\nB,-5\n", "line 2:", nil},
+		{"csv missing field", FormatCSV, "A,100\nB,1\nC\n", "line 3:", nil},
+		{"csv duplicate", FormatCSV, "A,100\nB,1\nA,5\n", "line 3:", ErrDuplicateAccount},
+		{"json malformed", FormatJSONLines, `{"name":"A","balance":1}` + "\n" + `{"name":"B",`, "line 2:", nil},
+		{"json duplicate", FormatJSONLines, `{"name":"A","balance":1}` + "\n\n" + `{"name":"A","balance":2}`, "line 3:", ErrDuplicateAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewInMemoryAccountStateFromReader(strings.NewReader(tt.input), tt.format)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.line) {
+				t.Errorf("Expected error starting with %q, got %v", tt.line, err)
+			}
+			if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+				t.Errorf("Expected %v, got %v", tt.sentinel, err)
+			}
+		})
+	}
+}
+
+func TestNewInMemoryAccountStateFromReader_DuplicateMatchesStrict(t *testing.T) {
+	_, strictErr := NewInMemoryAccountStateStrict([]AccountValue{{Name: "A", Balance: 1}, {Name: "A", Balance: 2}})
+	_, err := NewInMemoryAccountStateFromReader(strings.NewReader("A,1\nA,2\n"), FormatCSV)
+	if !errors.Is(strictErr, ErrDuplicateAccount) || !errors.Is(err, ErrDuplicateAccount) {
+		t.Fatalf("Expected both constructors to fail with ErrDuplicateAccount, got %v and %v", strictErr, err)
+	}
+	if errors.Is(err, ErrAccountExists) {
+		t.Errorf("Expected a duplicate initial account not to be reported as ErrAccountExists, got %v", err)
+	}
+}
+
+func TestWriteSnapshot_RoundTrip(t *testing.T) {
+	snapshot := []AccountValue{
+		{Name: "C", Balance: 0},
+		{Name: "A", Balance: 100},
+		{Name: "B, Inc.", Balance: 50},
+	}
+
+	for _, format := range []Format{FormatCSV, FormatJSONLines} {
+		var buf strings.Builder
+		if err := WriteSnapshot(&buf, snapshot, format); err != nil {
+			t.Fatalf("Format %d: WriteSnapshot failed: %v", format, err)
+		}
+		state, err := NewInMemoryAccountStateFromReader(strings.NewReader(buf.String()), format)
+		if err != nil {
+			t.Fatalf("Format %d: reading back failed: %v\n%s", format, err, buf.String())
+		}
+		if imported := state.GetSnapshot(); !reflect.DeepEqual(imported, []AccountValue{snapshot[1], snapshot[2], snapshot[0]}) {
+			t.Errorf("Format %d: expected %+v, got %+v", format, snapshot, imported)
+		}
+	}
+
+	// JSON lines also preserve assets and metadata
+	rich := []AccountValue{{Name: "A", Balance: 1, Assets: map[string]uint{"gold": 2}, Metadata: map[string]string{"owner": "ann"}}}
+	var buf strings.Builder
+	if err := WriteSnapshot(&buf, rich, FormatJSONLines); err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+	state, err := NewInMemoryAccountStateFromReader(strings.NewReader(buf.String()), FormatJSONLines)
+	if err != nil {
+		t.Fatalf("Reading back failed: %v", err)
+	}
+	if imported := state.GetSnapshot(); !reflect.DeepEqual(imported, rich) {
+		t.Errorf("Expected %+v, got %+v", rich, imported)
+	}
+}
+
+func TestWriteSnapshot_Empty(t *testing.T) {
+	var csvOut, jsonOut strings.Builder
+	if err := WriteSnapshot(&csvOut, nil, FormatCSV); err != nil {
+		t.Fatalf("WriteSnapshot CSV failed: %v", err)
+	}
+	if err := WriteSnapshot(&jsonOut, nil, FormatJSONLines); err != nil {
+		t.Fatalf("WriteSnapshot JSON lines failed: %v", err)
+	}
+	if csvOut.String() != "name,balance\n" {
+		t.Errorf("Expected only the CSV header, got %q", csvOut.String())
+	}
+	if jsonOut.String() != "" {
+		t.Errorf("Expected no JSON output, got %q", jsonOut.String())
+	}
+}
+
+func TestNewInMemoryAccountStateFromReader_UnknownFormat(t *testing.T) {
+	if _, err := NewInMemoryAccountStateFromReader(strings.NewReader(""), Format(99)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestWriteSnapshot_CSVRejectsUntrimmedNames(t *testing.T) {
+	snapshot := []AccountValue{{Name: "A", Balance: 1}, {Name: " B", Balance: 2}}
+
+	var out strings.Builder
+	if err := WriteSnapshot(&out, snapshot, FormatCSV); !errors.Is(err, ErrInvalidAccountName) {
+		t.Fatalf("Expected ErrInvalidAccountName, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+	if err := WriteSnapshot(&out, snapshot, FormatJSONLines); err != nil {
+		t.Errorf("Expected JSON lines to keep the name, got %v", err)
+	}
+}
+
+func TestWriteSnapshot_UnknownFormat(t *testing.T) {
+	if err := WriteSnapshot(io.Discard, nil, Format(99)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+)
+
+// logObserver logs the outcome of each transaction of a block to logger, successes at debug
+// level and failures at warn level, before forwarding the callback to next
+type logObserver struct {
+	logger       *slog.Logger
+	block        int
+	transactions []Transaction
+	next         ExecutionObserver
+}
+
+func (o logObserver) OnTransactionStart(index int, id string) {
+	o.next.OnTransactionStart(index, id)
+}
+
+func (o logObserver) OnTransactionApplied(index int, id string, updates []AccountUpdate) {
+	o.logger.Debug("transaction applied",
+		"block", o.block, "tx", index, "id", id, "accounts", updatedAccounts(updates))
+	o.next.OnTransactionApplied(index, id, updates)
+}
+
+func (o logObserver) OnTransactionFailed(index int, id string, err error) {
+	attrs := []any{"block", o.block, "tx", index, "id", id}
+	if sourced, ok := o.transactions[index].(Sourced); ok {
+		attrs = append(attrs, "account", sourced.Sender())
+	}
+	o.logger.Warn("transaction failed", append(attrs, "error", err)...)
+	o.next.OnTransactionFailed(index, id, err)
+}
+
+// updatedAccounts returns the names of the accounts updated by updates, sorted
+func updatedAccounts(updates []AccountUpdate) []string {
+	seen := make(map[string]struct{}, len(updates))
+	var names []string
+	for _, update := range updates {
+		if _, ok := seen[update.Name]; !ok {
+			seen[update.Name] = struct{}{}
+			names = append(names, update.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
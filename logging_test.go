@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// captureHandler records every log record it handles
+type captureHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler      { return h }
+
+// recordAttrs returns the attributes of record by key
+func recordAttrs(record slog.Record) map[string]slog.Value {
+	attrs := make(map[string]slog.Value)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value
+		return true
+	})
+	return attrs
+}
+
+func TestExecuteBlock_LoggerWarnsOnFailure(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 10}}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},
+		sourcedTransfer{transfer{from: "A", to: "C", value: 50}},
+	}}
+
+	handler := &captureHandler{}
+	opts := BlockOptions{Logger: slog.New(handler), BlockIndex: 3}
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), 2, opts); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	var messages []string
+	var warning *slog.Record
+	for i, record := range handler.records {
+		messages = append(messages, record.Message)
+		if record.Level == slog.LevelWarn {
+			if warning != nil {
+				t.Fatalf("Expected one warning, got %q and %q", warning.Message, record.Message)
+			}
+			warning = &handler.records[i]
+		}
+	}
+	expected := []string{"block started", "transaction applied", "transaction failed", "block finished"}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected messages %q, got %q", expected, messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Fatalf("Expected messages %q, got %q", expected, messages)
+		}
+	}
+
+	if warning == nil {
+		t.Fatal("Expected a warning for the failed transaction")
+	}
+	attrs := recordAttrs(*warning)
+	if attrs["block"].Int64() != 3 || attrs["tx"].Int64() != 1 || attrs["account"].String() != "A" {
+		t.Errorf("Unexpected warning attributes %v", attrs)
+	}
+	if err, ok := attrs["error"].Any().(error); !ok || !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance to be logged, got %v", attrs["error"])
+	}
+}
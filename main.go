@@ -1,17 +1,155 @@
 package main
 
 import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
-// Start processes multiple blocks sequentially and returns the final account state
+// Start processes multiple blocks sequentially and returns the final account state.
+// numWorkers must be at least 1, otherwise ErrInvalidWorkerCount is returned. It is a shorthand
+// for Executor.Run with the given number of workers.
 func Start(blocks []Block, initialState []AccountValue, numWorkers int) ([]AccountValue, error) {
-	state := NewInMemoryAccountState(initialState)
+	executor, err := NewExecutor(WithWorkers(numWorkers))
+	if err != nil {
+		return nil, err
+	}
+	return executor.Run(blocks, initialState)
+}
+
+// StartContext is like Start but stops processing and returns ctx.Err() once ctx is done
+func StartContext(ctx context.Context, blocks []Block, initialState []AccountValue, numWorkers int) ([]AccountValue, error) {
+	snapshot, _, err := StartWithResults(ctx, blocks, initialState, numWorkers)
+	return snapshot, err
+}
+
+// StartWithResults is like StartContext but also returns the per-transaction results of every
+// executed block. On error, the results of the blocks executed so far are still returned.
+func StartWithResults(ctx context.Context, blocks []Block, initialState []AccountValue, numWorkers int) ([]AccountValue, []BlockResult, error) {
+	if numWorkers < 1 {
+		return nil, nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+	return runBlocks(ctx, blocks, NewInMemoryAccountState(initialState), numWorkers, BlockOptions{}, nil)
+}
+
+// StartWithState is like Start but executes the blocks against state, which can be any
+// backend, such as a BoltAccountState or a COWAccountState, and returns its final snapshot.
+// state must implement GetSnapshot, otherwise ErrUnsupportedOperation is returned.
+func StartWithState(blocks []Block, state AccountState, numWorkers int) ([]AccountValue, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+	snapshotter, ok := state.(snapshotState)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T doesn't implement GetSnapshot", ErrUnsupportedOperation, state)
+	}
+	snapshot, _, err := runBlocks(context.Background(), blocks, snapshotter, numWorkers, BlockOptions{}, nil)
+	return snapshot, err
+}
+
+// snapshotState is an account state that can return a snapshot of all its accounts
+type snapshotState interface {
+	AccountState
+	GetSnapshot() []AccountValue
+}
+
+// runBlocks executes blocks sequentially against state with opts, numbering them in BlockIndex,
+// and records checkpoints in history, which may be nil
+func runBlocks(ctx context.Context, blocks []Block, state snapshotState, numWorkers int, opts BlockOptions,
+	history *snapshotHistory) ([]AccountValue, []BlockResult, error) {
+	results := make([]BlockResult, 0, len(blocks))
+	if history != nil {
+		history.record(0, state.GetSnapshot())
+	}
 
 	// Process each block sequentially
-	for _, block := range blocks {
-		if _, err := ExecuteBlock(block, state, numWorkers); err != nil {
-			return nil, err
+	for i, block := range blocks {
+		opts.BlockIndex = i
+		snapshot, result, err := ExecuteBlockWithOptions(ctx, block, state, numWorkers, opts)
+		results = append(results, result)
+		if err != nil {
+			return nil, results, err
+		}
+		history.record(i+1, snapshot)
+	}
+
+	return state.GetSnapshot(), results, nil
+}
+
+// StartAtomic processes multiple blocks sequentially and commits them together or not at all.
+// Each block stops at its first failed transaction, as in AbortOnError mode, and if any block
+// fails the state is restored to a checkpoint taken before the first block; the initial state
+// is then returned together with the error.
+func StartAtomic(blocks []Block, initialState []AccountValue, numWorkers int) ([]AccountValue, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+
+	state := NewInMemoryAccountState(initialState)
+	checkpoint := state.Checkpoint()
+
+	for i, block := range blocks {
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, numWorkers, BlockOptions{Mode: AbortOnError, BlockIndex: i}); err != nil {
+			state.Restore(checkpoint)
+			return state.getSnapshot(), fmt.Errorf("block %d: %w", i, err)
+		}
+	}
+
+	return state.getSnapshot(), nil
+}
+
+// BlockSnapshot describes the state after a block was executed
+type BlockSnapshot struct {
+	// Accounts holds the account state after the block
+	Accounts []AccountValue
+	// Result holds the per-transaction results of the block
+	Result BlockResult
+}
+
+// StartDetailed is like Start but returns the account state after every block rather than
+// only the final one. On error, the snapshots of the blocks executed before the failing one
+// are still returned.
+func StartDetailed(blocks []Block, initialState []AccountValue, numWorkers int) ([]BlockSnapshot, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+
+	state := NewInMemoryAccountState(initialState)
+	snapshots := make([]BlockSnapshot, 0, len(blocks))
+
+	for i, block := range blocks {
+		accounts, result, err := ExecuteBlockWithOptions(context.Background(), block, state, numWorkers, BlockOptions{BlockIndex: i})
+		if err != nil {
+			return snapshots, fmt.Errorf("block %d: %w", i, err)
+		}
+		snapshots = append(snapshots, BlockSnapshot{Accounts: accounts, Result: result})
+	}
+
+	return snapshots, nil
+}
+
+// StartWithSnapshotAt is like Start but stops after block atBlock and returns the state at that
+// point, without executing the blocks after it. atBlock must index one of blocks, otherwise
+// ErrBlockOutOfRange is returned.
+func StartWithSnapshotAt(blocks []Block, initialState []AccountValue, numWorkers int, atBlock int) ([]AccountValue, error) {
+	if atBlock < 0 || atBlock >= len(blocks) {
+		return nil, fmt.Errorf("%w: %d of %d blocks", ErrBlockOutOfRange, atBlock, len(blocks))
+	}
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+
+	state := NewInMemoryAccountState(initialState)
+	for i, block := range blocks[:atBlock+1] {
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, numWorkers, BlockOptions{BlockIndex: i}); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
 		}
 	}
 
@@ -20,39 +158,396 @@ func Start(blocks []Block, initialState []AccountValue, numWorkers int) ([]Accou
 
 type Block struct {
 	Transactions []Transaction
+	// Dependencies optionally lists, for a transaction index, the transactions that must commit
+	// before it executes, in addition to the dependencies inferred from access sets. It is
+	// honored by ExecuteBlock; ExecuteBlockOCC commits strictly by index and ignores it.
+	Dependencies map[int][]int
+	// Groups optionally partitions the transaction indices into groups the caller knows to be
+	// independent, such as per-shard transactions. Groups then run concurrently, each in its
+	// listed order, and access sets are not analyzed: transactions in different groups must
+	// not conflict. Like Dependencies, it is honored by ExecuteBlock only.
+	Groups [][]int
 }
 
+// Transaction describes a change to the account state. Updates reads the accounts it needs
+// through the given read-only view and returns the updates to apply rather than applying them
+// itself; all updates of a transaction are applied in a single atomic ApplyUpdates call.
+//
+// While Updates runs, no transaction conflicting with it is committed, so the accounts in its
+// declared access set (or the whole state, for transactions that don't declare one) form a
+// consistent view that doesn't change between reads.
 type Transaction interface {
-	Updates(AccountState) ([]AccountUpdate, error)
+	Updates(ReadOnlyState) ([]AccountUpdate, error)
+}
+
+// Identified is implemented by transactions carrying a correlation ID, such as the ID of the
+// request that submitted them, which is reported in their TxResult and to observers
+type Identified interface {
+	ID() string
+}
+
+// transactionID returns the ID of tx, defaulting to its index in the block
+func transactionID(tx Transaction, index int) string {
+	if identified, ok := tx.(Identified); ok {
+		return identified.ID()
+	}
+	return strconv.Itoa(index)
 }
 
+// BlockResult describes the outcome of executing a block
+type BlockResult struct {
+	// Transactions holds one result per transaction in the block, ordered by index
+	Transactions []TxResult
+	// Conflicts describes the dependency structure the scheduler found in the block
+	Conflicts ConflictStats
+	// Stats summarizes the updates applied by the block
+	Stats BlockStats
+	// Err is set on a result emitted by a stream for a block that failed, which stopped the
+	// stream, to the error the block failed with
+	Err error
+}
+
+// BlockStats summarizes the updates a block applied
+type BlockStats struct {
+	// Updates is the number of updates applied
+	Updates int
+	// Accounts is the number of distinct accounts the applied updates touched
+	Accounts int
+	// Movement is the sum of the absolute balance changes of the applied updates, across all
+	// assets. Creating an account or setting its balance doesn't count as movement.
+	Movement uint
+}
+
+// TxResult describes the outcome of a single transaction. A transaction that was neither
+// applied nor failed was not executed because the block stopped early.
+type TxResult struct {
+	Index int
+	// ID is the transaction's Identified ID, or its index if it doesn't implement Identified
+	ID      string
+	Applied bool
+	Err     error
+	Updates []AccountUpdate
+	// NoOp is set for a successful transaction whose updates cancel out, such as a transfer
+	// from an account to itself: they only change balances, by a net zero for every account.
+	NoOp bool
+	// Skipped is set for a ConditionalTransaction whose condition didn't hold, and for a
+	// duplicate removed by BlockOptions.Deduplicate. It counts as applied, with no updates.
+	Skipped bool
+	// Reads is the number of GetAccount and HasAccount calls the transaction made, across
+	// all of its executions if it was retried or re-executed
+	Reads int
+	// Writes is the number of distinct accounts the transaction's updates write, before
+	// any UpdateTransformer; zero if its Updates failed
+	Writes int
+}
+
+// AccountUpdate describes a change to a single account. By default it adjusts the account's
+// balance by BalanceChange, creating the account if needed; Op selects other operations.
 type AccountUpdate struct {
 	Name          string
 	BalanceChange int
+	Op            AccountOp
+	Balance       uint   // Balance to set for OpCreate and OpSetBalance
+	Force         bool   // Allow OpDelete to delete an account with a nonzero balance
+	Asset         string // Asset the update applies to, NativeAsset by default
 }
 
+// AccountValue is the state of an account. Balance holds the account's native asset;
+// Assets optionally holds balances of any other assets, keyed by asset name. Metadata
+// optionally holds arbitrary labels such as the account's owner, which updates don't affect.
 type AccountValue struct {
-	Name    string
-	Balance uint
+	Name     string            `json:"name"`
+	Balance  uint              `json:"balance"`
+	Assets   map[string]uint   `json:"assets,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// AssetBalance returns the balance of the given asset, where NativeAsset refers to Balance
+func (v AccountValue) AssetBalance(asset string) uint {
+	if asset == NativeAsset {
+		return v.Balance
+	}
+	return v.Assets[asset]
+}
+
+// ReadOnlyState is the view of the account state passed to Transaction.Updates
+type ReadOnlyState interface {
+	GetAccount(name string) AccountValue
+	// HasAccount reports whether the account exists, distinguishing it from an account with a zero balance
+	HasAccount(name string) bool
 }
 
 // AccountState interface for getting account information
 type AccountState interface {
-	GetAccount(name string) AccountValue
-	ApplyUpdates([]AccountUpdate)
+	ReadOnlyState
+	// ApplyUpdates applies all updates or, if any of them is invalid, none of them
+	ApplyUpdates([]AccountUpdate) error
+}
+
+// stateView exposes only the read methods of a state, so transactions can't reach
+// ApplyUpdates through a type assertion
+type stateView struct {
+	state ReadOnlyState
 }
 
-// ExecuteBlock takes a Block with transactions, and returns the updated account and with the updated balance.
-func ExecuteBlock(block Block, state AccountState, numWorkers int) ([]AccountValue, error) {
-	// Create channels for work distribution and result collection
-	jobs := make(chan txJob, 1)
-	results := make(chan txResult, 1)
+// GetAccount implements ReadOnlyState interface
+func (v stateView) GetAccount(name string) AccountValue { return v.state.GetAccount(name) }
+
+// HasAccount implements ReadOnlyState interface
+func (v stateView) HasAccount(name string) bool { return v.state.HasAccount(name) }
+
+// ExecutionMode determines how a block handles transactions whose Updates fail
+type ExecutionMode int
+
+const (
+	// SkipFailed ignores failed transactions and continues with the rest of the block
+	SkipFailed ExecutionMode = iota
+	// AbortOnError stops the block at the first failed transaction and returns its error.
+	// Transactions before the failed one remain applied.
+	AbortOnError
+	// CollectAllErrors continues past failed transactions like SkipFailed, but then returns
+	// an error joining a TransactionError for every failed transaction, in index order,
+	// together with the snapshot of the state the others were applied to.
+	CollectAllErrors
+)
+
+// BlockOptions configures how ExecuteBlockWithOptions executes a block
+type BlockOptions struct {
+	// Mode determines how failed transactions are handled. Defaults to SkipFailed.
+	Mode ExecutionMode
+
+	// ValidateAccessSets records the accounts each AccessAware transaction actually
+	// accesses and fails the block with ErrAccessSetViolation if they fall outside its
+	// declared access set. Intended as a debugging aid for new transaction types.
+	ValidateAccessSets bool
+
+	// Observer is notified as transactions execute. May be nil.
+	Observer ExecutionObserver
+
+	// Logger logs the start and end of the block at info level, each applied transaction at
+	// debug level and each failed one at warn level. May be nil, in which case nothing is logged.
+	Logger *slog.Logger
+
+	// Metrics records transaction counts and block latency. May be nil.
+	Metrics MetricsRecorder
+
+	// Retry re-executes transactions failing with a Retryable error. Disabled by default.
+	Retry RetryPolicy
+
+	// TxTimeout bounds how long a transaction's Updates may run. A transaction exceeding it is
+	// abandoned and fails with ErrTransactionTimeout. Updates can't be interrupted, so an
+	// abandoned call keeps running in the background and its result is discarded; Updates
+	// implementations should therefore return promptly rather than block indefinitely.
+	// Zero disables the timeout.
+	TxTimeout time.Duration
+
+	// OnConflict resolves contention for accounts written by several transactions of the block.
+	// It is called once per contested account, in account name order, with the indices of the
+	// transactions declaring a write to it in serial order, and returns those allowed to proceed.
+	// The others aren't executed and fail with ErrConflictRejected. Allowed transactions still
+	// execute in serial order; use Prioritized to reorder them. May be nil.
+	OnConflict func(account string, txs []int) []int
+
+	// BlockIndex is the position of the block in its chain, recorded in ledger entries
+	BlockIndex int
+
+	// MaxInFlight bounds how many transactions may be dispatched but not yet committed, which
+	// bounds the memory held by results waiting to be committed in order. Defaults to numWorkers.
+	MaxInFlight int
+
+	// CheckSupply verifies that transactions implementing SupplyConserving don't change the
+	// total supply of any asset. A transaction violating this fails the block with
+	// ErrSupplyViolation without its updates being applied.
+	CheckSupply bool
+
+	// Atomic makes the block all-or-nothing: updates are buffered while the block executes
+	// and only applied to the state once every transaction has succeeded. If any transaction
+	// fails, the block is aborted as with AbortOnError and the state is left exactly as it was
+	// at block start.
+	Atomic bool
+
+	// SkipNoOps doesn't apply the updates of no-op transactions (see TxResult.NoOp), which
+	// still count as applied. Skipping them avoids intermediate balances, such as the debit of
+	// a self-transfer, tripping checks like minimum balances. Their updates would otherwise
+	// create accounts they credit by zero, and under underflow clamping may change balances.
+	SkipNoOps bool
+
+	// FairScheduling interleaves the transactions of different senders (see Sourced) in serial
+	// order, round-robin, instead of following their indices, so a sender with many transactions
+	// can't hold up the others. Each sender's transactions keep their relative order, and
+	// priorities still take precedence.
+	FairScheduling bool
+
+	// MaxTransactions rejects blocks with more transactions with ErrBlockTooLarge before any
+	// of them executes. Zero means unlimited.
+	MaxTransactions int
+
+	// RateLimit caps how many transactions are dispatched to workers per second, spacing
+	// dispatches evenly, e.g. to throttle calls to external systems made from Updates. Waiting
+	// for the limit is interrupted by context cancellation. Zero means unlimited.
+	RateLimit float64
+
+	// DeterministicDispatch assigns transaction i to worker i % numWorkers, instead of to
+	// whichever worker is free, to make profiles reproducible. Each worker executes its
+	// transactions in the order they become ready, and may hold up ready transactions of its
+	// own while other workers are idle.
+	DeterministicDispatch bool
+
+	// ValidateFirst rejects the whole block before executing any transaction if ValidateBlock
+	// reports an invalid one, returning the joined validation errors
+	ValidateFirst bool
+
+	// HotAccounts lists accounts, such as a central treasury, whose credits are collected
+	// while the block executes and applied at its end as one update per account and asset,
+	// so that the state isn't updated for each of them (see HotAccounts to find them). Until
+	// then, transactions reading a hot account don't observe the credits of the block; debits
+	// and other updates to it are applied as usual, so they can fail where they wouldn't if
+	// the credits were applied right away. Transactions writing a hot account without reading
+	// it, such as transfers crediting it, don't conflict with each other over it, so they can
+	// execute concurrently. A transaction's credits are checked against the state and the
+	// credits already pending when it commits, so one that couldn't be applied at the end of
+	// the block, e.g. to a frozen account, fails the transaction with none of its updates applied.
+	HotAccounts []string
+
+	// Deduplicate executes a Hashable transaction only once if the block contains it several
+	// times, e.g. because it was submitted twice: transactions with the same Hash as an earlier
+	// one in the block aren't executed and are reported skipped (see TxResult.Skipped). The first
+	// occurrence by index is kept, whatever the serial order. Other transactions are never
+	// deduplicated.
+	Deduplicate bool
+
+	// SnapshotIsolation makes every transaction of the block read the state as it was at block
+	// start, instead of observing the updates of the transactions before it, as some consensus
+	// models require. Since no transaction depends on another's updates, all of them may
+	// execute concurrently. Their updates are still applied in serial order, to a buffer that
+	// is applied to the state once the block ends, so balance changes to an account accumulate
+	// and the last update setting its balance wins; a transaction whose updates conflict with
+	// those of earlier ones, e.g. a debit overdrawing an account another debit already
+	// drained, fails as usual. So does one the state rejects, e.g. for crediting a frozen
+	// account, as the constraints of an InMemoryAccountState are checked as updates are
+	// buffered. If applying the buffer to the state still fails, none of the block's updates
+	// are applied and the block fails.
+	SnapshotIsolation bool
+
+	// OnResult is passed the result of each transaction once it's committed, from the goroutine
+	// executing the block, so results can be consumed while the block is still executing.
+	// Unless OrderedResults is set, each transaction is committed as soon as it completes, so
+	// results arrive in completion order: a transaction is only dispatched once those it
+	// conflicts with have committed, so committing it ahead of slower independent ones doesn't
+	// change the outcome. AbortOnError, atomic and snapshot isolated blocks still commit in
+	// serial order, which their semantics depend on. Transactions that don't execute because the
+	// block stopped have no result. May be nil. In atomic mode, a result reported applied is
+	// rolled back if the block fails.
+	OnResult func(TxResult)
+
+	// OrderedResults commits transactions in serial order and holds their results back until
+	// they can be passed to OnResult strictly by ascending index, whatever their completion and
+	// serial order. Results held back for transactions waiting on a transaction that doesn't
+	// execute are passed once the block stops.
+	OrderedResults bool
+
+	// Transformers rewrite the updates of each successful transaction before they are
+	// applied, see UpdateTransformer. They run in order, each on the previous one's output.
+	Transformers []UpdateTransformer
+}
+
+// ExecuteBlock takes a Block with transactions, and returns the updated account and with the
+// updated balance. Transactions touching disjoint accounts are executed concurrently across
+// numWorkers workers, while conflicting transactions keep their serial order: descending
+// priority for transactions implementing Prioritized, then index. The final state is that of
+// executing the transactions one by one in serial order. numWorkers must be at least 1,
+// otherwise ErrInvalidWorkerCount is returned. The block's explicit Dependencies are respected
+// as well; if they form a cycle, ErrDependencyCycle is returned before any transaction executes.
+func ExecuteBlock(block Block, state AccountState, numWorkers int) ([]AccountValue, BlockResult, error) {
+	executor, err := NewExecutor(WithWorkers(numWorkers))
+	if err != nil {
+		return nil, BlockResult{}, err
+	}
+	return executor.RunBlock(block, state)
+}
+
+// ExecuteBlockContext is like ExecuteBlock but stops executing and returns ctx.Err() once ctx is done.
+// Transactions that already finished executing before the first cancelled one are still applied,
+// so a cancelled block leaves state reflecting a prefix of its transactions.
+func ExecuteBlockContext(ctx context.Context, block Block, state AccountState, numWorkers int) ([]AccountValue, BlockResult, error) {
+	return ExecuteBlockWithOptions(ctx, block, state, numWorkers, BlockOptions{})
+}
+
+// ExecuteBlockWithOptions is like ExecuteBlockContext but allows configuring the execution
+func ExecuteBlockWithOptions(ctx context.Context, block Block, state AccountState, numWorkers int, opts BlockOptions) ([]AccountValue, BlockResult, error) {
+	if opts.Logger == nil {
+		return executeBlock(ctx, block, state, numWorkers, opts)
+	}
+
+	opts.Logger.Info("block started", "block", opts.BlockIndex, "transactions", len(block.Transactions))
+	start := time.Now()
+	accounts, result, err := executeBlock(ctx, block, state, numWorkers, opts)
+	attrs := []any{"block", opts.BlockIndex, "duration", time.Since(start)}
+	if err != nil {
+		opts.Logger.Warn("block failed", append(attrs, "error", err)...)
+	} else {
+		opts.Logger.Info("block finished", attrs...)
+	}
+	return accounts, result, err
+}
+
+// executeBlock implements ExecuteBlockWithOptions, apart from logging the block
+func executeBlock(ctx context.Context, block Block, state AccountState, numWorkers int, opts BlockOptions) ([]AccountValue, BlockResult, error) {
+	if opts.Metrics != nil {
+		defer func(start time.Time) {
+			opts.Metrics.BlockExecuted(time.Since(start))
+		}(time.Now())
+	}
+
+	if numWorkers < 1 {
+		return nil, BlockResult{}, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+	if opts.MaxTransactions > 0 && len(block.Transactions) > opts.MaxTransactions {
+		return nil, BlockResult{}, fmt.Errorf("%w: %d transactions, at most %d allowed",
+			ErrBlockTooLarge, len(block.Transactions), opts.MaxTransactions)
+	}
+	if opts.ValidateFirst {
+		if errs := ValidateBlock(block, stateView{state}); len(errs) > 0 {
+			return nil, BlockResult{}, errors.Join(errs...)
+		}
+	}
+
+	if opts.Deduplicate {
+		block.Transactions = deduplicate(block.Transactions)
+	}
+	names := nameNormalizerOf(state)
+	if opts.OnConflict != nil {
+		block.Transactions = resolveConflicts(block.Transactions, opts.OnConflict, opts.FairScheduling, names)
+	}
+	if names != nil && len(opts.HotAccounts) > 0 {
+		hot := make([]string, len(opts.HotAccounts))
+		for i, name := range opts.HotAccounts {
+			hot[i] = names(name)
+		}
+		opts.HotAccounts = hot
+	}
+	scheduler, err := newBlockScheduler(block, opts.FairScheduling, opts.HotAccounts, names)
+	if err != nil {
+		return nil, BlockResult{}, err
+	}
+
+	// Create channels for work distribution and result collection, sized so that every
+	// worker can have a job queued and a result pending without blocking the dispatcher.
+	// With deterministic dispatch, every worker has its own job channel.
+	jobs := []chan txJob{make(chan txJob, numWorkers)}
+	if opts.DeterministicDispatch {
+		jobs = make([]chan txJob, numWorkers)
+		for i := range jobs {
+			jobs[i] = make(chan txJob, 1)
+		}
+	}
+	results := make(chan txResult, numWorkers)
 
 	// Create worker pool
 	var wg sync.WaitGroup
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go worker(jobs, results, &wg)
+		go worker(ctx, jobs[i%len(jobs)], results, &wg)
 	}
 
 	// Start a goroutine to close results channel after all workers finish
@@ -61,37 +556,256 @@ func ExecuteBlock(block Block, state AccountState, numWorkers int) ([]AccountVal
 		close(results)
 	}()
 
-	// Process transactions sequentially
-	for i, tx := range block.Transactions {
-		// Send job with current state
-		jobs <- txJob{
-			transaction: tx,
-			index:       i,
-			state:       state,
+	// In atomic mode transactions execute against a buffer that is committed at the end.
+	// With snapshot isolation updates are buffered too, but transactions read the state itself,
+	// which therefore doesn't change until the block ends.
+	target := state
+	var buffer *overlayState
+	var held *heldObserver
+	if opts.Atomic || opts.SnapshotIsolation {
+		buffer = newOverlayState(state)
+		target = buffer
+		if observer, ok := opts.Observer.(committedObserver); ok {
+			held = &heldObserver{ExecutionObserver: observer}
+			opts.Observer = held
+		}
+	}
+	view := target
+	if opts.SnapshotIsolation {
+		view = state
+	}
+
+	run := newBlockRun(block, target, scheduler.access, opts)
+	blockResult := run.result
+	blockResult.Conflicts = scheduler.Stats()
+
+	// Dispatch each transaction as soon as all transactions it depends on have committed,
+	// and commit results in the scheduler's order. Ready transactions are dispatched in that
+	// order too, so the next transaction to commit is never kept waiting by a full window.
+	window := opts.MaxInFlight
+	if window < 1 {
+		window = numWorkers
+	}
+	remaining := make([]int, len(block.Transactions))
+	ready := &rankHeap{rank: scheduler.rank}
+	for i := range block.Transactions {
+		if !opts.SnapshotIsolation {
+			remaining[i] = len(scheduler.deps[i])
+		}
+		if remaining[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	// Results passed to OnResult unordered are committed as transactions complete
+	completionOrder := opts.OnResult != nil && !opts.OrderedResults &&
+		opts.Mode != AbortOnError && !opts.Atomic && !opts.SnapshotIsolation && len(opts.HotAccounts) == 0
+	pending := make(map[int]txResult) // executed but not yet committed
+	dispatched := make(map[int]bool)  // dispatched but not yet committed
+	committed := 0
+	inFlight := 0
+	stopped := false // no further transactions are dispatched
+	done := ctx.Done()
+	var throttle *time.Timer // fires when the rate limit next allows a dispatch
+	var nextDispatch time.Time
+
+	for committed < len(scheduler.order) {
+		if ctx.Err() != nil {
+			stopped = true
+		}
+		if stopped {
+			if inFlight == 0 {
+				break
+			}
+			done = nil
+		}
+
+		var send chan<- txJob
+		var next txJob
+		var wake <-chan time.Time
+		if !stopped && ready.Len() > 0 && len(dispatched) < window {
+			if wait := time.Until(nextDispatch); opts.RateLimit > 0 && wait > 0 {
+				// Keep committing results while waiting for the rate limit
+				if throttle == nil {
+					throttle = time.NewTimer(wait)
+					defer throttle.Stop()
+				} else {
+					throttle.Reset(wait)
+				}
+				wake = throttle.C
+			} else {
+				send = jobs[ready.indices[0]%len(jobs)]
+			}
+		}
+		if send != nil {
+			next = txJob{
+				transaction: block.Transactions[ready.indices[0]],
+				index:       ready.indices[0],
+				state:       view,
+				record:      opts.ValidateAccessSets,
+				retry:       opts.Retry,
+				timeout:     opts.TxTimeout,
+			}
 		}
 
-		// Get result
-		result := <-results
+		select {
+		case send <- next:
+			if opts.RateLimit > 0 {
+				nextDispatch = time.Now().Add(time.Duration(float64(time.Second) / opts.RateLimit))
+			}
+			run.observer.OnTransactionStart(next.index, blockResult.Transactions[next.index].ID)
+			dispatched[next.index] = true
+			heap.Pop(ready)
+			inFlight++
+
+		case result := <-results:
+			inFlight--
+			pending[result.index] = result
+
+			// Commit every executed transaction that is next in order, or only this one when
+			// committing in completion order. Transactions that finished executing after the
+			// context was done are still committed, up to the first one the workers didn't execute.
+			order := scheduler.order[committed:]
+			if completionOrder {
+				order = []int{result.index}
+			}
+			for _, i := range order {
+				result, ok := pending[i]
+				if err != nil || !ok {
+					break
+				}
+				delete(pending, i)
+				delete(dispatched, i)
+				committed++
+
+				err = run.commit(result)
+				if !result.cancelled {
+					run.emitResult(i)
+				}
+				if err != nil {
+					stopped = true
+					break
+				}
+				if opts.SnapshotIsolation {
+					continue
+				}
+				for _, j := range scheduler.dependents[i] {
+					remaining[j]--
+					if remaining[j] == 0 {
+						heap.Push(ready, j)
+					}
+				}
+			}
+
+		case <-wake:
 
-		// Apply updates if transaction succeeded
-		if result.err == nil {
-			state.ApplyUpdates(result.updates)
+		case <-done:
+			stopped = true
 		}
 	}
-	close(jobs)
+	for _, ch := range jobs {
+		close(ch)
+	}
 
 	// Drain any remaining results
 	for range results {
 		// Drain channel
 	}
 
+	run.flushResults()
+
+	// Transactions dispatched but not committed when the block stopped are discarded
+	for _, i := range scheduler.order {
+		if dispatched[i] {
+			run.observer.OnTransactionFailed(i, blockResult.Transactions[i].ID, ErrBlockAborted)
+		}
+	}
+	if err == nil && committed < len(scheduler.order) {
+		err = ctx.Err()
+	}
+	// Apply the credits deferred for hot accounts, unless the block is atomic and discarded
+	if !opts.Atomic || err == nil {
+		if hotErr := run.flushHotCredits(); hotErr != nil && err == nil {
+			err = fmt.Errorf("apply hot account credits: %w", hotErr)
+		}
+	}
+	blockResult.Stats = run.result.Stats
+
+	if opts.Atomic || opts.SnapshotIsolation {
+		commitCtx := ctx
+		if !opts.Atomic {
+			// Like any block that isn't atomic, a block that stopped early keeps the updates of
+			// the transactions committed until then
+			commitCtx = context.Background()
+		}
+		committed := false
+		if err != nil && opts.Atomic {
+			// Discard the buffered updates, nothing from this block is applied
+			for i := range blockResult.Transactions {
+				blockResult.Transactions[i].Applied = false
+			}
+			blockResult.Stats = BlockStats{}
+		} else if updates, origins := buffer.bufferedUpdates(); len(updates) > 0 {
+			if commitErr := commitBuffered(commitCtx, state, updates, origins); commitErr != nil {
+				if err == nil {
+					err = fmt.Errorf("commit block: %w", commitErr)
+				}
+				for i := range blockResult.Transactions {
+					blockResult.Transactions[i].Applied = false
+				}
+				blockResult.Stats = BlockStats{}
+			} else {
+				committed = true
+			}
+		} else {
+			committed = true
+		}
+		if held != nil {
+			held.release(committed)
+		}
+	}
+
+	if err != nil {
+		return nil, blockResult, err
+	}
+	if opts.Mode == CollectAllErrors {
+		err = joinFailures(blockResult.Transactions)
+	}
+
 	// Convert state to AccountValue slice
 	if stateWithSnapshot, ok := state.(interface{ GetSnapshot() []AccountValue }); ok {
-		return stateWithSnapshot.GetSnapshot(), nil
+		return stateWithSnapshot.GetSnapshot(), blockResult, err
 	}
 
 	// If state doesn't support GetSnapshot, return empty slice
-	return []AccountValue{}, nil
+	return []AccountValue{}, blockResult, err
+}
+
+// ExecuteBlockResumable executes a block until its first failed transaction and returns that
+// transaction's index, or -1 if every transaction was applied, along with the snapshot of the
+// state, which holds the transactions committed before the failure. Transactions are committed
+// in index order, so all transactions before the failed one are committed and none from it
+// on; after fixing the cause, the caller can resume with the transactions from failedIndex
+// on. Because Prioritized transactions are committed out of index order, blocks containing
+// any are rejected with ErrUnsupportedOperation.
+func ExecuteBlockResumable(block Block, state AccountState, numWorkers int) (snapshot []AccountValue, failedIndex int, err error) {
+	for i, tx := range block.Transactions {
+		if _, ok := tx.(Prioritized); ok {
+			return nil, -1, fmt.Errorf("%w: transaction %d is prioritized and can't be resumed in index order", ErrUnsupportedOperation, i)
+		}
+	}
+	snapshot, _, err = ExecuteBlockWithOptions(context.Background(), block, state, numWorkers, BlockOptions{Mode: AbortOnError})
+	if err != nil {
+		if snapshotter, ok := state.(snapshotState); ok {
+			snapshot = snapshotter.GetSnapshot()
+		}
+		var txErr *TransactionError
+		if errors.As(err, &txErr) {
+			return snapshot, txErr.Index, err
+		}
+		return snapshot, -1, err
+	}
+	return snapshot, -1, nil
 }
 
 // txJob represents a transaction to be processed
@@ -99,104 +813,496 @@ type txJob struct {
 	transaction Transaction
 	index       int
 	state       AccountState // Pass the current state to use
+	record      bool         // Record the accounts accessed by the transaction
+	retry       RetryPolicy
+	timeout     time.Duration // Abandon the transaction if it runs longer, zero for no limit
 }
 
 // txResult represents the result of processing a transaction
 type txResult struct {
-	updates []AccountUpdate
-	index   int
-	err     error
+	updates   []AccountUpdate
+	access    accessSet
+	index     int
+	err       error
+	cancelled bool // The context was done before the transaction was executed
+	reads     int  // Reads made by every execution of the transaction
 }
 
-// worker processes transactions from the jobs channel
-func worker(jobs <-chan txJob, results chan<- txResult, wg *sync.WaitGroup) {
+// worker processes transactions from the jobs channel. Once ctx is done, remaining jobs are
+// answered with the context error without being executed.
+func worker(ctx context.Context, jobs <-chan txJob, results chan<- txResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for job := range jobs {
-		updates, err := job.transaction.Updates(job.state)
-		results <- txResult{
-			updates: updates,
-			index:   job.index,
-			err:     err,
+		var result txResult
+		if err := ctx.Err(); err != nil {
+			result.err, result.cancelled = err, true
+		} else {
+			meter := &meteringState{state: stateView{job.state}}
+			result.updates, result.access, result.err = job.retry.execute(ctx, func() ([]AccountUpdate, accessSet, error) {
+				return runWithTimeout(job.timeout, func() ([]AccountUpdate, accessSet, error) {
+					if job.record {
+						return runRecorded(job.transaction, meter)
+					}
+					updates, err := callUpdates(job.transaction, meter)
+					return updates, accessSet{}, err
+				})
+			})
+			result.reads = int(meter.reads.Load())
+		}
+		result.index = job.index
+		results <- result
+	}
+}
+
+// callUpdates calls tx.Updates, converting a panic into an error wrapping ErrTransactionPanic
+// so that a buggy transaction fails instead of crashing its worker
+func callUpdates(tx Transaction, state ReadOnlyState) (updates []AccountUpdate, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			updates = nil
+			if rerr, ok := r.(error); ok {
+				err = fmt.Errorf("%w: %w\n%s", ErrTransactionPanic, rerr, debug.Stack())
+			} else {
+				err = fmt.Errorf("%w: %v\n%s", ErrTransactionPanic, r, debug.Stack())
+			}
 		}
+	}()
+	return tx.Updates(state)
+}
+
+// runWithTimeout calls run and returns its result, or ErrTransactionTimeout if it doesn't
+// return within timeout. In that case run is left running in its own goroutine.
+func runWithTimeout(timeout time.Duration, run func() ([]AccountUpdate, accessSet, error)) ([]AccountUpdate, accessSet, error) {
+	if timeout <= 0 {
+		return run()
+	}
+
+	type outcome struct {
+		updates []AccountUpdate
+		access  accessSet
+		err     error
+	}
+	done := make(chan outcome, 1) // buffered so an abandoned run can still finish
+	go func() {
+		updates, access, err := run()
+		done <- outcome{updates, access, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.updates, o.access, o.err
+	case <-timer.C:
+		return nil, newAccessSet(), fmt.Errorf("%w after %v", ErrTransactionTimeout, timeout)
 	}
 }
 
 // InMemoryAccountState implements AccountState with thread-safe operations
 type InMemoryAccountState struct {
-	accounts map[string]uint
-	mu       sync.RWMutex
+	accounts       map[string]uint            // native balances, keyed by account name
+	assets         map[string]map[string]uint // non-native balances, keyed by account then asset
+	metadata       map[string]map[string]string
+	minimums       map[string]uint          // minimum native balances, 0 when unset
+	frozen         map[string]struct{}      // accounts no update may touch
+	ledger         map[string][]LedgerEntry // per-account history, nil unless enabled
+	names          *NamePolicy              // normalizes account names, nil unless set
+	clampUnderflow bool
+	mu             sync.RWMutex
 }
 
 // NewInMemoryAccountState creates a new account state
 func NewInMemoryAccountState(initialAccounts []AccountValue) *InMemoryAccountState {
 	state := &InMemoryAccountState{
 		accounts: make(map[string]uint),
+		assets:   make(map[string]map[string]uint),
+		metadata: make(map[string]map[string]string),
+		minimums: make(map[string]uint),
+		frozen:   make(map[string]struct{}),
 	}
 
 	for _, acc := range initialAccounts {
 		state.accounts[acc.Name] = acc.Balance
+		if len(acc.Assets) > 0 {
+			state.assets[acc.Name] = copyBalances(acc.Assets)
+		}
+		if len(acc.Metadata) > 0 {
+			state.metadata[acc.Name] = copyMetadata(acc.Metadata)
+		}
 	}
 
 	return state
 }
 
+// NewInMemoryAccountStateStrict is like NewInMemoryAccountState, except that it fails with
+// ErrDuplicateAccount if initialAccounts names an account more than once, where
+// NewInMemoryAccountState lets the last one win
+func NewInMemoryAccountStateStrict(initialAccounts []AccountValue) (*InMemoryAccountState, error) {
+	seen := make(map[string]int, len(initialAccounts))
+	for i, acc := range initialAccounts {
+		if first, ok := seen[acc.Name]; ok {
+			return nil, fmt.Errorf("%w: %s at %d and %d", ErrDuplicateAccount, acc.Name, first, i)
+		}
+		seen[acc.Name] = i
+	}
+	return NewInMemoryAccountState(initialAccounts), nil
+}
+
 // GetAccount implements AccountState interface
 func (s *InMemoryAccountState) GetAccount(name string) AccountValue {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	balance := s.accounts[name] // Returns 0 if account doesn't exist
-	return AccountValue{
+	normalized, err := s.normalizeName(name)
+	if err != nil {
+		return AccountValue{Name: name}
+	}
+	return s.accountValue(normalized)
+}
+
+// accountValue returns the account with copies of its asset balances and metadata. The caller must hold the lock.
+func (s *InMemoryAccountState) accountValue(name string) AccountValue {
+	value := AccountValue{
 		Name:    name,
-		Balance: balance,
+		Balance: s.accounts[name], // Returns 0 if account doesn't exist
 	}
+	if assets, ok := s.assets[name]; ok {
+		value.Assets = copyBalances(assets)
+	}
+	if metadata, ok := s.metadata[name]; ok {
+		value.Metadata = copyMetadata(metadata)
+	}
+	return value
+}
+
+// HasAccount implements AccountState interface
+func (s *InMemoryAccountState) HasAccount(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, err := s.normalizeName(name)
+	if err != nil {
+		return false
+	}
+	_, ok := s.accounts[name]
+	return ok
+}
+
+// TryGetAccount is like GetAccount but also reports whether the account exists
+func (s *InMemoryAccountState) TryGetAccount(name string) (AccountValue, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	normalized, err := s.normalizeName(name)
+	if err != nil {
+		return AccountValue{Name: name}, false
+	}
+	_, ok := s.accounts[normalized]
+	return s.accountValue(normalized), ok
+}
+
+// GetAccounts returns the given accounts, read under a single lock so they form a consistent
+// view: no update is applied between reading one and reading another
+func (s *InMemoryAccountState) GetAccounts(names []string) []AccountValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]AccountValue, len(names))
+	for i, name := range names {
+		normalized, err := s.normalizeName(name)
+		if err != nil {
+			accounts[i] = AccountValue{Name: name}
+			continue
+		}
+		accounts[i] = s.accountValue(normalized)
+	}
+	return accounts
+}
+
+// applyUpdates applies a list of updates to the account state. Updates are validated
+// before any of them is written, so on error the state is left unchanged. blockIndex and
+// txIndex identify the transaction the updates belong to in ledger entries.
+func (s *InMemoryAccountState) applyUpdates(updates []AccountUpdate, blockIndex, txIndex int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staged, err := s.stageUpdates(updates, sameOrigin(blockIndex, txIndex))
+	if err != nil {
+		return err
+	}
+	s.writeStaged(staged)
+	return nil
 }
 
-// applyUpdates applies a list of updates to the account state
-func (s *InMemoryAccountState) applyUpdates(updates []AccountUpdate) {
+// applyBuffered applies the updates buffered by a block like applyUpdates, recording each
+// update in ledger entries with the transaction it was buffered by, origins[i] for updates[i]
+func (s *InMemoryAccountState) applyBuffered(updates []AccountUpdate, origins []ledgerOrigin) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, update := range updates {
-		currentBalance := s.accounts[update.Name]
-		if update.BalanceChange >= 0 {
-			s.accounts[update.Name] = currentBalance + uint(update.BalanceChange)
+	staged, err := s.stageUpdates(updates, func(i int) ledgerOrigin { return origins[i] })
+	if err != nil {
+		return err
+	}
+	s.writeStaged(staged)
+	return nil
+}
+
+// ledgerOrigin identifies the transaction an update belongs to in ledger entries
+type ledgerOrigin struct {
+	blockIndex int
+	txIndex    int
+}
+
+// sameOrigin returns the origin of updates all belonging to the same transaction
+func sameOrigin(blockIndex, txIndex int) func(int) ledgerOrigin {
+	return func(int) ledgerOrigin { return ledgerOrigin{blockIndex, txIndex} }
+}
+
+// stagedUpdates holds updates validated by stageUpdates but not yet written
+type stagedUpdates struct {
+	accounts map[string]accountEntry
+	ledger   []LedgerEntry
+}
+
+// stageUpdates validates updates and computes the resulting accounts without writing them.
+// origin returns the transaction the update at an index belongs to. The caller must hold the lock.
+func (s *InMemoryAccountState) stageUpdates(updates []AccountUpdate, origin func(int) ledgerOrigin) (stagedUpdates, error) {
+	updates, err := s.normalizeUpdates(updates)
+	if err != nil {
+		return stagedUpdates{}, err
+	}
+
+	staged := stagedUpdates{accounts: make(map[string]accountEntry, len(updates))}
+	for i, update := range updates {
+		if err := s.checkFrozen(update.Name); err != nil {
+			return stagedUpdates{}, err
+		}
+		entry, ok := staged.accounts[update.Name]
+		if !ok {
+			entry.balance, entry.exists = s.accounts[update.Name]
+			entry.assets = s.assets[update.Name]
+		}
+
+		previous := entry.balance
+		entry, err := applyUpdate(entry, update, s.clampUnderflow)
+		if err != nil {
+			return stagedUpdates{}, err
+		}
+		if err := s.checkMinimum(update, entry.balance); err != nil {
+			return stagedUpdates{}, err
+		}
+		staged.accounts[update.Name] = entry
+
+		if s.ledger != nil && update.Asset == NativeAsset {
+			from := origin(i)
+			staged.ledger = append(staged.ledger, LedgerEntry{
+				Account:    update.Name,
+				Delta:      int(entry.balance) - int(previous),
+				NewBalance: entry.balance,
+				BlockIndex: from.blockIndex,
+				TxIndex:    from.txIndex,
+			})
+		}
+	}
+	return staged, nil
+}
+
+// checkFrozen fails with ErrAccountFrozen if the account is frozen. The caller must hold the lock.
+func (s *InMemoryAccountState) checkFrozen(name string) error {
+	if _, frozen := s.frozen[name]; frozen {
+		return fmt.Errorf("%w: %s", ErrAccountFrozen, name)
+	}
+	return nil
+}
+
+// checkMinimum fails with ErrBelowMinimum if update is a debit leaving its account's native
+// balance below its minimum, given that balance. The caller must hold the lock.
+func (s *InMemoryAccountState) checkMinimum(update AccountUpdate, balance uint) error {
+	if min := s.minimums[update.Name]; update.Op == OpBalanceChange && update.Asset == NativeAsset &&
+		update.BalanceChange < 0 && balance < min {
+		return fmt.Errorf("%w: account %s would have %d, minimum is %d", ErrBelowMinimum, update.Name, balance, min)
+	}
+	return nil
+}
+
+// validateUpdate implements updateValidator, checking freezes and minimum balances
+func (s *InMemoryAccountState) validateUpdate(update AccountUpdate, balance uint) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, err := s.normalizeName(update.Name)
+	if err != nil {
+		return err
+	}
+	update.Name = name
+	if err := s.checkFrozen(name); err != nil {
+		return err
+	}
+	return s.checkMinimum(update, balance)
+}
+
+// writeStaged writes updates staged by stageUpdates. The caller must hold the lock.
+func (s *InMemoryAccountState) writeStaged(staged stagedUpdates) {
+	for _, entry := range staged.ledger {
+		s.ledger[entry.Account] = append(s.ledger[entry.Account], entry)
+	}
+
+	for name, entry := range staged.accounts {
+		if entry.exists {
+			s.accounts[name] = entry.balance
 		} else {
-			// Handle negative balance changes
-			decrease := uint(-update.BalanceChange)
-			if decrease > currentBalance {
-				// This shouldn't happen if transaction validation is correct
-				// but we protect against underflow just in case
-				s.accounts[update.Name] = 0
-			} else {
-				s.accounts[update.Name] = currentBalance - decrease
-			}
+			delete(s.accounts, name)
+			delete(s.metadata, name)
 		}
+		if entry.exists && len(entry.assets) > 0 {
+			s.assets[name] = entry.assets
+		} else {
+			delete(s.assets, name)
+		}
+	}
+}
+
+// SetMetadata sets a metadata entry of an existing account. Metadata is never changed by
+// ApplyUpdates, except that deleting an account also deletes its metadata.
+func (s *InMemoryAccountState) SetMetadata(name, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, err := s.normalizeName(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.accounts[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, name)
+	}
+	if s.metadata[name] == nil {
+		s.metadata[name] = make(map[string]string)
+	}
+	s.metadata[name][key] = value
+	return nil
+}
+
+// SetMinimumBalance sets the native balance an account must keep. A debit that would take the
+// account below it fails with ErrBelowMinimum. The minimum defaults to 0 and may be set before
+// the account exists.
+func (s *InMemoryAccountState) SetMinimumBalance(name string, min uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// An invalid name can't be given to an account, so its minimum is stored but never applies
+	if normalized, err := s.normalizeName(name); err == nil {
+		name = normalized
+	}
+	if min == 0 {
+		delete(s.minimums, name)
+		return
+	}
+	s.minimums[name] = min
+}
+
+// Freeze freezes an account: until it is unfrozen, ApplyUpdates fails with ErrAccountFrozen
+// for any updates touching it, whether they debit, credit or otherwise change it. An account
+// may be frozen before it exists.
+func (s *InMemoryAccountState) Freeze(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// As with minimums, an invalid name is frozen but can never be updated anyway
+	if normalized, err := s.normalizeName(name); err == nil {
+		name = normalized
+	}
+	s.frozen[name] = struct{}{}
+}
+
+// Unfreeze lets updates touch a frozen account again
+func (s *InMemoryAccountState) Unfreeze(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if normalized, err := s.normalizeName(name); err == nil {
+		name = normalized
+	}
+	delete(s.frozen, name)
+}
+
+// IsFrozen reports whether an account is frozen
+func (s *InMemoryAccountState) IsFrozen(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if normalized, err := s.normalizeName(name); err == nil {
+		name = normalized
 	}
+	_, frozen := s.frozen[name]
+	return frozen
 }
 
-// getSnapshot returns the current state of all accounts
+// SetClampUnderflow controls how debits exceeding an account's balance are handled. By default
+// they fail with ErrInsufficientBalance; legacy callers relying on the balance silently dropping
+// to zero can opt back into that behavior by passing true.
+func (s *InMemoryAccountState) SetClampUnderflow(clamp bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clampUnderflow = clamp
+}
+
+// clampsUnderflow reports whether debits exceeding the balance are clamped to zero
+func (s *InMemoryAccountState) clampsUnderflow() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.clampUnderflow
+}
+
+// getSnapshot returns the current state of all accounts, sorted by name
 func (s *InMemoryAccountState) getSnapshot() []AccountValue {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	result := make([]AccountValue, 0, len(s.accounts))
-	for name, balance := range s.accounts {
-		result = append(result, AccountValue{
-			Name:    name,
-			Balance: balance,
-		})
+	for name := range s.accounts {
+		result = append(result, s.accountValue(name))
 	}
+	sortAccounts(result)
 	return result
 }
 
+// sortAccounts sorts accounts by name
+func sortAccounts(accounts []AccountValue) {
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+}
+
 // Update InMemoryAccountState to implement the new interface method
-func (s *InMemoryAccountState) ApplyUpdates(updates []AccountUpdate) {
-	s.applyUpdates(updates)
+func (s *InMemoryAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	return s.applyUpdates(updates, -1, -1)
 }
 
-// GetSnapshot returns the current state of all accounts
+// GetSnapshot returns the current state of all accounts, sorted by name. The returned values
+// hold copies of the asset and metadata maps, so modifying them doesn't affect the state.
 func (s *InMemoryAccountState) GetSnapshot() []AccountValue {
 	return s.getSnapshot()
 }
+
+// IterateSnapshot calls fn for every account, sorted by name, stopping early if fn returns
+// false. It holds a read lock throughout, so fn sees a consistent state without the state being
+// copied, and must not call any method of the state, not even to read it: taking the read lock
+// again while an update waits for the lock deadlocks. It also blocks updates until it returns.
+// The values passed to fn are copies as with GetSnapshot.
+func (s *InMemoryAccountState) IterateSnapshot(fn func(AccountValue) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.accounts))
+	for name := range s.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !fn(s.accountValue(name)) {
+			return
+		}
+	}
+}
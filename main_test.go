@@ -1,8 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // transfer implements Transaction interface for testing
@@ -12,10 +23,10 @@ type transfer struct {
 	value int
 }
 
-func (t transfer) Updates(state AccountState) ([]AccountUpdate, error) {
+func (t transfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
 	fromAcc := state.GetAccount(t.from)
 	if fromAcc.Balance < uint(t.value) {
-		return nil, fmt.Errorf("insufficient balance: account %s has %d, needs %d", t.from, fromAcc.Balance, t.value)
+		return nil, fmt.Errorf("%w: account %s has %d, needs %d", ErrInsufficientBalance, t.from, fromAcc.Balance, t.value)
 	}
 
 	return []AccountUpdate{
@@ -24,6 +35,14 @@ func (t transfer) Updates(state AccountState) ([]AccountUpdate, error) {
 	}, nil
 }
 
+func (t transfer) AccessSet() ([]string, []string) {
+	return []string{t.from}, []string{t.from, t.to}
+}
+
+func (t transfer) ConservesSupply() bool {
+	return true
+}
+
 func TestStart_Example1(t *testing.T) {
 	// Initial state setup
 	initialState := []AccountValue{
@@ -131,7 +150,7 @@ func TestStart_MultipleBlocks(t *testing.T) {
 		}
 
 		// Compare with first result to ensure deterministic execution
-		if !compareResults(firstResult, result) {
+		if !SnapshotsEqual(firstResult, result) {
 			t.Errorf("Non-deterministic results detected on iteration %d", i)
 		}
 	}
@@ -165,26 +184,6 @@ func verifyResults(t *testing.T, result []AccountValue, expected map[string]uint
 	}
 }
 
-// compareResults compares two sets of account values
-func compareResults(a, b []AccountValue) bool {
-	if len(a) != len(b) {
-		return false
-	}
-
-	aMap := make(map[string]uint)
-	for _, acc := range a {
-		aMap[acc.Name] = acc.Balance
-	}
-
-	for _, acc := range b {
-		if aMap[acc.Name] != acc.Balance {
-			return false
-		}
-	}
-
-	return true
-}
-
 func TestStart_ConcurrentTransactions(t *testing.T) {
 	initialState := []AccountValue{
 		{Name: "A1", Balance: 100},
@@ -219,7 +218,7 @@ func TestStart_ConcurrentTransactions(t *testing.T) {
 		}
 
 		// Compare with first result to ensure deterministic execution
-		if !compareResults(firstResult, result) {
+		if !SnapshotsEqual(firstResult, result) {
 			t.Errorf("Non-deterministic results detected on iteration %d", i)
 		}
 	}
@@ -274,7 +273,7 @@ func TestStart_DifferentWorkerCounts(t *testing.T) {
 		}
 
 		// Compare with first result to ensure deterministic execution
-		if !compareResults(firstResult, result) {
+		if !SnapshotsEqual(firstResult, result) {
 			t.Errorf("Results with %d workers differ from results with %d workers",
 				numWorkers, workerCounts[0])
 
@@ -294,3 +293,1660 @@ func TestStart_DifferentWorkerCounts(t *testing.T) {
 
 	verifyResults(t, firstResult, expected)
 }
+
+// opaqueTransfer is a transfer that doesn't declare its access set
+type opaqueTransfer struct {
+	t transfer
+}
+
+func (o opaqueTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	return o.t.Updates(state)
+}
+
+// misdeclaredTransfer declares only the sender as written while also crediting the receiver
+type misdeclaredTransfer struct {
+	transfer
+}
+
+func (t misdeclaredTransfer) AccessSet() ([]string, []string) {
+	return []string{t.from}, []string{t.from}
+}
+
+func TestExecuteBlock_ValidateAccessSets(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 100},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		misdeclaredTransfer{transfer{from: "B", to: "A", value: 10}},
+	}}
+
+	// Without validation the misdeclared transaction goes unnoticed
+	if _, _, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 2); err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+
+	_, _, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), 2, BlockOptions{ValidateAccessSets: true})
+	if !errors.Is(err, ErrAccessSetViolation) {
+		t.Fatalf("Expected ErrAccessSetViolation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "transaction 1") || !strings.Contains(err.Error(), "[A]") {
+		t.Errorf("Expected error to name transaction 1 and account A, got %v", err)
+	}
+}
+
+// busyTransfer is a transfer that burns CPU before producing its updates,
+// simulating transactions with non-trivial execution cost
+type busyTransfer struct {
+	transfer
+	work int
+}
+
+func (t busyTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	sum := sha256.Sum256([]byte(t.from + t.to))
+	for i := 0; i < t.work; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return t.transfer.Updates(state)
+}
+
+func BenchmarkExecuteBlock_IndependentTransfers(b *testing.B) {
+	const numTransactions = 256
+
+	var initialState []AccountValue
+	var transactions []Transaction
+	for i := 0; i < numTransactions; i++ {
+		from, to := fmt.Sprintf("F%d", i), fmt.Sprintf("T%d", i)
+		initialState = append(initialState, AccountValue{Name: from, Balance: 100})
+		transactions = append(transactions, busyTransfer{
+			transfer: transfer{from: from, to: to, value: 1},
+			work:     2000,
+		})
+	}
+	block := Block{Transactions: transactions}
+
+	for _, numWorkers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", numWorkers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				state := NewInMemoryAccountState(initialState)
+				if _, _, err := ExecuteBlock(block, state, numWorkers); err != nil {
+					b.Fatalf("ExecuteBlock failed: %v", err)
+				}
+			}
+			b.ReportMetric(float64(b.N*numTransactions)/b.Elapsed().Seconds(), "tx/s")
+		})
+	}
+}
+
+func TestInvalidWorkerCount(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 10}}
+	block := Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 5}}}
+
+	for _, numWorkers := range []int{0, -3} {
+		t.Run(fmt.Sprintf("workers=%d", numWorkers), func(t *testing.T) {
+			state := NewInMemoryAccountState(initialState)
+			if _, _, err := ExecuteBlock(block, state, numWorkers); !errors.Is(err, ErrInvalidWorkerCount) {
+				t.Errorf("Expected ExecuteBlock to return ErrInvalidWorkerCount, got %v", err)
+			}
+			verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10})
+
+			if _, err := Start([]Block{block}, initialState, numWorkers); !errors.Is(err, ErrInvalidWorkerCount) {
+				t.Errorf("Expected Start to return ErrInvalidWorkerCount, got %v", err)
+			}
+			// Rejected even when there is nothing to execute
+			if _, err := Start(nil, initialState, numWorkers); !errors.Is(err, ErrInvalidWorkerCount) {
+				t.Errorf("Expected Start without blocks to return ErrInvalidWorkerCount, got %v", err)
+			}
+		})
+	}
+}
+
+// peakObserver tracks the largest number of transactions started but not yet finished
+type peakObserver struct {
+	outstanding int
+	peak        int
+}
+
+func (o *peakObserver) OnTransactionStart(int, string) {
+	o.outstanding++
+	if o.outstanding > o.peak {
+		o.peak = o.outstanding
+	}
+}
+
+func (o *peakObserver) OnTransactionApplied(int, string, []AccountUpdate) { o.outstanding-- }
+func (o *peakObserver) OnTransactionFailed(int, string, error)            { o.outstanding-- }
+
+func TestExecuteBlock_BoundedInFlight(t *testing.T) {
+	const numTransactions = 20000
+
+	// Mostly independent transfers, with every tenth one contending for a shared account
+	initialState := []AccountValue{{Name: "Shared", Balance: numTransactions}}
+	var transactions []Transaction
+	for i := 0; i < numTransactions; i++ {
+		from := fmt.Sprintf("F%d", i)
+		initialState = append(initialState, AccountValue{Name: from, Balance: 1})
+		if i%10 == 0 {
+			from = "Shared"
+		}
+		transactions = append(transactions, transfer{from: from, to: fmt.Sprintf("T%d", i), value: 1})
+	}
+	block := Block{Transactions: transactions}
+
+	tests := []struct {
+		name       string
+		numWorkers int
+		window     int
+		expected   int
+	}{
+		{"default window", 8, 0, 8},
+		{"configured window", 8, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observer := &peakObserver{}
+			opts := BlockOptions{Observer: observer, MaxInFlight: tt.window}
+			_, result, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), tt.numWorkers, opts)
+			if err != nil {
+				t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+			}
+			for i, tx := range result.Transactions {
+				if !tx.Applied {
+					t.Fatalf("Expected transaction %d to be applied, got %+v", i, tx)
+				}
+			}
+			if observer.peak > tt.expected {
+				t.Errorf("Expected at most %d transactions in flight, got %d", tt.expected, observer.peak)
+			}
+		})
+	}
+}
+
+// cancellingTransfer is a transfer that cancels the block's context once executed
+type cancellingTransfer struct {
+	transfer
+	cancel context.CancelFunc
+}
+
+func (t cancellingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	defer t.cancel()
+	return t.transfer.Updates(state)
+}
+
+func (t cancellingTransfer) AccessSet() ([]string, []string) {
+	// Conflict with everything so the transactions after it are dispatched later
+	return []string{t.from}, []string{t.from, t.to, "A", "B", "C"}
+}
+
+func TestExecuteBlockContext_CancelMidBlock(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 100},
+		{Name: "C", Balance: 100},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},                             // applied
+		cancellingTransfer{transfer{from: "B", to: "C", value: 20}, cancel}, // applied, then cancels
+		transfer{from: "C", to: "A", value: 30},                             // not executed
+		transfer{from: "A", to: "C", value: 40},                             // not executed
+	}}
+
+	_, _, err := ExecuteBlockContext(ctx, block, state, 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// State reflects exactly the transactions executed before cancellation
+	expected := map[string]uint{
+		"A": 90,
+		"B": 90,
+		"C": 120,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+
+	// All workers and helper goroutines exit
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Goroutine leak: %d goroutines before, %d after", before, after)
+	}
+}
+
+func TestStartContext_AlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	blocks := []Block{{Transactions: []Transaction{transfer{from: "A", to: "B", value: 1}}}}
+	if _, err := StartContext(ctx, blocks, []AccountValue{{Name: "A", Balance: 1}}, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExecuteBlock_FailedTransactionModes(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},   // A->B: 5
+		transfer{from: "B", to: "C", value: 100}, // B->C: 100 (fails)
+		transfer{from: "C", to: "A", value: 10},  // C->A: 10
+	}}
+
+	t.Run("SkipFailed", func(t *testing.T) {
+		state := NewInMemoryAccountState(initialState)
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Mode: SkipFailed}); err != nil {
+			t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+		}
+
+		expected := map[string]uint{
+			"A": 25,
+			"B": 35,
+			"C": 30,
+		}
+		verifyResults(t, state.GetSnapshot(), expected)
+	})
+
+	t.Run("AbortOnError", func(t *testing.T) {
+		state := NewInMemoryAccountState(initialState)
+		_, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Mode: AbortOnError})
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+		if !strings.HasPrefix(err.Error(), "transaction 1 failed: insufficient balance") {
+			t.Errorf("Expected error for transaction 1, got %v", err)
+		}
+
+		// Only the transaction before the failed one is applied
+		expected := map[string]uint{
+			"A": 15,
+			"B": 35,
+			"C": 40,
+		}
+		verifyResults(t, state.GetSnapshot(), expected)
+	})
+}
+
+func TestExecuteBlock_CollectAllErrors(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 5}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "C", value: 10},
+		transfer{from: "B", to: "C", value: 50}, // fails
+		transfer{from: "A", to: "D", value: 10},
+		transfer{from: "E", to: "C", value: 1},   // fails
+		transfer{from: "A", to: "F", value: 500}, // fails
+		transfer{from: "B", to: "G", value: 5},
+	}}
+
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Mode: CollectAllErrors})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Expected a joined error, got %T", err)
+	}
+	var failed []int
+	for _, e := range joined.Unwrap() {
+		var txErr *TransactionError
+		if !errors.As(e, &txErr) {
+			t.Fatalf("Expected a TransactionError, got %v", e)
+		}
+		failed = append(failed, txErr.Index)
+	}
+	if fmt.Sprint(failed) != "[1 3 4]" {
+		t.Errorf("Expected transactions [1 3 4] reported, got %v", failed)
+	}
+	if !result.Transactions[5].Applied {
+		t.Errorf("Expected the block to continue past failures, got %+v", result.Transactions[5])
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 80, "B": 0, "C": 10, "D": 10, "G": 5})
+
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), Block{Transactions: block.Transactions[:1]}, state, 4, BlockOptions{Mode: CollectAllErrors}); err != nil {
+		t.Errorf("Expected no error without failures, got %v", err)
+	}
+}
+
+func TestExecuteBlockResumable(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 10},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "C", value: 10},
+		transfer{from: "A", to: "D", value: 10},
+		transfer{from: "B", to: "E", value: 50}, // fails
+		transfer{from: "A", to: "F", value: 10},
+		transfer{from: "A", to: "G", value: 10},
+	}}
+
+	snapshot, failedIndex, err := ExecuteBlockResumable(block, state, 4)
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	if failedIndex != 2 {
+		t.Fatalf("Expected transaction 2 to fail, got %d", failedIndex)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 80, "B": 10, "C": 10, "D": 10})
+
+	// Fund B and resume from the failed transaction
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", BalanceChange: 40}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	snapshot, failedIndex, err = ExecuteBlockResumable(Block{Transactions: block.Transactions[failedIndex:]}, state, 4)
+	if err != nil || failedIndex != -1 {
+		t.Fatalf("Expected resumed block to succeed, got index %d and %v", failedIndex, err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 60, "B": 0, "C": 10, "D": 10, "E": 50, "F": 10, "G": 10})
+}
+
+func TestExecuteBlockResumable_RejectsPrioritized(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		prioritizedTransfer{transfer{from: "A", to: "C", value: 10}, 1},
+	}}
+
+	if _, _, err := ExecuteBlockResumable(block, state, 4); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Fatalf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100})
+}
+
+func TestExecuteBlock_TransactionResults(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+		{Name: "D", Balance: 50},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},   // applied
+		transfer{from: "C", to: "D", value: 100}, // fails, runs concurrently with the first
+		transfer{from: "D", to: "A", value: 10},  // applied
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+
+	if len(result.Transactions) != 3 {
+		t.Fatalf("Expected 3 transaction results, got %d", len(result.Transactions))
+	}
+	for i, tx := range result.Transactions {
+		if tx.Index != i {
+			t.Errorf("Result %d has index %d", i, tx.Index)
+		}
+	}
+
+	if r := result.Transactions[0]; !r.Applied || r.Err != nil || len(r.Updates) != 2 {
+		t.Errorf("Expected transaction 0 to be applied with 2 updates, got %+v", r)
+	}
+	if r := result.Transactions[1]; r.Applied || r.Err == nil {
+		t.Errorf("Expected transaction 1 to fail, got %+v", r)
+	}
+	if r := result.Transactions[2]; !r.Applied || r.Updates[0] != (AccountUpdate{Name: "D", BalanceChange: -10}) {
+		t.Errorf("Expected transaction 2 to be applied debiting D, got %+v", r)
+	}
+}
+
+func TestStartWithResults_AggregatesBlocks(t *testing.T) {
+	blocks := []Block{
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 50}}},
+		{Transactions: []Transaction{
+			transfer{from: "B", to: "A", value: 500}, // fails
+			transfer{from: "B", to: "A", value: 25},
+		}},
+	}
+
+	_, results, err := StartWithResults(context.Background(), blocks, []AccountValue{{Name: "A", Balance: 100}}, 2)
+	if err != nil {
+		t.Fatalf("StartWithResults failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 block results, got %d", len(results))
+	}
+	if len(results[0].Transactions) != 1 || !results[0].Transactions[0].Applied {
+		t.Errorf("Unexpected first block result: %+v", results[0])
+	}
+	if second := results[1].Transactions; len(second) != 2 || second[0].Applied || !second[1].Applied {
+		t.Errorf("Unexpected second block result: %+v", results[1])
+	}
+}
+
+func TestStartDetailed_SnapshotPerBlock(t *testing.T) {
+	blocks := []Block{
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 30},
+			transfer{from: "C", to: "A", value: 10},
+		}},
+		{Transactions: []Transaction{
+			transfer{from: "B", to: "C", value: 50}, // fails, B has 40
+			transfer{from: "B", to: "C", value: 15},
+		}},
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 80},
+		}},
+	}
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 10},
+		{Name: "C", Balance: 20},
+	}
+
+	snapshots, err := StartDetailed(blocks, initialState, 2)
+	if err != nil {
+		t.Fatalf("StartDetailed failed: %v", err)
+	}
+	if len(snapshots) != len(blocks) {
+		t.Fatalf("Expected %d snapshots, got %d", len(blocks), len(snapshots))
+	}
+
+	expected := []map[string]uint{
+		{"A": 80, "B": 40, "C": 10},
+		{"A": 80, "B": 25, "C": 25},
+		{"A": 0, "B": 105, "C": 25},
+	}
+	for i, snapshot := range snapshots {
+		verifyResults(t, snapshot.Accounts, expected[i])
+	}
+	if second := snapshots[1].Result.Transactions; second[0].Applied || !second[1].Applied {
+		t.Errorf("Unexpected second block result: %+v", second)
+	}
+}
+
+func TestStartWithSnapshotAt(t *testing.T) {
+	var executed atomic.Int32
+	blocks := []Block{
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 30}}},
+		{Transactions: []Transaction{transfer{from: "B", to: "C", value: 10}}},
+		{Transactions: []Transaction{transfer{from: "C", to: "A", value: 5}}},
+		{Transactions: []Transaction{countingTransfer{transfer{from: "A", to: "C", value: 70}, &executed}}},
+	}
+	initialState := []AccountValue{{Name: "A", Balance: 100}}
+
+	snapshot, err := StartWithSnapshotAt(blocks, initialState, 2, 1)
+	if err != nil {
+		t.Fatalf("StartWithSnapshotAt failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 70, "B": 20, "C": 10})
+	if n := executed.Load(); n != 0 {
+		t.Errorf("Expected the blocks after the snapshot not to execute, got %d executions", n)
+	}
+
+	for _, atBlock := range []int{-1, len(blocks)} {
+		if _, err := StartWithSnapshotAt(blocks, initialState, 2, atBlock); !errors.Is(err, ErrBlockOutOfRange) {
+			t.Errorf("Block %d: expected ErrBlockOutOfRange, got %v", atBlock, err)
+		}
+	}
+}
+
+// loggingState is an account state logging every batch of updates applied to it
+type loggingState struct {
+	state *InMemoryAccountState
+	mu    sync.Mutex
+	log   [][]AccountUpdate
+}
+
+func (s *loggingState) GetAccount(name string) AccountValue { return s.state.GetAccount(name) }
+func (s *loggingState) HasAccount(name string) bool         { return s.state.HasAccount(name) }
+func (s *loggingState) GetSnapshot() []AccountValue         { return s.state.GetSnapshot() }
+
+func (s *loggingState) ApplyUpdates(updates []AccountUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log = append(s.log, updates)
+	return s.state.ApplyUpdates(updates)
+}
+
+func TestStartWithState(t *testing.T) {
+	blocks := []Block{
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 30}, transfer{from: "A", to: "C", value: 20}}},
+		{Transactions: []Transaction{transfer{from: "B", to: "C", value: 10}}},
+	}
+	state := &loggingState{state: NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})}
+
+	snapshot, err := StartWithState(blocks, state, 2)
+	if err != nil {
+		t.Fatalf("StartWithState failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 50, "B": 20, "C": 30})
+	if len(state.log) != 3 {
+		t.Errorf("Expected 3 batches of updates applied through the state, got %v", state.log)
+	}
+
+	// A state without snapshots is rejected
+	noSnapshot := struct{ AccountState }{state}
+	if _, err := StartWithState(blocks, noSnapshot, 2); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+}
+
+func TestStartAtomic_RollsBackAllBlocks(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 10},
+	}
+	blocks := []Block{
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 40}}},
+		{Transactions: []Transaction{
+			transfer{from: "B", to: "C", value: 20},
+			transfer{from: "C", to: "A", value: 500}, // fails
+		}},
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 10}}},
+	}
+
+	snapshot, err := StartAtomic(blocks, initialState, 2)
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 100, "B": 10})
+
+	// Without the failing transaction every block is committed
+	blocks[1].Transactions = blocks[1].Transactions[:1]
+	snapshot, err = StartAtomic(blocks, initialState, 2)
+	if err != nil {
+		t.Fatalf("StartAtomic failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 50, "B": 40, "C": 20})
+}
+
+func TestExecuteBlock_AtomicRollsBackOnFailure(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},   // A->B: 5
+		transfer{from: "B", to: "C", value: 35},  // B->C: 35 (only possible after the first)
+		transfer{from: "C", to: "A", value: 100}, // C->A: 100 (fails)
+		transfer{from: "A", to: "C", value: 1},   // A->C: 1
+	}}
+
+	state := NewInMemoryAccountState(initialState)
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Atomic: true})
+	if err == nil || !strings.HasPrefix(err.Error(), "transaction 2 failed") {
+		t.Fatalf("Expected transaction 2 to fail, got %v", err)
+	}
+
+	// State is untouched
+	expected := map[string]uint{
+		"A": 20,
+		"B": 30,
+		"C": 40,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+
+	for _, tx := range result.Transactions {
+		if tx.Applied {
+			t.Errorf("Transaction %d reported as applied after rollback", tx.Index)
+		}
+	}
+}
+
+func TestExecuteBlock_AtomicReportsMinimumBalanceViolation(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 20}, {Name: "B", Balance: 30}}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},
+		transfer{from: "B", to: "A", value: 25}, // takes B below its minimum
+	}}
+	newState := func() *InMemoryAccountState {
+		state := NewInMemoryAccountState(initialState)
+		state.SetMinimumBalance("B", 20)
+		return state
+	}
+
+	state := newState()
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Atomic: true})
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || txErr.Index != 1 || !errors.Is(err, ErrBelowMinimum) {
+		t.Fatalf("Expected transaction 1 to fail with ErrBelowMinimum, got %v", err)
+	}
+	if !errors.Is(result.Transactions[1].Err, ErrBelowMinimum) {
+		t.Errorf("Expected the result of transaction 1 to record ErrBelowMinimum, got %+v", result.Transactions[1])
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 20, "B": 30})
+
+	// Simulating the block reports the violation as a real run does
+	_, simulated, err := SimulateBlock(block, newState(), 4)
+	if err != nil {
+		t.Fatalf("SimulateBlock failed: %v", err)
+	}
+	if !errors.Is(simulated[1].Err, ErrBelowMinimum) || simulated[1].Applied {
+		t.Errorf("Expected the simulated transaction 1 to fail with ErrBelowMinimum, got %+v", simulated[1])
+	}
+}
+
+func TestExecuteBlock_AtomicCommitsOnSuccess(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 20},
+		transfer{from: "B", to: "C", value: 50}, // depends on the buffered effect of the first
+	}}
+
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Atomic: true})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	expected := map[string]uint{
+		"A": 0,
+		"B": 0,
+		"C": 50,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d not applied", tx.Index)
+		}
+	}
+}
+
+// observingTransfer is a transfer recording the sender balance it observed
+type observingTransfer struct {
+	transfer
+	seen *uint
+}
+
+func (t observingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	*t.seen = state.GetAccount(t.from).Balance
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlock_SnapshotIsolation(t *testing.T) {
+	seen := make([]uint, 3)
+	block := Block{Transactions: []Transaction{
+		observingTransfer{transfer{from: "A", to: "B", value: 30}, &seen[0]},
+		observingTransfer{transfer{from: "A", to: "C", value: 50}, &seen[1]},
+		observingTransfer{transfer{from: "A", to: "D", value: 40}, &seen[2]}, // overdraws A with the others
+	}}
+
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{SnapshotIsolation: true})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	for i, balance := range seen {
+		if balance != 100 {
+			t.Errorf("Transaction %d saw a balance of %d, expected the block-start balance 100", i, balance)
+		}
+	}
+	for i, expected := range []error{nil, nil, ErrInsufficientBalance} {
+		if r := result.Transactions[i]; !errors.Is(r.Err, expected) || r.Applied != (expected == nil) {
+			t.Errorf("Transaction %d: expected %v, got %+v", i, expected, r)
+		}
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 20, "B": 30, "C": 50})
+
+	// Without snapshot isolation, each transfer sees the earlier ones
+	state = NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{}); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if seen[0] != 100 || seen[1] != 70 || seen[2] != 20 {
+		t.Errorf("Expected balances 100, 70 and 20 to be seen, got %v", seen)
+	}
+}
+
+func TestExecuteBlock_SnapshotIsolationFailsOnlyRejectedTransactions(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 100}, {Name: "C", Balance: 100}, {Name: "E", Balance: 100}}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		transfer{from: "C", to: "D", value: 10}, // credits frozen D
+		transfer{from: "E", to: "F", value: 60}, // takes E below its minimum
+		transfer{from: "A", to: "F", value: 10},
+	}}
+	newState := func() *InMemoryAccountState {
+		state := NewInMemoryAccountState(initialState)
+		state.Freeze("D")
+		state.SetMinimumBalance("E", 50)
+		return state
+	}
+
+	expected, normal, err := ExecuteBlock(block, newState(), 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	snapshot, isolated, err := ExecuteBlockWithOptions(context.Background(), block, newState(), 4, BlockOptions{SnapshotIsolation: true})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	for i, reason := range []error{nil, ErrAccountFrozen, ErrBelowMinimum, nil} {
+		if r := isolated.Transactions[i]; !errors.Is(r.Err, reason) || r.Applied != (reason == nil) {
+			t.Errorf("Transaction %d: expected %v, got %+v", i, reason, r)
+		}
+		if n := normal.Transactions[i]; n.Applied != isolated.Transactions[i].Applied {
+			t.Errorf("Transaction %d: applied %v with snapshot isolation, %v without", i, isolated.Transactions[i].Applied, n.Applied)
+		}
+	}
+	if !SnapshotsEqual(expected, snapshot) {
+		t.Errorf("Expected %+v, got %+v", expected, snapshot)
+	}
+}
+
+// mint credits an account without debiting any other
+type mint struct {
+	to    string
+	value int
+}
+
+func (m mint) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	return []AccountUpdate{{Name: m.to, BalanceChange: m.value}}, nil
+}
+
+func (m mint) AccessSet() ([]string, []string) {
+	return nil, []string{m.to}
+}
+
+func TestApplyUpdates_Overflow(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: math.MaxUint - 10},
+		{Name: "B", Balance: 5},
+	})
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "B", BalanceChange: 1},
+		{Name: "A", BalanceChange: 11},
+	})
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected ErrOverflow, got %v", err)
+	}
+
+	// No update from the failed call is applied
+	expected := map[string]uint{
+		"A": math.MaxUint - 10,
+		"B": 5,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+}
+
+func TestExecuteBlock_OverflowIsReported(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: math.MaxUint - 10}})
+	block := Block{Transactions: []Transaction{
+		mint{to: "A", value: 10},
+		mint{to: "A", value: 1}, // overflows
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !errors.Is(result.Transactions[1].Err, ErrOverflow) || result.Transactions[1].Applied {
+		t.Errorf("Expected transaction 1 to fail with ErrOverflow, got %+v", result.Transactions[1])
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": math.MaxUint})
+
+	_, _, err = ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Mode: AbortOnError})
+	if !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+}
+
+func TestApplyUpdates_UnderflowStrictByDefault(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 10},
+		{Name: "B", Balance: 10},
+	})
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "B", BalanceChange: 5},
+		{Name: "A", BalanceChange: -15},
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "account A has 10, cannot subtract 15") {
+		t.Errorf("Expected error to describe the underflow, got %v", err)
+	}
+
+	expected := map[string]uint{
+		"A": 10,
+		"B": 10,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+}
+
+func TestApplyUpdates_UnderflowClampOptIn(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+	state.SetClampUnderflow(true)
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -15}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 0})
+}
+
+func TestExecuteBlock_UnderflowRejectsTransaction(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+	block := Block{Transactions: []Transaction{
+		mint{to: "A", value: -15}, // debits without checking the balance
+		mint{to: "A", value: 5},
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !errors.Is(result.Transactions[0].Err, ErrInsufficientBalance) {
+		t.Errorf("Expected transaction 0 to fail with ErrInsufficientBalance, got %v", result.Transactions[0].Err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 15})
+}
+
+func TestGetSnapshot_SortedAndStable(t *testing.T) {
+	var initialState []AccountValue
+	for _, name := range []string{"M", "C", "Z", "A", "K", "B"} {
+		initialState = append(initialState, AccountValue{Name: name, Balance: 1})
+	}
+
+	states := map[string]interface{ GetSnapshot() []AccountValue }{
+		"in-memory": NewInMemoryAccountState(initialState),
+		"striped":   NewStripedAccountState(initialState, 4),
+	}
+	for name, state := range states {
+		first := state.GetSnapshot()
+		if !sort.SliceIsSorted(first, func(i, j int) bool { return first[i].Name < first[j].Name }) {
+			t.Errorf("%s: expected snapshot sorted by name, got %+v", name, first)
+		}
+		for i := 0; i < 10; i++ {
+			if again := state.GetSnapshot(); !reflect.DeepEqual(first, again) {
+				t.Fatalf("%s: expected identical snapshots, got %+v and %+v", name, first, again)
+			}
+		}
+	}
+}
+
+func TestNewInMemoryAccountStateStrict(t *testing.T) {
+	initial := []AccountValue{{Name: "A", Balance: 10}, {Name: "B", Balance: 20}, {Name: "A", Balance: 30}}
+
+	if _, err := NewInMemoryAccountStateStrict(initial); !errors.Is(err, ErrDuplicateAccount) {
+		t.Errorf("Expected ErrDuplicateAccount, got %v", err)
+	}
+	// The lenient constructor keeps the last duplicate
+	verifyResults(t, NewInMemoryAccountState(initial).GetSnapshot(), map[string]uint{"A": 30, "B": 20})
+
+	state, err := NewInMemoryAccountStateStrict(initial[:2])
+	if err != nil {
+		t.Fatalf("NewInMemoryAccountStateStrict failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10, "B": 20})
+}
+
+func TestInMemoryAccountState_GetAccountsIsConsistent(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 1000}, {Name: "B", Balance: 1000}})
+
+	// Concurrent transfers between A and B keep their total constant
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			change := 1 - 2*(i%2)
+			if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -change}, {Name: "B", BalanceChange: change}}); err != nil {
+				t.Errorf("ApplyUpdates failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		accounts := state.GetAccounts([]string{"A", "B"})
+		if total := accounts[0].Balance + accounts[1].Balance; total != 2000 {
+			t.Fatalf("Expected a consistent view totalling 2000, got %+v", accounts)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if accounts := state.GetAccounts([]string{"B", "missing"}); accounts[0].Name != "B" || accounts[1].Name != "missing" || accounts[1].Balance != 0 {
+		t.Errorf("Expected accounts in the requested order, with a zero value for a missing one, got %+v", accounts)
+	}
+}
+
+func TestInMemoryAccountState_Metadata(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100, Metadata: map[string]string{"owner": "alice"}},
+		{Name: "B", Balance: 50},
+	})
+	if err := state.SetMetadata("B", "type", "escrow"); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if err := state.SetMetadata("C", "type", "escrow"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound for a missing account, got %v", err)
+	}
+
+	// Balance updates, including those of a block, leave metadata untouched
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		transfer{from: "B", to: "C", value: 10},
+	}}
+	if _, _, err := ExecuteBlock(block, state, 2); err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	expected := map[string]map[string]string{
+		"A": {"owner": "alice"},
+		"B": {"type": "escrow"},
+		"C": nil,
+	}
+	for name, metadata := range expected {
+		acc := state.GetAccount(name)
+		if !reflect.DeepEqual(acc.Metadata, metadata) {
+			t.Errorf("Account %s: expected metadata %v, got %v", name, metadata, acc.Metadata)
+		}
+	}
+	if acc := state.GetAccount("A"); acc.Balance != 70 {
+		t.Errorf("Expected A to have balance 70, got %d", acc.Balance)
+	}
+
+	// Returned metadata is a copy
+	state.GetAccount("A").Metadata["owner"] = "mallory"
+	if owner := state.GetAccount("A").Metadata["owner"]; owner != "alice" {
+		t.Errorf("Expected owner to remain alice, got %s", owner)
+	}
+
+	// Deleting an account deletes its metadata
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", Op: OpDelete, Force: true}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", Op: OpCreate}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	if metadata := state.GetAccount("B").Metadata; metadata != nil {
+		t.Errorf("Expected recreated account to have no metadata, got %v", metadata)
+	}
+}
+
+func TestInMemoryAccountState_SnapshotIsACopy(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{
+		Name:     "A",
+		Balance:  100,
+		Assets:   map[string]uint{"gold": 5},
+		Metadata: map[string]string{"owner": "alice"},
+	}})
+
+	snapshot := state.GetSnapshot()
+	snapshot[0].Metadata["owner"] = "mallory"
+	snapshot[0].Metadata["type"] = "stolen"
+	snapshot[0].Assets["gold"] = 500
+	snapshot[0].Balance = 1
+
+	acc := state.GetAccount("A")
+	if !reflect.DeepEqual(acc.Metadata, map[string]string{"owner": "alice"}) {
+		t.Errorf("Expected metadata to be unaffected, got %v", acc.Metadata)
+	}
+	if acc.Assets["gold"] != 5 || acc.Balance != 100 {
+		t.Errorf("Expected balances to be unaffected, got %+v", acc)
+	}
+}
+
+func TestInMemoryAccountState_MinimumBalance(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 100},
+	})
+	state.SetMinimumBalance("A", 30)
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "C", value: 70}, // leaves exactly the minimum
+		transfer{from: "A", to: "C", value: 1},  // would go below it
+		transfer{from: "B", to: "C", value: 70}, // B has no minimum
+	}}
+	snapshot, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !result.Transactions[0].Applied {
+		t.Errorf("Expected the withdrawal respecting the minimum to be applied, got %+v", result.Transactions[0])
+	}
+	if r := result.Transactions[1]; r.Applied || !errors.Is(r.Err, ErrBelowMinimum) {
+		t.Errorf("Expected the withdrawal violating the minimum to fail with ErrBelowMinimum, got %+v", r)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 30, "B": 30, "C": 140})
+
+	// Credits are unaffected, and removing the minimum allows the debit
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: 5}}); err != nil {
+		t.Fatalf("Credit failed: %v", err)
+	}
+	state.SetMinimumBalance("A", 0)
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -35}}); err != nil {
+		t.Errorf("Expected debit without a minimum to succeed, got %v", err)
+	}
+}
+
+func TestInMemoryAccountState_Freeze(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 100},
+	})
+	state.Freeze("B")
+	if !state.IsFrozen("B") || state.IsFrozen("A") {
+		t.Fatalf("Expected only B to be frozen")
+	}
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10}, // credit to a frozen account
+		transfer{from: "B", to: "A", value: 10}, // debit from a frozen account
+		transfer{from: "A", to: "C", value: 10},
+	}}
+	snapshot, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for _, i := range []int{0, 1} {
+		if r := result.Transactions[i]; r.Applied || !errors.Is(r.Err, ErrAccountFrozen) {
+			t.Errorf("Expected transaction %d to fail with ErrAccountFrozen, got %+v", i, r)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 90, "B": 100, "C": 10})
+
+	state.Unfreeze("B")
+	if state.IsFrozen("B") {
+		t.Fatalf("Expected B to be unfrozen")
+	}
+	snapshot, result, err = ExecuteBlock(Block{Transactions: block.Transactions[:2]}, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for _, r := range result.Transactions {
+		if !r.Applied {
+			t.Errorf("Expected transaction %d to be applied after unfreezing, got %v", r.Index, r.Err)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 90, "B": 100, "C": 10})
+}
+
+// mutatingTransfer is a misbehaving transfer trying to apply its updates itself
+type mutatingTransfer struct {
+	transfer
+	mutated *atomic.Bool
+}
+
+func (t mutatingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if mutable, ok := state.(interface{ ApplyUpdates([]AccountUpdate) error }); ok {
+		t.mutated.Store(true)
+		_ = mutable.ApplyUpdates([]AccountUpdate{{Name: t.to, BalanceChange: 1000}})
+	}
+	return t.transfer.Updates(state)
+}
+
+func TestTransaction_CannotMutateStateThroughView(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 100}}
+	mutated := &atomic.Bool{}
+	tx := mutatingTransfer{transfer{from: "A", to: "B", value: 10}, mutated}
+
+	engines := map[string]func(Block, AccountState) ([]AccountValue, BlockResult, error){
+		"scheduled": func(block Block, state AccountState) ([]AccountValue, BlockResult, error) {
+			return ExecuteBlock(block, state, 2)
+		},
+		"validated": func(block Block, state AccountState) ([]AccountValue, BlockResult, error) {
+			return ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{ValidateAccessSets: true})
+		},
+		"occ": func(block Block, state AccountState) ([]AccountValue, BlockResult, error) {
+			return ExecuteBlockOCC(block, state, 2)
+		},
+	}
+	blocks := map[string]Block{
+		"plain":     {Transactions: []Transaction{tx}},
+		"composite": {Transactions: []Transaction{CompositeTransaction{Transactions: []Transaction{tx}}}},
+	}
+	for engineName, execute := range engines {
+		for blockName, block := range blocks {
+			snapshot, _, err := execute(block, NewInMemoryAccountState(initialState))
+			if err != nil {
+				t.Fatalf("%s/%s: execution failed: %v", engineName, blockName, err)
+			}
+			if mutated.Load() {
+				t.Fatalf("%s/%s: transaction could reach ApplyUpdates through the state passed to Updates", engineName, blockName)
+			}
+			verifyResults(t, snapshot, map[string]uint{"A": 90, "B": 10})
+		}
+	}
+}
+
+func TestInMemoryAccountState_ExistenceVersusZeroBalance(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "Zero", Balance: 0}})
+
+	if !state.HasAccount("Zero") {
+		t.Error("Expected account with zero balance to exist")
+	}
+	if state.HasAccount("Missing") {
+		t.Error("Expected missing account not to exist")
+	}
+
+	if acc, ok := state.TryGetAccount("Zero"); !ok || acc.Balance != 0 {
+		t.Errorf("Expected (Zero, 0, true), got (%+v, %v)", acc, ok)
+	}
+	if acc, ok := state.TryGetAccount("Missing"); ok || acc.Balance != 0 {
+		t.Errorf("Expected (Missing, 0, false), got (%+v, %v)", acc, ok)
+	}
+
+	// Crediting an account creates it
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "Missing", BalanceChange: 0}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	if !state.HasAccount("Missing") {
+		t.Error("Expected updated account to exist")
+	}
+}
+
+// existingTransfer is a transfer that fails explicitly if the sender doesn't exist
+type existingTransfer struct {
+	transfer
+}
+
+func (t existingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if !state.HasAccount(t.from) {
+		return nil, fmt.Errorf("account %s does not exist", t.from)
+	}
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlock_TransferFromNonexistentAccount(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 0}})
+	block := Block{Transactions: []Transaction{
+		existingTransfer{transfer{from: "A", to: "B", value: 0}},
+		existingTransfer{transfer{from: "X", to: "B", value: 0}},
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !result.Transactions[0].Applied {
+		t.Errorf("Expected transfer from zero-balance account to apply, got %v", result.Transactions[0].Err)
+	}
+	if err := result.Transactions[1].Err; err == nil || err.Error() != "account X does not exist" {
+		t.Errorf("Expected transfer from nonexistent account to fail, got %v", err)
+	}
+	if state.HasAccount("X") {
+		t.Error("Failed transfer created the sender account")
+	}
+}
+
+// pairAuditor reads both accounts of a pair and fails if their total isn't conserved
+type pairAuditor struct {
+	a, b  string
+	total uint
+}
+
+func (p pairAuditor) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	first := state.GetAccount(p.a)
+	runtime.Gosched() // give concurrently committing transactions a chance to interleave
+	second := state.GetAccount(p.b)
+	if sum := first.Balance + second.Balance; sum != p.total {
+		return nil, fmt.Errorf("inconsistent read: %s+%s = %d, expected %d", p.a, p.b, sum, p.total)
+	}
+	return nil, nil
+}
+
+func (p pairAuditor) AccessSet() ([]string, []string) {
+	return []string{p.a, p.b}, nil
+}
+
+func TestExecuteBlock_ConservesFundsUnderConcurrentReads(t *testing.T) {
+	const pairs = 8
+	var initialState []AccountValue
+	for i := 0; i < pairs; i++ {
+		initialState = append(initialState,
+			AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 100},
+			AccountValue{Name: fmt.Sprintf("B%d", i), Balance: 100},
+		)
+	}
+	const total = pairs * 200
+
+	var transactions []Transaction
+	for round := 0; round < 20; round++ {
+		for i := 0; i < pairs; i++ {
+			a, b := fmt.Sprintf("A%d", i), fmt.Sprintf("B%d", i)
+			if round%2 == 0 {
+				a, b = b, a
+			}
+			transactions = append(transactions,
+				transfer{from: a, to: b, value: round % 7},
+				pairAuditor{a: a, b: b, total: 200},
+			)
+		}
+	}
+
+	state := NewInMemoryAccountState(initialState)
+
+	// A concurrent reader must never observe funds in flight between accounts
+	stop := make(chan struct{})
+	violations := make(chan uint, 1)
+	go func() {
+		defer close(violations)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			var sum uint
+			for _, acc := range state.GetSnapshot() {
+				sum += acc.Balance
+			}
+			if sum != total {
+				violations <- sum
+				return
+			}
+			runtime.Gosched()
+		}
+	}()
+
+	_, result, err := ExecuteBlock(Block{Transactions: transactions}, state, 8)
+	close(stop)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+
+	if sum, ok := <-violations; ok {
+		t.Errorf("Concurrent reader observed total %d, expected %d", sum, total)
+	}
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d failed: %v", tx.Index, tx.Err)
+		}
+	}
+}
+
+func TestInMemoryAccountState_LedgerHistory(t *testing.T) {
+	blocks := []Block{
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 30},
+			transfer{from: "C", to: "A", value: 10},
+		}},
+		{Transactions: []Transaction{
+			transfer{from: "B", to: "C", value: 50}, // fails, B has 40
+			transfer{from: "B", to: "C", value: 15},
+		}},
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 80},
+		}},
+	}
+	initial := map[string]uint{"A": 100, "B": 10, "C": 20}
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: initial["A"]},
+		{Name: "B", Balance: initial["B"]},
+		{Name: "C", Balance: initial["C"]},
+	})
+	state.EnableLedger()
+
+	for i, block := range blocks {
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{BlockIndex: i}); err != nil {
+			t.Fatalf("Block %d failed: %v", i, err)
+		}
+	}
+
+	for _, acc := range state.GetSnapshot() {
+		balance := int(initial[acc.Name])
+		for _, entry := range state.History(acc.Name) {
+			balance += entry.Delta
+			if entry.NewBalance != uint(balance) {
+				t.Errorf("Account %s: entry %+v, expected new balance %d", acc.Name, entry, balance)
+			}
+		}
+		if uint(balance) != acc.Balance {
+			t.Errorf("Account %s: history sums to %d, balance is %d", acc.Name, balance, acc.Balance)
+		}
+	}
+
+	expected := []LedgerEntry{
+		{Account: "B", Delta: 30, NewBalance: 40, BlockIndex: 0, TxIndex: 0},
+		{Account: "B", Delta: -15, NewBalance: 25, BlockIndex: 1, TxIndex: 1},
+		{Account: "B", Delta: 80, NewBalance: 105, BlockIndex: 2, TxIndex: 0},
+	}
+	if history := state.History("B"); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Unexpected history for B: %+v", history)
+	}
+	if history := state.History("D"); len(history) != 0 {
+		t.Errorf("Expected no history for unknown account, got %+v", history)
+	}
+}
+
+func TestInMemoryAccountState_LedgerBufferedBlocks(t *testing.T) {
+	for _, opts := range []BlockOptions{{Atomic: true, BlockIndex: 3}, {SnapshotIsolation: true, BlockIndex: 3}} {
+		state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 10}})
+		state.EnableLedger()
+
+		block := Block{Transactions: []Transaction{
+			transfer{from: "A", to: "C", value: 30},
+			transfer{from: "B", to: "C", value: 5},
+		}}
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, opts); err != nil {
+			t.Fatalf("%+v: ExecuteBlock failed: %v", opts, err)
+		}
+
+		expected := []LedgerEntry{
+			{Account: "C", Delta: 30, NewBalance: 30, BlockIndex: 3, TxIndex: 0},
+			{Account: "C", Delta: 5, NewBalance: 35, BlockIndex: 3, TxIndex: 1},
+		}
+		if history := state.History("C"); !reflect.DeepEqual(history, expected) {
+			t.Errorf("%+v: unexpected history for C: %+v", opts, history)
+		}
+	}
+}
+
+func TestInMemoryAccountState_LedgerDisabledByDefault(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B"}})
+
+	if _, _, err := ExecuteBlock(Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 10}}}, state, 1); err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if history := state.History("A"); len(history) != 0 {
+		t.Errorf("Expected no history without EnableLedger, got %+v", history)
+	}
+}
+
+func TestExecuteBlock_SelfTransfer(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 10}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "A", value: 100},
+		transfer{from: "B", to: "A", value: 10},
+	}}
+
+	accounts, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	verifyResults(t, accounts, map[string]uint{"A": 110, "B": 0})
+	if self := result.Transactions[0]; !self.Applied || self.Err != nil || !self.NoOp {
+		t.Errorf("Expected the self-transfer to be applied as a no-op, got %+v", self)
+	}
+	if result.Transactions[1].NoOp {
+		t.Errorf("Expected a regular transfer not to be a no-op, got %+v", result.Transactions[1])
+	}
+}
+
+func TestExecuteBlock_SkipNoOps(t *testing.T) {
+	block := Block{Transactions: []Transaction{transfer{from: "A", to: "A", value: 50}}}
+	newState := func() *InMemoryAccountState {
+		state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+		state.SetMinimumBalance("A", 80)
+		return state
+	}
+
+	// Applied update by update, the self-transfer's debit dips below the minimum
+	_, result, err := ExecuteBlock(block, newState(), 1)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !errors.Is(result.Transactions[0].Err, ErrBelowMinimum) {
+		t.Fatalf("Expected ErrBelowMinimum without SkipNoOps, got %+v", result.Transactions[0])
+	}
+
+	state := newState()
+	accounts, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 1, BlockOptions{SkipNoOps: true})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if tx := result.Transactions[0]; !tx.Applied || !tx.NoOp {
+		t.Errorf("Expected the skipped self-transfer to count as applied, got %+v", tx)
+	}
+	verifyResults(t, accounts, map[string]uint{"A": 100})
+}
+
+func TestExecuteBlock_MaxTransactions(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 1000}})
+	transactions := make([]Transaction, 101)
+	for i := range transactions {
+		transactions[i] = transfer{from: "A", to: "B", value: 1}
+	}
+	opts := BlockOptions{MaxTransactions: 100}
+
+	_, _, err := ExecuteBlockWithOptions(context.Background(), Block{Transactions: transactions}, state, 4, opts)
+	if !errors.Is(err, ErrBlockTooLarge) {
+		t.Fatalf("Expected ErrBlockTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "101") || !strings.Contains(err.Error(), "100") {
+		t.Errorf("Expected the error to report the actual and allowed counts, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 1000})
+
+	accounts, _, err := ExecuteBlockWithOptions(context.Background(), Block{Transactions: transactions[:100]}, state, 4, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions at the limit failed: %v", err)
+	}
+	verifyResults(t, accounts, map[string]uint{"A": 900, "B": 100})
+}
+
+func TestInMemoryAccountState_IterateSnapshot(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "C", Balance: 30},
+		{Name: "A", Balance: 10, Assets: map[string]uint{"gold": 1}},
+		{Name: "B", Balance: 20},
+	})
+
+	var iterated []AccountValue
+	var sum uint
+	state.IterateSnapshot(func(acc AccountValue) bool {
+		iterated = append(iterated, acc)
+		sum += acc.Balance
+		return true
+	})
+
+	var expected uint
+	for _, acc := range state.GetSnapshot() {
+		expected += acc.Balance
+	}
+	if sum != expected {
+		t.Errorf("Iterated balances sum to %d, snapshot to %d", sum, expected)
+	}
+	if !reflect.DeepEqual(iterated, state.GetSnapshot()) {
+		t.Errorf("Iteration doesn't match GetSnapshot: %+v", iterated)
+	}
+
+	var visited []string
+	state.IterateSnapshot(func(acc AccountValue) bool {
+		visited = append(visited, acc.Name)
+		return acc.Name != "B"
+	})
+	if fmt.Sprint(visited) != "[A B]" {
+		t.Errorf("Expected iteration to stop after B, visited %v", visited)
+	}
+}
+
+// panickingTransfer is a transfer whose Updates panics with value
+type panickingTransfer struct {
+	transfer
+	value any
+}
+
+func (t panickingTransfer) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	panic(t.value)
+}
+
+func TestExecuteBlock_PanickingTransaction(t *testing.T) {
+	cause := errors.New("nil map write")
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		panickingTransfer{transfer{from: "A", to: "C", value: 10}, "index out of range"},
+		transfer{from: "A", to: "D", value: 10},
+		panickingTransfer{transfer{from: "B", to: "C", value: 5}, cause},
+	}}
+
+	tests := []struct {
+		name string
+		opts BlockOptions
+	}{
+		{"default", BlockOptions{}},
+		{"recorded", BlockOptions{ValidateAccessSets: true}},
+		{"with timeout", BlockOptions{TxTimeout: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+			accounts, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, tt.opts)
+			if err != nil {
+				t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+			}
+			verifyResults(t, accounts, map[string]uint{"A": 80, "B": 10, "D": 10})
+
+			for _, i := range []int{1, 3} {
+				if tx := result.Transactions[i]; tx.Applied || !errors.Is(tx.Err, ErrTransactionPanic) {
+					t.Errorf("Expected transaction %d to fail with ErrTransactionPanic, got %+v", i, tx)
+				}
+			}
+			if err := result.Transactions[1].Err; !strings.Contains(err.Error(), "index out of range") {
+				t.Errorf("Expected the error to report the panic value, got %v", err)
+			}
+			if !errors.Is(result.Transactions[3].Err, cause) {
+				t.Errorf("Expected the error to wrap the panicked error, got %v", result.Transactions[3].Err)
+			}
+		})
+	}
+}
+
+func TestExecuteBlock_RateLimit(t *testing.T) {
+	const n = 6
+	transactions := make([]Transaction, n)
+	for i := range transactions {
+		transactions[i] = transfer{from: fmt.Sprintf("A%d", i), to: fmt.Sprintf("B%d", i)}
+	}
+	opts := BlockOptions{RateLimit: 50} // one dispatch every 20ms
+
+	start := time.Now()
+	_, result, err := ExecuteBlockWithOptions(context.Background(), Block{Transactions: transactions}, NewInMemoryAccountState(nil), 4, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if elapsed, min := time.Since(start), (n-1)*20*time.Millisecond; elapsed < min {
+		t.Errorf("Expected executing %d transactions to take at least %v, took %v", n, min, elapsed)
+	}
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d failed: %v", tx.Index, tx.Err)
+		}
+	}
+
+	// Cancellation interrupts waiting for the limit
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	opts.RateLimit = 1
+	_, _, err = ExecuteBlockWithOptions(ctx, Block{Transactions: transactions}, NewInMemoryAccountState(nil), 4, opts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected cancellation to stop waiting for the rate limit, took %v", elapsed)
+	}
+}
+
+func TestExecuteBlock_DeterministicDispatch(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	var initial []AccountValue
+	for _, name := range accounts {
+		initial = append(initial, AccountValue{Name: name, Balance: 20}) // low enough for some transfers to fail
+	}
+	block := GenerateBlock(accounts, 300, 7)
+
+	expected, expectedResult, err := ExecuteBlock(block, NewInMemoryAccountState(initial), 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for run := 0; run < 5; run++ {
+		snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initial), 4, BlockOptions{DeterministicDispatch: true})
+		if err != nil {
+			t.Fatalf("Run %d: ExecuteBlockWithOptions failed: %v", run, err)
+		}
+		if !SnapshotsEqual(expected, snapshot) {
+			t.Fatalf("Run %d: expected %+v, got %+v", run, expected, snapshot)
+		}
+		for i, tx := range result.Transactions {
+			if tx.Applied != expectedResult.Transactions[i].Applied {
+				t.Fatalf("Run %d: transaction %d applied %v, expected %v", run, i, tx.Applied, expectedResult.Transactions[i].Applied)
+			}
+		}
+	}
+}
+
+func TestExecuteBlock_Stats(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 10}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30}, // 2 updates, moving 60
+		transfer{from: "B", to: "C", value: 50}, // fails, not counted
+		transfer{from: "B", to: "C", value: 25}, // 2 updates, moving 50
+		mint{to: "D", value: 5},                 // 1 update, moving 5
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	expected := BlockStats{Updates: 5, Accounts: 4, Movement: 115}
+	if result.Stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, result.Stats)
+	}
+
+	// An aborted atomic block applies nothing
+	_, result, _ = ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Atomic: true})
+	if result.Stats != (BlockStats{}) {
+		t.Errorf("Expected no stats for an aborted atomic block, got %+v", result.Stats)
+	}
+}
+
+// balancingTransfer moves half the difference between two accounts from the richer to the
+// poorer, reading both
+type balancingTransfer struct {
+	a, b string
+}
+
+func (t balancingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	a, b := state.GetAccount(t.a).Balance, state.GetAccount(t.b).Balance
+	change := (int(a) - int(b)) / 2
+	return []AccountUpdate{{Name: t.a, BalanceChange: -change}, {Name: t.b, BalanceChange: change}}, nil
+}
+
+func (t balancingTransfer) AccessSet() ([]string, []string) {
+	return []string{t.a, t.b}, []string{t.a, t.b}
+}
+
+func TestExecuteBlock_Metering(t *testing.T) {
+	block := Block{Transactions: []Transaction{
+		balancingTransfer{a: "A", b: "B"},
+		transfer{from: "C", to: "D", value: 10}, // reads C, fails
+	}}
+	initialState := []AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 20}}
+
+	engines := map[string]func(Block, AccountState, int) ([]AccountValue, BlockResult, error){
+		"ExecuteBlock":    ExecuteBlock,
+		"ExecuteBlockOCC": ExecuteBlockOCC,
+	}
+	for name, execute := range engines {
+		snapshot, result, err := execute(block, NewInMemoryAccountState(initialState), 2)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		verifyResults(t, snapshot, map[string]uint{"A": 60, "B": 60})
+		if r := result.Transactions[0]; r.Reads != 2 || r.Writes != 2 {
+			t.Errorf("%s: expected 2 reads and 2 writes, got %d and %d", name, r.Reads, r.Writes)
+		}
+		if r := result.Transactions[1]; r.Reads != 1 || r.Writes != 0 {
+			t.Errorf("%s: expected 1 read and no writes for the failed transfer, got %d and %d", name, r.Reads, r.Writes)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricsRecorder receives execution metrics. Implementations may forward them to any
+// metrics system; Metrics is a built-in implementation exposing them to Prometheus.
+// Methods may be called concurrently by blocks executing in parallel.
+type MetricsRecorder interface {
+	// TransactionProcessed is called once per transaction that was applied or failed
+	TransactionProcessed(failed bool)
+	// BlockExecuted is called once per block with the time it took to execute
+	BlockExecuted(duration time.Duration)
+}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the block latency histogram
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics is a MetricsRecorder serving its metrics in the Prometheus text exposition format.
+// It records transactions_total, transactions_failed_total and the block_execution_seconds
+// histogram without depending on a Prometheus client library.
+type Metrics struct {
+	mu           sync.Mutex
+	transactions uint64
+	failed       uint64
+	buckets      []float64
+	bucketCounts []uint64 // non-cumulative count per bucket
+	latencySum   float64
+	blocks       uint64
+}
+
+// NewMetrics creates a Metrics using DefaultLatencyBuckets
+func NewMetrics() *Metrics {
+	return &Metrics{
+		buckets:      DefaultLatencyBuckets,
+		bucketCounts: make([]uint64, len(DefaultLatencyBuckets)),
+	}
+}
+
+// TransactionProcessed implements MetricsRecorder interface
+func (m *Metrics) TransactionProcessed(failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.transactions++
+	if failed {
+		m.failed++
+	}
+}
+
+// BlockExecuted implements MetricsRecorder interface
+func (m *Metrics) BlockExecuted(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			m.bucketCounts[i]++
+			break
+		}
+	}
+	m.latencySum += seconds
+	m.blocks++
+}
+
+// ServeHTTP implements http.Handler, writing the metrics for a Prometheus scrape
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP transactions_total Total number of transactions processed.")
+	fmt.Fprintln(w, "# TYPE transactions_total counter")
+	fmt.Fprintf(w, "transactions_total %d\n", m.transactions)
+
+	fmt.Fprintln(w, "# HELP transactions_failed_total Total number of transactions that failed.")
+	fmt.Fprintln(w, "# TYPE transactions_failed_total counter")
+	fmt.Fprintf(w, "transactions_failed_total %d\n", m.failed)
+
+	fmt.Fprintln(w, "# HELP block_execution_seconds Time taken to execute a block.")
+	fmt.Fprintln(w, "# TYPE block_execution_seconds histogram")
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		cumulative += m.bucketCounts[i]
+		fmt.Fprintf(w, "block_execution_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "block_execution_seconds_bucket{le=\"+Inf\"} %d\n", m.blocks)
+	fmt.Fprintf(w, "block_execution_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "block_execution_seconds_count %d\n", m.blocks)
+}
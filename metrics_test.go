@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_CountsTransactionsAndBlocks(t *testing.T) {
+	metrics := NewMetrics()
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5},
+		transfer{from: "B", to: "A", value: 100}, // fails
+		transfer{from: "B", to: "A", value: 10},
+	}}
+
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Metrics: metrics}); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Scraping metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading metrics failed: %v", err)
+	}
+
+	for _, line := range []string{
+		"transactions_total 3",
+		"transactions_failed_total 1",
+		`block_execution_seconds_bucket{le="+Inf"} 1`,
+		"block_execution_seconds_count 1",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+}
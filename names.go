@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NameCase selects how a NamePolicy cases account names
+type NameCase int
+
+const (
+	// PreserveCase leaves names as they are, so names differing only in case are distinct accounts
+	PreserveCase NameCase = iota
+	// LowerCase folds names to lower case
+	LowerCase
+	// UpperCase folds names to upper case
+	UpperCase
+)
+
+// NamePolicy normalizes and validates account names, so that names meant to refer to the same
+// account, such as " A " and "A", don't create distinct accounts
+type NamePolicy struct {
+	// TrimSpace removes leading and trailing white space
+	TrimSpace bool
+	// Case folds names to a single case
+	Case NameCase
+	// Allowed reports whether a rune may appear in a normalized name. Nil allows any rune.
+	Allowed func(rune) bool
+}
+
+// Normalize returns the normalized form of name. It fails with ErrInvalidAccountName if the
+// normalized name is empty or contains a rune that isn't allowed.
+func (p NamePolicy) Normalize(name string) (string, error) {
+	normalized := name
+	if p.TrimSpace {
+		normalized = strings.TrimSpace(normalized)
+	}
+	switch p.Case {
+	case LowerCase:
+		normalized = strings.ToLower(normalized)
+	case UpperCase:
+		normalized = strings.ToUpper(normalized)
+	}
+
+	if normalized == "" {
+		return "", fmt.Errorf("%w: %q is empty", ErrInvalidAccountName, name)
+	}
+	if p.Allowed != nil {
+		for _, r := range normalized {
+			if !p.Allowed(r) {
+				return "", fmt.Errorf("%w: %q contains %q", ErrInvalidAccountName, name, r)
+			}
+		}
+	}
+	return normalized, nil
+}
+
+// SetNamePolicy makes the state normalize every account name it is given, in updates and
+// lookups alike, and reject updates naming invalid accounts with ErrInvalidAccountName. Invalid
+// names are reported as nonexistent accounts by lookups. Names of accounts already in the state
+// aren't changed, so the policy should be set before the state is used. Blocks executed
+// against the state normalize the names of declared access sets and hot accounts too, so
+// transactions spelling one account differently conflict as they should.
+func (s *InMemoryAccountState) SetNamePolicy(policy NamePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.names = &policy
+}
+
+// nameNormalizer is implemented by states normalizing account names, so that the scheduler
+// and overlays keying accounts by name agree with the state on which names are the same account
+type nameNormalizer interface {
+	// normalizeAccountName returns the normalized form of name, or name itself if it's invalid
+	normalizeAccountName(name string) string
+}
+
+// normalizeAccountName implements nameNormalizer
+func (s *InMemoryAccountState) normalizeAccountName(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if normalized, err := s.normalizeName(name); err == nil {
+		return normalized
+	}
+	return name
+}
+
+// nameNormalizerOf returns the name normalization of state, or nil if it doesn't normalize names
+func nameNormalizerOf(state any) func(string) string {
+	if normalizer, ok := state.(nameNormalizer); ok {
+		return normalizer.normalizeAccountName
+	}
+	return nil
+}
+
+// normalizeName returns name normalized by the state's policy, if any. The caller must hold the lock.
+func (s *InMemoryAccountState) normalizeName(name string) (string, error) {
+	if s.names == nil {
+		return name, nil
+	}
+	return s.names.Normalize(name)
+}
+
+// normalizeUpdates returns updates with their names normalized. The caller must hold the lock.
+func (s *InMemoryAccountState) normalizeUpdates(updates []AccountUpdate) ([]AccountUpdate, error) {
+	if s.names == nil {
+		return updates, nil
+	}
+
+	normalized := make([]AccountUpdate, len(updates))
+	for i, update := range updates {
+		name, err := s.names.Normalize(update.Name)
+		if err != nil {
+			return nil, err
+		}
+		update.Name = name
+		normalized[i] = update
+	}
+	return normalized, nil
+}
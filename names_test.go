@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"unicode"
+)
+
+func TestNamePolicy_Normalize(t *testing.T) {
+	policy := NamePolicy{
+		TrimSpace: true,
+		Case:      UpperCase,
+		Allowed:   func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' },
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"A", "A", nil},
+		{" a ", "A", nil},
+		{"\talice-1\n", "ALICE-1", nil},
+		{"   ", "", ErrInvalidAccountName},
+		{"al ice", "", ErrInvalidAccountName},
+		{"bob!", "", ErrInvalidAccountName},
+	}
+	for _, tt := range tests {
+		got, err := policy.Normalize(tt.name)
+		if !errors.Is(err, tt.err) || got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, %v; expected %q, %v", tt.name, got, err, tt.expected, tt.err)
+		}
+	}
+
+	if got, err := (NamePolicy{}).Normalize(" Mixed "); err != nil || got != " Mixed " {
+		t.Errorf("Expected the zero policy to keep names, got %q, %v", got, err)
+	}
+}
+
+func TestInMemoryAccountState_NamePolicy(t *testing.T) {
+	state := NewInMemoryAccountState(nil)
+	state.SetNamePolicy(NamePolicy{TrimSpace: true, Case: LowerCase})
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: " A ", BalanceChange: 10}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "a", BalanceChange: 5}, {Name: "A\n", BalanceChange: -3}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"a": 12})
+
+	if acc := state.GetAccount("A "); acc.Name != "a" || acc.Balance != 12 {
+		t.Errorf("Expected lookups to be normalized, got %+v", acc)
+	}
+	if !state.HasAccount(" a") {
+		t.Error("Expected HasAccount to normalize its name")
+	}
+
+	// Updates naming an invalid account are rejected as a whole
+	err := state.ApplyUpdates([]AccountUpdate{{Name: "a", BalanceChange: -2}, {Name: "  ", BalanceChange: 2}})
+	if !errors.Is(err, ErrInvalidAccountName) {
+		t.Fatalf("Expected ErrInvalidAccountName, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"a": 12})
+	if state.HasAccount(" ") {
+		t.Error("Expected an invalid name not to exist")
+	}
+}
+
+func TestExecuteBlock_NamePolicy(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "alice", Balance: 100}})
+	state.SetNamePolicy(NamePolicy{TrimSpace: true, Case: LowerCase})
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "Alice", to: "bob ", value: 30},
+		transfer{from: "bob ", to: "ALICE", value: 10}, // conflicts with the first through "bob "
+		transfer{from: "alice", to: "", value: 10},     // fails
+	}}
+	accounts, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	verifyResults(t, accounts, map[string]uint{"alice": 80, "bob": 20})
+	if !errors.Is(result.Transactions[2].Err, ErrInvalidAccountName) {
+		t.Errorf("Expected ErrInvalidAccountName, got %+v", result.Transactions[2])
+	}
+}
+
+func TestExecuteBlock_NamePolicyNormalizesAccessSets(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		state := NewInMemoryAccountState([]AccountValue{{Name: "alice", Balance: 100}})
+		state.SetNamePolicy(NamePolicy{TrimSpace: true, Case: LowerCase})
+
+		// The second transfer spends what the first credits, spelling the account differently
+		block := Block{Transactions: []Transaction{
+			transfer{from: "Alice", to: "bob", value: 30},
+			transfer{from: " BOB", to: "carol", value: 30},
+		}}
+		accounts, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Atomic: atomic})
+		if err != nil {
+			t.Fatalf("Atomic %v: ExecuteBlock failed: %v", atomic, err)
+		}
+		if result.Conflicts.SerializedTransactions != 1 {
+			t.Errorf("Atomic %v: expected the transfers to conflict, got %+v", atomic, result.Conflicts)
+		}
+		verifyResults(t, accounts, map[string]uint{"alice": 70, "bob": 0, "carol": 30})
+	}
+}
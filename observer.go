@@ -0,0 +1,66 @@
+package main
+
+// ExecutionObserver is notified as a block executes, e.g. for auditing or progress reporting.
+//
+// Each callback receives the transaction's index and ID, as reported in its TxResult.
+// Callbacks are made from the goroutine executing the block, never concurrently, so
+// implementations don't need to synchronize unless they are shared between blocks executing
+// in parallel. Every started transaction receives exactly one terminal callback, either
+// OnTransactionApplied or OnTransactionFailed. In atomic mode, applied means applied to the
+// block's buffer; a block that is rolled back doesn't revoke earlier OnTransactionApplied calls.
+// SupplyTracker is the exception: it is only told about transactions once their block's buffer
+// is committed.
+type ExecutionObserver interface {
+	// OnTransactionStart is called when the transaction is dispatched to a worker
+	OnTransactionStart(index int, id string)
+	// OnTransactionApplied is called once the transaction's updates have been applied
+	OnTransactionApplied(index int, id string, updates []AccountUpdate)
+	// OnTransactionFailed is called when the transaction fails or is discarded because the block stopped early
+	OnTransactionFailed(index int, id string, err error)
+}
+
+// committedObserver is implemented by observers that must only be told about updates that
+// reached the state, such as SupplyTracker. In atomic and snapshot isolated blocks, their
+// OnTransactionApplied calls are held back until the block's buffer is committed, and turned
+// into OnTransactionFailed calls with ErrBlockAborted if it is discarded instead.
+type committedObserver interface {
+	ExecutionObserver
+	committedOnly()
+}
+
+// heldObserver holds back the OnTransactionApplied calls of a committedObserver
+type heldObserver struct {
+	ExecutionObserver
+	applied []heldApplied
+}
+
+// heldApplied is a held back OnTransactionApplied call
+type heldApplied struct {
+	index   int
+	id      string
+	updates []AccountUpdate
+}
+
+// OnTransactionApplied implements ExecutionObserver by holding the call back
+func (h *heldObserver) OnTransactionApplied(index int, id string, updates []AccountUpdate) {
+	h.applied = append(h.applied, heldApplied{index, id, updates})
+}
+
+// release makes the held back calls, as OnTransactionFailed calls unless committed
+func (h *heldObserver) release(committed bool) {
+	for _, call := range h.applied {
+		if committed {
+			h.ExecutionObserver.OnTransactionApplied(call.index, call.id, call.updates)
+		} else {
+			h.ExecutionObserver.OnTransactionFailed(call.index, call.id, ErrBlockAborted)
+		}
+	}
+	h.applied = nil
+}
+
+// noopObserver is used when no observer is configured
+type noopObserver struct{}
+
+func (noopObserver) OnTransactionStart(int, string)                    {}
+func (noopObserver) OnTransactionApplied(int, string, []AccountUpdate) {}
+func (noopObserver) OnTransactionFailed(int, string, error)            {}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recordingObserver records every callback it receives
+type recordingObserver struct {
+	started []int
+	ids     map[int]string // IDs reported for each index
+	applied map[int][]AccountUpdate
+	log     [][]AccountUpdate // applied updates in commit order
+	failed  map[int]error
+}
+
+func newRecordingObserver() *recordingObserver {
+	return &recordingObserver{
+		ids:     make(map[int]string),
+		applied: make(map[int][]AccountUpdate),
+		failed:  make(map[int]error),
+	}
+}
+
+func (o *recordingObserver) OnTransactionStart(index int, id string) {
+	o.started = append(o.started, index)
+	o.ids[index] = id
+}
+
+func (o *recordingObserver) OnTransactionApplied(index int, id string, updates []AccountUpdate) {
+	o.ids[index] = id
+	o.applied[index] = updates
+	o.log = append(o.log, updates)
+}
+
+func (o *recordingObserver) OnTransactionFailed(index int, id string, err error) {
+	o.ids[index] = id
+	o.failed[index] = err
+}
+
+func TestExecuteBlock_Observer(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 1000},
+		{Name: "B", Balance: 1000},
+		{Name: "C", Balance: 1000},
+		{Name: "D", Balance: 1000},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 100},
+		transfer{from: "C", to: "D", value: 5000}, // fails
+		transfer{from: "B", to: "C", value: 50},
+		transfer{from: "D", to: "A", value: 75},
+		opaqueTransfer{transfer{from: "A", to: "D", value: 10}},
+	}}
+
+	observer := newRecordingObserver()
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, BlockOptions{Observer: observer}); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	if len(observer.started) != len(block.Transactions) {
+		t.Errorf("Expected %d start events, got %v", len(block.Transactions), observer.started)
+	}
+	for i := range block.Transactions {
+		_, applied := observer.applied[i]
+		_, failed := observer.failed[i]
+		if applied == failed {
+			t.Errorf("Transaction %d: expected exactly one terminal event, got applied=%v failed=%v", i, applied, failed)
+		}
+	}
+	if _, failed := observer.failed[1]; !failed {
+		t.Error("Expected transaction 1 to be reported as failed")
+	}
+	if updates := observer.applied[0]; len(updates) != 2 || updates[0].Name != "A" {
+		t.Errorf("Expected applied updates of transaction 0, got %v", updates)
+	}
+}
+
+func TestExecuteBlock_ObserverOnAbort(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 10},
+		{Name: "C", Balance: 10},
+	})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 100}, // fails
+		transfer{from: "C", to: "D", value: 1},   // dispatched concurrently, then discarded
+		transfer{from: "B", to: "C", value: 1},   // never started
+	}}
+
+	observer := newRecordingObserver()
+	opts := BlockOptions{Mode: AbortOnError, Observer: observer}
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, opts); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if len(observer.started) != 2 {
+		t.Errorf("Expected 2 start events, got %v", observer.started)
+	}
+	if len(observer.applied) != 0 {
+		t.Errorf("Expected no applied events, got %v", observer.applied)
+	}
+	if err := observer.failed[1]; !errors.Is(err, ErrBlockAborted) {
+		t.Errorf("Expected transaction 1 to be reported as aborted, got %v", err)
+	}
+	if _, ok := observer.failed[2]; ok {
+		t.Error("Expected no event for transaction 2")
+	}
+}
+
+// identifiedTransfer is a transfer carrying a correlation ID
+type identifiedTransfer struct {
+	transfer
+	id string
+}
+
+func (t identifiedTransfer) ID() string { return t.id }
+
+func TestExecuteBlock_TransactionIDs(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		identifiedTransfer{transfer{from: "A", to: "B", value: 30}, "req-7"},
+		transfer{from: "A", to: "C", value: 10},                              // no ID, falls back to its index
+		identifiedTransfer{transfer{from: "B", to: "C", value: 50}, "req-9"}, // fails
+		identifiedTransfer{transfer{from: "C", to: "D", value: 5}, "req-3"},
+	}}
+
+	observer := newRecordingObserver()
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Observer: observer})
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+
+	expected := []string{"req-7", "1", "req-9", "req-3"}
+	for i, id := range expected {
+		if got := result.Transactions[i].ID; got != id {
+			t.Errorf("Transaction %d: expected ID %q in result, got %q", i, id, got)
+		}
+		if got := observer.ids[i]; got != id {
+			t.Errorf("Transaction %d: expected ID %q reported to observer, got %q", i, id, got)
+		}
+	}
+	if result.Transactions[2].Applied {
+		t.Errorf("Expected req-9 to fail, got %+v", result.Transactions[2])
+	}
+}
+
+// signallingTransfer is a transfer calling executed once its updates are computed
+type signallingTransfer struct {
+	transfer
+	executed func()
+}
+
+func (t signallingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	defer t.executed()
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlock_OnResultOrdering(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		ordered  bool
+		expected []int
+	}{
+		{"completion order", false, []int{1, 2, 0}},
+		{"index order", true, []int{0, 1, 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// The independent transfers 1 and 2 complete while transfer 0 is held up: until
+			// their results are passed or, when those are held back too, until they executed
+			release := make(chan struct{})
+			var executed sync.WaitGroup
+			executed.Add(2)
+			block := Block{Transactions: []Transaction{
+				blockingTransfer{transfer{from: "A", to: "B", value: 10}, release},
+				signallingTransfer{transfer{from: "C", to: "D", value: 10}, executed.Done},
+				signallingTransfer{transfer{from: "E", to: "F", value: 10}, executed.Done},
+			}}
+			var indices []int
+			opts := BlockOptions{
+				OrderedResults: tt.ordered,
+				OnResult: func(result TxResult) {
+					indices = append(indices, result.Index)
+					if !result.Applied {
+						t.Errorf("Unexpected result %+v", result)
+					}
+					if !tt.ordered && len(indices) == 2 {
+						close(release)
+					}
+				},
+			}
+			if tt.ordered {
+				go func() {
+					executed.Wait()
+					close(release)
+				}()
+			}
+
+			state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}, {Name: "C", Balance: 10}, {Name: "E", Balance: 10}})
+			snapshot, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 3, opts)
+			if err != nil {
+				t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+			}
+			if len(indices) != len(tt.expected) {
+				t.Fatalf("Expected results %v, got %v", tt.expected, indices)
+			}
+			for i := range indices {
+				// Transfers 1 and 2 complete in either order
+				if indices[i] != tt.expected[i] && (tt.ordered || indices[i] == 0) {
+					t.Fatalf("Expected results %v, got %v", tt.expected, indices)
+				}
+			}
+			verifyResults(t, snapshot, map[string]uint{"A": 0, "B": 10, "C": 0, "D": 10, "E": 0, "F": 10})
+		})
+	}
+
+	// Results held back for a transaction that never executes are passed once the block stops.
+	// Every transfer debits A, so they commit by descending priority: 1, 3 and then 2, which
+	// aborts the block as A is drained by then.
+	block := Block{Transactions: []Transaction{
+		prioritizedTransfer{transfer{from: "A", to: "B", value: 40}, 1},
+		prioritizedTransfer{transfer{from: "A", to: "C", value: 30}, 4},
+		prioritizedTransfer{transfer{from: "A", to: "D", value: 20}, 2},
+		prioritizedTransfer{transfer{from: "A", to: "E", value: 10}, 3},
+	}}
+	var indices []int
+	opts := BlockOptions{Mode: AbortOnError, OrderedResults: true, OnResult: func(result TxResult) {
+		indices = append(indices, result.Index)
+	}}
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 40}})
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 4, opts); err == nil {
+		t.Fatal("Expected the block to abort")
+	}
+	if len(indices) != 3 || indices[0] != 1 || indices[1] != 2 || indices[2] != 3 {
+		t.Errorf("Expected results 1, 2 and 3 in order, got %v", indices)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ExecuteBlockOCC executes a block with optimistic concurrency control, as an alternative to
+// the dependency scheduling of ExecuteBlock. Every transaction first executes in parallel
+// against the state as it was before the block, recording the accounts it reads. Transactions
+// are then validated and committed serially by index: a transaction that read an account
+// written by a transaction committed before it is re-executed against the current state before
+// committing. The final state matches sequential execution, and transactions need not declare
+// access sets, making this engine a good fit for blocks with few conflicts.
+func ExecuteBlockOCC(block Block, state AccountState, numWorkers int) ([]AccountValue, BlockResult, error) {
+	if numWorkers < 1 {
+		return nil, BlockResult{}, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+
+	n := len(block.Transactions)
+	jobs := make(chan txJob, numWorkers)
+	results := make(chan txResult, numWorkers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go worker(context.Background(), jobs, results, &wg)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	go func() {
+		for i, tx := range block.Transactions {
+			jobs <- txJob{transaction: tx, index: i, state: state, record: true}
+		}
+		close(jobs)
+	}()
+
+	// Nothing is committed until every transaction has executed, so all of them read the same state
+	tentative := make([]txResult, n)
+	for result := range results {
+		tentative[result.index] = result
+	}
+
+	run := newBlockRun(block, state, make([]accessSet, n), BlockOptions{})
+	written := make(map[string]struct{}) // accounts written by committed transactions
+	for i, result := range tentative {
+		if intersects(result.access.reads, written) {
+			// The transaction read state that has changed since, execute it again
+			meter := &meteringState{state: stateView{state}}
+			result.updates, result.access, result.err = runRecorded(block.Transactions[i], meter)
+			result.index = i
+			result.reads += int(meter.reads.Load())
+		}
+		if err := run.commit(result); err != nil {
+			return nil, run.result, err
+		}
+		if run.result.Transactions[i].Applied {
+			for name := range result.access.writes {
+				written[name] = struct{}{}
+			}
+		}
+	}
+
+	if stateWithSnapshot, ok := state.(interface{ GetSnapshot() []AccountValue }); ok {
+		return stateWithSnapshot.GetSnapshot(), run.result, nil
+	}
+	return []AccountValue{}, run.result, nil
+}
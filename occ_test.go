@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExecuteBlockOCC_RandomBlocksMatchSerial(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	var initialState []AccountValue
+	for _, name := range accounts {
+		initialState = append(initialState, AccountValue{Name: name, Balance: 100})
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 50; round++ {
+		block := randomDependentBlock(rng, accounts, 40)
+		expected, expectedApplied := executeSerially(t, block, initialState)
+
+		for _, numWorkers := range []int{1, 3, 8} {
+			result, blockResult, err := ExecuteBlockOCC(block, NewInMemoryAccountState(initialState), numWorkers)
+			if err != nil {
+				t.Fatalf("Round %d: ExecuteBlockOCC failed with %d workers: %v", round, numWorkers, err)
+			}
+			if !SnapshotsEqual(expected, result) {
+				t.Fatalf("Round %d: results with %d workers differ from serial execution\nexpected: %+v\ngot: %+v",
+					round, numWorkers, expected, result)
+			}
+			for i, tx := range blockResult.Transactions {
+				if tx.Applied != expectedApplied[i] {
+					t.Fatalf("Round %d: transaction %d applied=%v with %d workers, serially %v",
+						round, i, tx.Applied, numWorkers, expectedApplied[i])
+				}
+			}
+		}
+	}
+}
+
+// countingTransfer is a transfer counting how many times it is executed
+type countingTransfer struct {
+	transfer
+	executions *atomic.Int32
+}
+
+func (t countingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	t.executions.Add(1)
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlockOCC_ReExecutesInvalidatedTransactions(t *testing.T) {
+	executions := &atomic.Int32{}
+	block := Block{Transactions: []Transaction{
+		countingTransfer{transfer{from: "A", to: "B", value: 50}, executions},
+		countingTransfer{transfer{from: "B", to: "C", value: 50}, executions}, // reads B written by 0
+		countingTransfer{transfer{from: "C", to: "D", value: 50}, executions}, // reads C written by 1
+		countingTransfer{transfer{from: "E", to: "F", value: 5}, executions},  // independent
+	}}
+
+	snapshot, result, err := ExecuteBlockOCC(block, NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 50},
+		{Name: "E", Balance: 5},
+	}), 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlockOCC failed: %v", err)
+	}
+
+	// Executed against the initial state, transactions 1 and 2 fail for lack of funds.
+	// Re-executed after their predecessors commit, they succeed.
+	for i, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Expected transaction %d to be applied, got %+v", i, tx)
+		}
+	}
+	if n := executions.Load(); n != 6 {
+		t.Errorf("Expected 6 executions including 2 re-executions, got %d", n)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 0, "B": 0, "C": 0, "D": 50, "E": 0, "F": 5})
+}
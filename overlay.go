@@ -0,0 +1,147 @@
+package main
+
+import (
+	"sync"
+)
+
+// overlayState buffers updates on top of an underlying state without modifying it.
+// Reads observe the underlying state with the buffered updates applied, so transactions
+// executed against the overlay see the effects of earlier transactions in the same block.
+type overlayState struct {
+	base           ReadOnlyState
+	clampUnderflow bool                // mirrors the underflow policy of base
+	names          func(string) string // mirrors the name normalization of base, nil without one
+	mu             sync.RWMutex
+	accounts       map[string]accountEntry // accounts touched by buffered updates
+	updates        []AccountUpdate         // buffered updates in the order they were applied
+	origins        []ledgerOrigin          // transactions that buffered each update
+}
+
+func newOverlayState(base ReadOnlyState) *overlayState {
+	overlay := &overlayState{
+		base:     base,
+		accounts: make(map[string]accountEntry),
+	}
+	if clamper, ok := base.(interface{ clampsUnderflow() bool }); ok {
+		overlay.clampUnderflow = clamper.clampsUnderflow()
+	}
+	overlay.names = nameNormalizerOf(base)
+	return overlay
+}
+
+// normalizeAccountName implements nameNormalizer by normalizing names as the base does, so
+// that buffered updates are keyed by the account they are committed to
+func (o *overlayState) normalizeAccountName(name string) string {
+	if o.names == nil {
+		return name
+	}
+	return o.names(name)
+}
+
+// updateValidator is implemented by states constraining updates beyond their balances, such
+// as frozen accounts and minimum balances. Overlays check buffered updates against the
+// constraints of their base, so that an update violating them fails when it's buffered rather
+// than when the buffer is committed.
+type updateValidator interface {
+	// validateUpdate checks update given the native balance of its account after it
+	validateUpdate(update AccountUpdate, balance uint) error
+}
+
+// validateUpdate implements updateValidator by checking the constraints of the base
+func (o *overlayState) validateUpdate(update AccountUpdate, balance uint) error {
+	if validator, ok := o.base.(updateValidator); ok {
+		return validator.validateUpdate(update, balance)
+	}
+	return nil
+}
+
+// GetAccount implements AccountState interface
+func (o *overlayState) GetAccount(name string) AccountValue {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	name = o.normalizeAccountName(name)
+	if entry, ok := o.accounts[name]; ok {
+		value := AccountValue{
+			Name:    name,
+			Balance: entry.balance,
+		}
+		if len(entry.assets) > 0 {
+			value.Assets = copyBalances(entry.assets)
+		}
+		if entry.exists {
+			// Updates don't change metadata
+			value.Metadata = o.base.GetAccount(name).Metadata
+		}
+		return value
+	}
+	return o.base.GetAccount(name)
+}
+
+// HasAccount implements AccountState interface
+func (o *overlayState) HasAccount(name string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	name = o.normalizeAccountName(name)
+	if entry, ok := o.accounts[name]; ok {
+		return entry.exists
+	}
+	return o.base.HasAccount(name)
+}
+
+// ApplyUpdates implements AccountState interface by buffering the updates
+func (o *overlayState) ApplyUpdates(updates []AccountUpdate) error {
+	return o.applyUpdates(updates, -1, -1)
+}
+
+// applyUpdates buffers updates committed by the transaction at txIndex of the block at
+// blockIndex, which are recorded in ledger entries when the buffer is committed
+func (o *overlayState) applyUpdates(updates []AccountUpdate, blockIndex, txIndex int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	staged := make(map[string]accountEntry, len(updates))
+	for _, update := range updates {
+		name := o.normalizeAccountName(update.Name)
+		entry, ok := staged[name]
+		if !ok {
+			entry, ok = o.accounts[name]
+		}
+		if !ok {
+			acc := o.base.GetAccount(name)
+			entry = accountEntry{
+				balance: acc.Balance,
+				assets:  acc.Assets,
+				exists:  o.base.HasAccount(name),
+			}
+		}
+
+		entry, err := applyUpdate(entry, update, o.clampUnderflow)
+		if err != nil {
+			return err
+		}
+		if err := o.validateUpdate(update, entry.balance); err != nil {
+			return err
+		}
+		staged[name] = entry
+	}
+
+	for name, entry := range staged {
+		o.accounts[name] = entry
+	}
+	o.updates = append(o.updates, updates...)
+	for range updates {
+		o.origins = append(o.origins, ledgerOrigin{blockIndex, txIndex})
+	}
+	return nil
+}
+
+// bufferedUpdates returns every update applied to the overlay, in order, and the transaction
+// that buffered each
+func (o *overlayState) bufferedUpdates() ([]AccountUpdate, []ledgerOrigin) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return append([]AccountUpdate(nil), o.updates...), append([]ledgerOrigin(nil), o.origins...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Replay reconstructs state by applying a log of recorded updates to initial, without running
+// any transaction logic. Each entry of updateLog holds the updates of one applied transaction,
+// in commit order, as reported to ExecutionObserver.OnTransactionApplied. Entries are applied
+// exactly as during live execution, so an entry that would overflow or underflow a balance
+// stops the replay with an error naming it.
+func Replay(initial []AccountValue, updateLog [][]AccountUpdate) ([]AccountValue, error) {
+	state := NewInMemoryAccountState(initial)
+	for i, updates := range updateLog {
+		if err := state.ApplyUpdates(updates); err != nil {
+			return nil, fmt.Errorf("replay entry %d: %w", i, err)
+		}
+	}
+	return state.GetSnapshot(), nil
+}
+
+// ReverseBlock undoes a committed block, for example when handling a reorg. Each entry of
+// updates holds the updates of one applied transaction in commit order, as in Replay. The
+// negation of every update is applied in reverse order, as a single call to ApplyUpdates, so
+// with InMemoryAccountState either the whole block is reversed or, on error, none of it. A
+// reversed credit is a debit and a reversed debit a credit, failing just like they would, with
+// ErrInsufficientBalance if the credited funds were spent since or ErrOverflow if a balance
+// can no longer hold the debited ones. Only balance changes can be negated: other operations
+// fail with ErrUnsupportedOperation. Debits clamped by SetClampUnderflow aren't reversed
+// exactly, since the amount they actually removed isn't recorded, and accounts the block
+// created by crediting them remain, with zero balances.
+func ReverseBlock(state AccountState, updates [][]AccountUpdate) error {
+	var reversed []AccountUpdate
+	for i := len(updates) - 1; i >= 0; i-- {
+		for j := len(updates[i]) - 1; j >= 0; j-- {
+			update := updates[i][j]
+			if update.Op != OpBalanceChange || update.BalanceChange == math.MinInt {
+				return fmt.Errorf("%w: can't reverse update %d of entry %d", ErrUnsupportedOperation, j, i)
+			}
+			update.BalanceChange = -update.BalanceChange
+			reversed = append(reversed, update)
+		}
+	}
+	if len(reversed) == 0 {
+		return nil
+	}
+	return applyUpdatesTo(state, reversed, -1, -1)
+}
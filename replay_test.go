@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestReplay_ReproducesExecutedBlocks(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50},
+		{Name: "C", Balance: 10},
+	}
+	blocks := []Block{
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 30},
+			transfer{from: "C", to: "D", value: 20}, // fails
+			prioritizedTransfer{transfer{from: "B", to: "C", value: 60}, 1},
+		}},
+		{Transactions: []Transaction{
+			transfer{from: "B", to: "A", value: 15},
+			transfer{from: "C", to: "E", value: 70},
+		}},
+	}
+
+	state := NewInMemoryAccountState(initialState)
+	observer := newRecordingObserver()
+	var snapshot []AccountValue
+	for _, block := range blocks {
+		var err error
+		snapshot, _, err = ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{Observer: observer})
+		if err != nil {
+			t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+		}
+	}
+
+	replayed, err := Replay(initialState, observer.log)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if !SnapshotsEqual(snapshot, replayed) {
+		t.Errorf("Expected replayed state %+v, got %+v", snapshot, replayed)
+	}
+}
+
+func TestReplay_RejectsInvalidEntries(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: math.MaxUint}}
+
+	_, err := Replay(initialState, [][]AccountUpdate{
+		{{Name: "A", BalanceChange: -1}},
+		{{Name: "A", BalanceChange: 2}},
+	})
+	if !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+
+	_, err = Replay(initialState, [][]AccountUpdate{{{Name: "B", BalanceChange: -1}}})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+}
+
+func TestReverseBlock(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: math.MaxUint - 10},
+	})
+	before := state.GetSnapshot()
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "C", value: 60},
+		transfer{from: "C", to: "D", value: 50},
+		transfer{from: "D", to: "D", value: 50},
+		transfer{from: "A", to: "B", value: 50}, // fails with an overflow
+	}}
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	var updates [][]AccountUpdate
+	for _, tx := range result.Transactions {
+		if tx.Applied {
+			updates = append(updates, tx.Updates)
+		}
+	}
+
+	if err := ReverseBlock(state, updates); err != nil {
+		t.Fatalf("ReverseBlock failed: %v", err)
+	}
+	if snapshot := state.GetSnapshot(); !SnapshotsEqualWithOptions(before, snapshot, SnapshotOptions{AbsentAsZero: true}) {
+		t.Errorf("Expected the pre-block state %+v, got %+v", before, snapshot)
+	}
+
+	// Reversing a credit whose funds were spent since fails without changing anything
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", BalanceChange: 10}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	spent := [][]AccountUpdate{{{Name: "A", BalanceChange: -5}, {Name: "C", BalanceChange: 5}}}
+	if err := ReverseBlock(state, spent); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	// Reversing a debit that no longer fits overflows
+	overflowing := [][]AccountUpdate{{{Name: "B", BalanceChange: -1}}}
+	if err := ReverseBlock(state, overflowing); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if err := ReverseBlock(state, [][]AccountUpdate{{{Name: "A", Op: OpSetBalance, Balance: 1}}}); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100, "B": math.MaxUint, "C": 0, "D": 0})
+}
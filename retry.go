@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy configures how transactions failing transiently are re-executed
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times Updates is invoked for a transaction.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// Backoff is the delay between attempts
+	Backoff time.Duration
+}
+
+// Retryable is implemented by errors that describe transient failures. A transaction whose
+// Updates fails with an error wrapping a Retryable error reporting true is re-executed
+// according to the block's RetryPolicy; any other error fails the transaction immediately.
+type Retryable interface {
+	Retryable() bool
+}
+
+// isRetryable reports whether err wraps a Retryable error reporting true
+func isRetryable(err error) bool {
+	var retryable Retryable
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// execute calls run until it succeeds, fails with a non-retryable error, exhausts the
+// policy's attempts or ctx is done, and returns the last result
+func (p RetryPolicy) execute(ctx context.Context, run func() ([]AccountUpdate, accessSet, error)) ([]AccountUpdate, accessSet, error) {
+	for attempt := 1; ; attempt++ {
+		updates, access, err := run()
+		if err == nil || attempt >= p.MaxAttempts || !isRetryable(err) {
+			return updates, access, err
+		}
+
+		timer := time.NewTimer(p.Backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return updates, access, err
+		}
+	}
+}
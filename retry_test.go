@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// transientError is a retryable error
+type transientError struct{}
+
+func (transientError) Error() string   { return "oracle unavailable" }
+func (transientError) Retryable() bool { return true }
+
+// flakyTransfer is a transfer whose Updates fails with err for the first failures attempts
+type flakyTransfer struct {
+	transfer
+	failures int
+	err      error
+	attempts *atomic.Int32
+}
+
+func (t flakyTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if int(t.attempts.Add(1)) <= t.failures {
+		return nil, t.err
+	}
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlock_RetriesTransientFailures(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	attempts := &atomic.Int32{}
+	block := Block{Transactions: []Transaction{
+		flakyTransfer{transfer{from: "A", to: "B", value: 40}, 2, transientError{}, attempts},
+	}}
+
+	opts := BlockOptions{Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}}
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if !result.Transactions[0].Applied {
+		t.Errorf("Expected transaction to be applied, got %+v", result.Transactions[0])
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 60, "B": 40})
+}
+
+func TestExecuteBlock_RetryLimits(t *testing.T) {
+	opts := BlockOptions{Retry: RetryPolicy{MaxAttempts: 3}}
+	permanent := errors.New("invalid signature")
+
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		attempts int32
+	}{
+		{"attempts exhausted", 5, transientError{}, 3},
+		{"non-retryable", 5, permanent, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+			attempts := &atomic.Int32{}
+			block := Block{Transactions: []Transaction{
+				flakyTransfer{transfer{from: "A", to: "B", value: 40}, tt.failures, tt.err, attempts},
+			}}
+
+			_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 1, opts)
+			if err != nil {
+				t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+			}
+			if r := result.Transactions[0]; r.Applied || !errors.Is(r.Err, tt.err) {
+				t.Errorf("Expected transaction to fail with %v, got %+v", tt.err, r)
+			}
+			if n := attempts.Load(); n != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, n)
+			}
+		})
+	}
+}
+
+// blockingTransfer is a transfer whose Updates doesn't return until release is closed
+type blockingTransfer struct {
+	transfer
+	release chan struct{}
+}
+
+func (t blockingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	<-t.release
+	return t.transfer.Updates(state)
+}
+
+func TestExecuteBlock_TxTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		blockingTransfer{transfer{from: "A", to: "C", value: 10}, release},
+		transfer{from: "A", to: "D", value: 10},
+	}}
+
+	opts := BlockOptions{TxTimeout: 10 * time.Millisecond}
+	snapshot, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if r := result.Transactions[1]; r.Applied || !errors.Is(r.Err, ErrTransactionTimeout) {
+		t.Errorf("Expected transaction 1 to time out, got %+v", r)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 80, "B": 10, "D": 10})
+}
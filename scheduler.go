@@ -0,0 +1,669 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// AccessAware is implemented by transactions that declare up front which accounts they
+// read and write, allowing the executor to schedule non-conflicting transactions
+// concurrently without executing them first.
+//
+// The declared sets are a contract: writes must be a superset of every account the
+// transaction's updates touch, and reads must be a superset of every account it reads
+// through the state passed to Updates. Transactions that don't implement AccessAware are
+// assumed to conflict with every other transaction and are executed on their own, unless
+// they are ReadOnly.
+type AccessAware interface {
+	AccessSet() (reads []string, writes []string)
+}
+
+// Prioritized is implemented by transactions that declare a priority, such as a fee. Among
+// conflicting transactions in a block, higher-priority ones are committed first; transactions
+// with equal priority keep their original order. Transactions that don't implement Prioritized
+// have priority 0.
+type Prioritized interface {
+	Priority() int
+}
+
+// Sourced is implemented by transactions that identify their sender, such as the account that
+// signed them. With BlockOptions.FairScheduling, senders take turns in serial order.
+type Sourced interface {
+	Sender() string
+}
+
+// senderOf returns the sender of tx. Transactions that don't implement Sourced share the
+// empty sender.
+func senderOf(tx Transaction) string {
+	if s, ok := tx.(Sourced); ok {
+		return s.Sender()
+	}
+	return ""
+}
+
+// priorityOf returns the priority of tx, defaulting to 0
+func priorityOf(tx Transaction) int {
+	if p, ok := tx.(Prioritized); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// ReadOnly is a marker interface for transactions that only read state and never return
+// updates, such as balance checks. Read-only transactions don't conflict with each other, so
+// they run in parallel even when they don't implement AccessAware, in which case they are
+// assumed to read every account. A read-only transaction implementing AccessAware may only
+// declare reads. Returning updates from a read-only transaction fails the block with
+// ErrAccessSetViolation.
+type ReadOnly interface {
+	ReadOnly()
+}
+
+// accessSet records the accounts a transaction reads and writes
+type accessSet struct {
+	reads    map[string]struct{}
+	writes   map[string]struct{}
+	all      bool // conflicts with every other transaction
+	readsAll bool // reads every account but writes none
+}
+
+func newAccessSet() accessSet {
+	return accessSet{
+		reads:  make(map[string]struct{}),
+		writes: make(map[string]struct{}),
+	}
+}
+
+// accessDeclarer is implemented by transactions whose access set is derived from other
+// transactions, such as CompositeTransaction
+type accessDeclarer interface {
+	declaredAccess() accessSet
+}
+
+// declaredAccessSet returns the access set declared by tx, or a conservative
+// conflicts-with-everything set when tx doesn't declare one
+func declaredAccessSet(tx Transaction) accessSet {
+	if declarer, ok := tx.(accessDeclarer); ok {
+		return declarer.declaredAccess()
+	}
+	_, readOnly := tx.(ReadOnly)
+	aware, ok := tx.(AccessAware)
+	if !ok {
+		if readOnly {
+			access := newAccessSet()
+			access.readsAll = true
+			return access
+		}
+		return accessSet{all: true}
+	}
+
+	reads, writes := aware.AccessSet()
+	access := newAccessSet()
+	for _, name := range reads {
+		access.reads[name] = struct{}{}
+	}
+	if !readOnly {
+		for _, name := range writes {
+			access.writes[name] = struct{}{}
+		}
+	}
+	return access
+}
+
+// normalized returns the access set with its names passed through normalize, or the set itself
+// if normalize is nil
+func (a accessSet) normalized(normalize func(string) string) accessSet {
+	if normalize == nil || a.all {
+		return a
+	}
+	n := newAccessSet()
+	n.readsAll = a.readsAll
+	for name := range a.reads {
+		n.reads[normalize(name)] = struct{}{}
+	}
+	for name := range a.writes {
+		n.writes[normalize(name)] = struct{}{}
+	}
+	return n
+}
+
+// addWrites marks every account touched by updates as written
+func (a accessSet) addWrites(updates []AccountUpdate) {
+	for _, update := range updates {
+		a.writes[update.Name] = struct{}{}
+	}
+}
+
+// conflicts reports whether two transactions cannot safely run concurrently,
+// i.e. one of them writes an account the other reads or writes.
+func (a accessSet) conflicts(b accessSet) bool {
+	if a.all || b.all {
+		return true
+	}
+	if a.readsAll || b.readsAll {
+		return len(a.writes) > 0 || len(b.writes) > 0
+	}
+	return intersects(a.writes, b.reads) || intersects(a.writes, b.writes) || intersects(b.writes, a.reads)
+}
+
+// within returns an error naming the accounts actually accessed outside the declared set
+func (a accessSet) within(declared accessSet) error {
+	if declared.all {
+		return nil
+	}
+
+	var undeclaredReads, undeclaredWrites []string
+	for name := range a.reads {
+		if declared.readsAll {
+			break
+		}
+		_, read := declared.reads[name]
+		_, written := declared.writes[name]
+		if !read && !written {
+			undeclaredReads = append(undeclaredReads, name)
+		}
+	}
+	for name := range a.writes {
+		if _, ok := declared.writes[name]; !ok {
+			undeclaredWrites = append(undeclaredWrites, name)
+		}
+	}
+
+	if len(undeclaredReads) == 0 && len(undeclaredWrites) == 0 {
+		return nil
+	}
+	sort.Strings(undeclaredReads)
+	sort.Strings(undeclaredWrites)
+	return fmt.Errorf("%w: undeclared reads %v, undeclared writes %v", ErrAccessSetViolation, undeclaredReads, undeclaredWrites)
+}
+
+func intersects(a, b map[string]struct{}) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for name := range a {
+		if _, ok := b[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingState wraps a state and records every account read through it
+type recordingState struct {
+	state ReadOnlyState
+	mu    sync.Mutex
+	reads map[string]struct{}
+}
+
+func newRecordingState(state ReadOnlyState) *recordingState {
+	return &recordingState{
+		state: state,
+		reads: make(map[string]struct{}),
+	}
+}
+
+// GetAccount implements ReadOnlyState interface and records the read
+func (r *recordingState) GetAccount(name string) AccountValue {
+	r.mu.Lock()
+	r.reads[name] = struct{}{}
+	r.mu.Unlock()
+	return r.state.GetAccount(name)
+}
+
+// HasAccount implements ReadOnlyState interface and records the read
+func (r *recordingState) HasAccount(name string) bool {
+	r.mu.Lock()
+	r.reads[name] = struct{}{}
+	r.mu.Unlock()
+	return r.state.HasAccount(name)
+}
+
+// meteringState wraps a state and counts the reads made through it
+type meteringState struct {
+	state ReadOnlyState
+	reads atomic.Int64
+}
+
+// GetAccount implements ReadOnlyState interface and counts the read
+func (m *meteringState) GetAccount(name string) AccountValue {
+	m.reads.Add(1)
+	return m.state.GetAccount(name)
+}
+
+// HasAccount implements ReadOnlyState interface and counts the read
+func (m *meteringState) HasAccount(name string) bool {
+	m.reads.Add(1)
+	return m.state.HasAccount(name)
+}
+
+// runRecorded executes a transaction against state and returns its updates together
+// with the accounts it actually accessed.
+func runRecorded(tx Transaction, state ReadOnlyState) ([]AccountUpdate, accessSet, error) {
+	recorder := newRecordingState(state)
+	updates, err := callUpdates(tx, recorder)
+
+	access := newAccessSet()
+	access.reads = recorder.reads
+	access.addWrites(updates)
+	return updates, access, err
+}
+
+// DependencyScheduler orders a block's transactions as a DAG built from their declared access
+// sets. Transactions are first put in serial order: by descending priority (see Prioritized),
+// then by original index, or by sender round with BlockOptions.FairScheduling. A transaction
+// depends on every transaction before it in serial order that writes an account it reads or
+// writes, and on every such transaction that reads an account it writes. Transactions that
+// don't implement AccessAware depend on, and are depended on by, every other transaction;
+// read-only ones only on, and by, every writing transaction. A block's explicit Dependencies,
+// if built with NewBlockScheduler, are added to these.
+//
+// Executing each transaction only once all of its dependencies have committed yields the same
+// final state as executing the block sequentially in serial order. In particular, when two
+// conflicting transactions compete for the same funds, the one earlier in serial order (the
+// lower-indexed one, at equal priority) always wins, however the workers are scheduled.
+type DependencyScheduler struct {
+	access     []accessSet
+	deps       [][]int // deps[i] holds the transactions i must wait for, in ascending order
+	dependents [][]int // dependents[i] holds the transactions waiting for i, in serial order
+	rank       []int   // rank[i] is the position of transaction i in serial order
+	order      []int
+}
+
+// NewDependencyScheduler builds the dependency graph of the given transactions
+func NewDependencyScheduler(transactions []Transaction) *DependencyScheduler {
+	s, _ := newDependencyScheduler(transactions, nil, false, nil, nil)
+	return s
+}
+
+// NewBlockScheduler builds the dependency graph of a block's transactions, including its
+// explicit Dependencies, or from its Groups if set. It fails with ErrInvalidDependency if a
+// dependency refers to a transaction outside the block, with ErrInvalidGroups if the groups
+// don't partition the block and with ErrDependencyCycle if the graph has a cycle.
+func NewBlockScheduler(block Block) (*DependencyScheduler, error) {
+	return newBlockScheduler(block, false, nil, nil)
+}
+
+// newBlockScheduler is NewBlockScheduler, interleaving senders in serial order if fair is set.
+// Transactions writing an account of hot without reading it don't conflict with each other,
+// see BlockOptions.HotAccounts. Declared account names are passed through names unless it is
+// nil; hot must already be normalized.
+func newBlockScheduler(block Block, fair bool, hot []string, names func(string) string) (*DependencyScheduler, error) {
+	for i, deps := range block.Dependencies {
+		for _, j := range append([]int{i}, deps...) {
+			if j < 0 || j >= len(block.Transactions) {
+				return nil, fmt.Errorf("%w: transaction %d depends on %v", ErrInvalidDependency, i, deps)
+			}
+		}
+	}
+	if block.Groups != nil {
+		return newGroupScheduler(block.Transactions, block.Groups, block.Dependencies, fair)
+	}
+	return newDependencyScheduler(block.Transactions, block.Dependencies, fair, hot, names)
+}
+
+// newGroupScheduler builds the dependency graph of transactions partitioned into groups: each
+// transaction depends on the one before it in its group, and on its explicit dependencies.
+// Access sets aren't analyzed, and are treated as declaring every account when validated.
+func newGroupScheduler(transactions []Transaction, groups [][]int, explicit map[int][]int, fair bool) (*DependencyScheduler, error) {
+	s := &DependencyScheduler{
+		access:     make([]accessSet, len(transactions)),
+		deps:       make([][]int, len(transactions)),
+		dependents: make([][]int, len(transactions)),
+		rank:       make([]int, len(transactions)),
+	}
+
+	grouped := make([]bool, len(transactions))
+	count := 0
+	for g, group := range groups {
+		for k, i := range group {
+			if i < 0 || i >= len(transactions) || grouped[i] {
+				return nil, fmt.Errorf("%w: group %d lists %d", ErrInvalidGroups, g, i)
+			}
+			grouped[i] = true
+			count++
+			if k > 0 {
+				s.deps[i] = append(s.deps[i], group[k-1])
+			}
+		}
+	}
+	if count < len(transactions) {
+		return nil, fmt.Errorf("%w: %d of %d transactions are in no group", ErrInvalidGroups, len(transactions)-count, len(transactions))
+	}
+
+	serial := serialOrder(transactions, fair)
+	for position, i := range serial {
+		s.rank[i] = position
+	}
+	for _, i := range serial {
+		s.access[i] = accessSet{all: true}
+		for _, j := range explicit[i] {
+			if !slices.Contains(s.deps[i], j) {
+				s.deps[i] = append(s.deps[i], j)
+			}
+		}
+		sort.Ints(s.deps[i])
+		for _, j := range s.deps[i] {
+			s.dependents[j] = append(s.dependents[j], i)
+		}
+	}
+
+	s.order = s.topologicalOrder()
+	if len(s.order) < len(transactions) {
+		return nil, fmt.Errorf("%w: only %d of %d transactions can be ordered", ErrDependencyCycle, len(s.order), len(transactions))
+	}
+	return s, nil
+}
+
+// newDependencyScheduler builds the dependency graph of the given transactions, adding the
+// explicit dependencies, which must refer to valid indices. fair selects the serial order,
+// and names, unless nil, normalizes the declared account names.
+//
+// A transaction writing a hot account without reading it, such as a transfer crediting it,
+// has its credits deferred to the end of the block, so it doesn't conflict with other such
+// blind writers: their other updates to the account don't depend on its balance, and are still
+// committed in serial order. Blind writers do conflict with transactions reading the account or
+// writing it after reading it, which must observe their other updates as in serial execution.
+func newDependencyScheduler(transactions []Transaction, explicit map[int][]int, fair bool, hot []string, names func(string) string) (*DependencyScheduler, error) {
+	s := &DependencyScheduler{
+		access:     make([]accessSet, len(transactions)),
+		deps:       make([][]int, len(transactions)),
+		dependents: make([][]int, len(transactions)),
+		rank:       make([]int, len(transactions)),
+	}
+
+	serial := serialOrder(transactions, fair)
+	for position, i := range serial {
+		s.rank[i] = position
+	}
+
+	barrier := -1          // last transaction conflicting with everything
+	var sinceBarrier []int // transactions after the barrier
+	var allReaders []int   // read-only transactions reading every account since the barrier
+	lastWriter := make(map[string]int)
+	readersSinceWrite := make(map[string][]int)
+	blindWriters := make(map[string][]int) // blind writers of hot accounts since their last writer
+	isHot := make(map[string]bool, len(hot))
+	for _, name := range hot {
+		isHot[name] = true
+	}
+
+	for _, i := range serial {
+		access := declaredAccessSet(transactions[i]).normalized(names)
+		s.access[i] = access
+
+		deps := make(map[int]struct{})
+		if barrier >= 0 {
+			deps[barrier] = struct{}{}
+		}
+
+		if access.all {
+			for _, j := range sinceBarrier {
+				deps[j] = struct{}{}
+			}
+			barrier, sinceBarrier, allReaders = i, nil, nil
+			lastWriter = make(map[string]int)
+			readersSinceWrite = make(map[string][]int)
+			blindWriters = make(map[string][]int)
+		} else if access.readsAll {
+			for _, j := range lastWriter {
+				deps[j] = struct{}{}
+			}
+			for _, writers := range blindWriters {
+				for _, j := range writers {
+					deps[j] = struct{}{}
+				}
+			}
+			allReaders = append(allReaders, i)
+			sinceBarrier = append(sinceBarrier, i)
+		} else {
+			blind := func(name string) bool {
+				_, read := access.reads[name]
+				return isHot[name] && !read
+			}
+			for name := range access.reads {
+				if j, ok := lastWriter[name]; ok {
+					deps[j] = struct{}{}
+				}
+				for _, j := range blindWriters[name] {
+					deps[j] = struct{}{}
+				}
+			}
+			for name := range access.writes {
+				if j, ok := lastWriter[name]; ok {
+					deps[j] = struct{}{}
+				}
+				for _, j := range readersSinceWrite[name] {
+					deps[j] = struct{}{}
+				}
+				if !blind(name) {
+					for _, j := range blindWriters[name] {
+						deps[j] = struct{}{}
+					}
+				}
+			}
+			if len(access.writes) > 0 {
+				for _, j := range allReaders {
+					deps[j] = struct{}{}
+				}
+			}
+
+			for name := range access.reads {
+				if _, written := access.writes[name]; !written {
+					readersSinceWrite[name] = append(readersSinceWrite[name], i)
+				}
+			}
+			for name := range access.writes {
+				if blind(name) {
+					blindWriters[name] = append(blindWriters[name], i)
+					continue
+				}
+				lastWriter[name] = i
+				delete(readersSinceWrite, name)
+				delete(blindWriters, name)
+			}
+			sinceBarrier = append(sinceBarrier, i)
+		}
+		for _, j := range explicit[i] {
+			deps[j] = struct{}{}
+		}
+
+		for j := range deps {
+			s.deps[i] = append(s.deps[i], j)
+		}
+		sort.Ints(s.deps[i])
+		for _, j := range s.deps[i] {
+			s.dependents[j] = append(s.dependents[j], i)
+		}
+	}
+
+	s.order = s.topologicalOrder()
+	if len(s.order) < len(transactions) {
+		return nil, fmt.Errorf("%w: only %d of %d transactions can be ordered", ErrDependencyCycle, len(s.order), len(transactions))
+	}
+	return s, nil
+}
+
+// serialOrder returns the indices of the transactions sorted by descending priority, then by
+// index. If fair is set, transactions of equal priority are instead sorted by round, then by
+// index, where a transaction's round is the number of transactions of its sender before it: the
+// senders' first transactions come first, then their second ones, and so on.
+func serialOrder(transactions []Transaction, fair bool) []int {
+	serial := make([]int, len(transactions))
+	round := make([]int, len(transactions))
+	sent := make(map[string]int)
+	for i, tx := range transactions {
+		serial[i] = i
+		if fair {
+			sender := senderOf(tx)
+			round[i] = sent[sender]
+			sent[sender]++
+		}
+	}
+	sort.SliceStable(serial, func(a, b int) bool {
+		i, j := serial[a], serial[b]
+		if pi, pj := priorityOf(transactions[i]), priorityOf(transactions[j]); pi != pj {
+			return pi > pj
+		}
+		return round[i] < round[j]
+	})
+	return serial
+}
+
+// topologicalOrder returns an order of the transactions respecting all dependencies,
+// breaking ties by serial order
+func (s *DependencyScheduler) topologicalOrder() []int {
+	remaining := make([]int, len(s.deps))
+	ready := &rankHeap{rank: s.rank}
+	for i, deps := range s.deps {
+		remaining[i] = len(deps)
+		if remaining[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	order := make([]int, 0, len(s.deps))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		order = append(order, i)
+		for _, j := range s.dependents[i] {
+			remaining[j]--
+			if remaining[j] == 0 {
+				heap.Push(ready, j)
+			}
+		}
+	}
+	return order
+}
+
+// Order returns the order in which the executor commits the transactions
+func (s *DependencyScheduler) Order() []int {
+	return append([]int(nil), s.order...)
+}
+
+// Dependencies returns the transactions that must commit before transaction i may execute
+func (s *DependencyScheduler) Dependencies(i int) []int {
+	return append([]int(nil), s.deps[i]...)
+}
+
+// ConflictStats describes how parallelizable a block is. Transactions connected, directly or
+// through others, by dependencies form a group; different groups never wait for each other.
+type ConflictStats struct {
+	// IndependentGroups is the number of groups
+	IndependentGroups int
+	// LargestGroup is the number of transactions in the largest group, which bounds how much
+	// of the block can run in parallel with the rest
+	LargestGroup int
+	// SerializedTransactions is the number of transactions that depend on at least one other
+	SerializedTransactions int
+}
+
+// Stats returns the conflict statistics of the dependency graph
+func (s *DependencyScheduler) Stats() ConflictStats {
+	parent := make([]int, len(s.deps))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	var stats ConflictStats
+	for i, deps := range s.deps {
+		if len(deps) > 0 {
+			stats.SerializedTransactions++
+		}
+		for _, j := range deps {
+			parent[find(i)] = find(j)
+		}
+	}
+	sizes := make(map[int]int)
+	for i := range parent {
+		sizes[find(i)]++
+	}
+	stats.IndependentGroups = len(sizes)
+	for _, size := range sizes {
+		stats.LargestGroup = max(stats.LargestGroup, size)
+	}
+	return stats
+}
+
+// rankHeap is a heap of transaction indices ordered by ascending rank
+type rankHeap struct {
+	indices []int
+	rank    []int
+}
+
+func (h rankHeap) Len() int           { return len(h.indices) }
+func (h rankHeap) Less(i, j int) bool { return h.rank[h.indices[i]] < h.rank[h.indices[j]] }
+func (h rankHeap) Swap(i, j int)      { h.indices[i], h.indices[j] = h.indices[j], h.indices[i] }
+func (h *rankHeap) Push(x any)        { h.indices = append(h.indices, x.(int)) }
+func (h *rankHeap) Pop() any {
+	old := h.indices
+	x := old[len(old)-1]
+	h.indices = old[:len(old)-1]
+	return x
+}
+
+// resolveConflicts returns the transactions with those rejected by onConflict replaced by
+// transactions failing with ErrConflictRejected that don't access any account. fair selects
+// the serial order in which writers are passed to onConflict, and names, unless nil,
+// normalizes the declared account names.
+func resolveConflicts(transactions []Transaction, onConflict func(account string, txs []int) []int, fair bool, names func(string) string) []Transaction {
+	writers := make(map[string][]int)
+	for _, i := range serialOrder(transactions, fair) {
+		for name := range declaredAccessSet(transactions[i]).normalized(names).writes {
+			writers[name] = append(writers[name], i)
+		}
+	}
+	var contested []string
+	for name, txs := range writers {
+		if len(txs) > 1 {
+			contested = append(contested, name)
+		}
+	}
+	sort.Strings(contested)
+
+	rejected := make(map[int]struct{})
+	for _, name := range contested {
+		allowed := make(map[int]struct{})
+		for _, i := range onConflict(name, append([]int(nil), writers[name]...)) {
+			allowed[i] = struct{}{}
+		}
+		for _, i := range writers[name] {
+			if _, ok := allowed[i]; !ok {
+				rejected[i] = struct{}{}
+			}
+		}
+	}
+	if len(rejected) == 0 {
+		return transactions
+	}
+
+	resolved := append([]Transaction(nil), transactions...)
+	for i := range rejected {
+		resolved[i] = rejectedTransaction{id: transactionID(transactions[i], i)}
+	}
+	return resolved
+}
+
+// rejectedTransaction stands in for a transaction rejected by conflict resolution, keeping its ID
+type rejectedTransaction struct {
+	id string
+}
+
+func (t rejectedTransaction) ID() string { return t.id }
+
+func (rejectedTransaction) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	return nil, ErrConflictRejected
+}
+
+func (rejectedTransaction) AccessSet() ([]string, []string) { return nil, nil }
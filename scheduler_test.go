@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDependencyScheduler_Dependencies(t *testing.T) {
+	transactions := []Transaction{
+		transfer{from: "A", to: "B", value: 100}, // T1: A->B
+		transfer{from: "C", to: "D", value: 200}, // T2: C->D (independent from T1)
+		transfer{from: "B", to: "E", value: 50},  // T3: depends on T1
+		transfer{from: "D", to: "A", value: 75},  // T4: depends on T1 and T2
+		transfer{from: "E", to: "C", value: 25},  // T5: depends on T2 and T3
+	}
+
+	scheduler := NewDependencyScheduler(transactions)
+
+	expected := [][]int{{}, {}, {0}, {0, 1}, {1, 2}}
+	for i, deps := range expected {
+		if got := scheduler.Dependencies(i); fmt.Sprint(got) != fmt.Sprint(deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, got)
+		}
+	}
+	if order := scheduler.Order(); fmt.Sprint(order) != "[0 1 2 3 4]" {
+		t.Errorf("Expected order to match original indices, got %v", order)
+	}
+}
+
+func TestDependencyScheduler_UndeclaredTransactionConflictsWithEverything(t *testing.T) {
+	transactions := []Transaction{
+		transfer{from: "A", to: "B", value: 1},
+		transfer{from: "C", to: "D", value: 1},
+		opaqueTransfer{transfer{from: "E", to: "F", value: 1}},
+		transfer{from: "G", to: "H", value: 1},
+		transfer{from: "I", to: "J", value: 1},
+	}
+
+	scheduler := NewDependencyScheduler(transactions)
+
+	expected := [][]int{{}, {}, {0, 1}, {2}, {2}}
+	for i, deps := range expected {
+		if got := scheduler.Dependencies(i); fmt.Sprint(got) != fmt.Sprint(deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, got)
+		}
+	}
+}
+
+// prioritizedTransfer is a transfer with a priority
+type prioritizedTransfer struct {
+	transfer
+	priority int
+}
+
+func (t prioritizedTransfer) Priority() int { return t.priority }
+
+func TestExecuteBlock_HigherPriorityWinsContestedBalance(t *testing.T) {
+	initialState := []AccountValue{{Name: "A", Balance: 10}}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		prioritizedTransfer{transfer{from: "A", to: "C", value: 10}, 5},
+		transfer{from: "D", to: "E", value: 0}, // unrelated, keeps its place
+	}}
+
+	scheduler := NewDependencyScheduler(block.Transactions)
+	if deps := scheduler.Dependencies(0); fmt.Sprint(deps) != "[1]" {
+		t.Errorf("Expected transaction 0 to depend on the higher-priority transaction 1, got %v", deps)
+	}
+	if order := scheduler.Order(); fmt.Sprint(order) != "[1 0 2]" {
+		t.Errorf("Expected order [1 0 2], got %v", order)
+	}
+
+	snapshot, result, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 0, "C": 10, "D": 0, "E": 0})
+	if result.Transactions[0].Applied || !result.Transactions[1].Applied {
+		t.Errorf("Expected only the higher-priority transfer to be applied, got %+v", result.Transactions)
+	}
+}
+
+// balanceCheck is a read-only transaction failing unless an account holds at least min.
+// It doesn't declare an access set. If rendezvous is set, it waits until every check sharing
+// it is executing concurrently.
+type balanceCheck struct {
+	account    string
+	min        uint
+	rendezvous *sync.WaitGroup
+}
+
+func (c balanceCheck) ReadOnly() {}
+
+func (c balanceCheck) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if c.rendezvous != nil {
+		c.rendezvous.Done()
+		arrived := make(chan struct{})
+		go func() {
+			c.rendezvous.Wait()
+			close(arrived)
+		}()
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("read-only transactions did not execute concurrently")
+		}
+	}
+	if balance := state.GetAccount(c.account).Balance; balance < c.min {
+		return nil, fmt.Errorf("account %s has %d, expected at least %d", c.account, balance, c.min)
+	}
+	return nil, nil
+}
+
+func TestDependencyScheduler_ReadOnlyTransactions(t *testing.T) {
+	transactions := []Transaction{
+		balanceCheck{account: "A"},
+		balanceCheck{account: "B"},
+		transfer{from: "A", to: "B", value: 10}, // waits for both checks
+		balanceCheck{account: "C"},              // waits for the transfer
+		balanceCheck{account: "D"},              // waits for the transfer only
+		transfer{from: "C", to: "D", value: 10}, // waits for every check, and nothing else
+	}
+
+	scheduler := NewDependencyScheduler(transactions)
+
+	expected := [][]int{{}, {}, {0, 1}, {2}, {2}, {0, 1, 3, 4}}
+	for i, deps := range expected {
+		if got := scheduler.Dependencies(i); fmt.Sprint(got) != fmt.Sprint(deps) {
+			t.Errorf("Transaction %d: expected dependencies %v, got %v", i, deps, got)
+		}
+	}
+}
+
+func TestExecuteBlock_ReadOnlyTransactionsRunConcurrently(t *testing.T) {
+	const numChecks = 8
+	rendezvous := &sync.WaitGroup{}
+	rendezvous.Add(numChecks)
+
+	var transactions []Transaction
+	for i := 0; i < numChecks; i++ {
+		transactions = append(transactions, balanceCheck{account: "A", min: 100, rendezvous: rendezvous})
+	}
+	transactions = append(transactions,
+		transfer{from: "A", to: "B", value: 60},
+		balanceCheck{account: "A", min: 50}, // fails, observes the transfer
+	)
+	block := Block{Transactions: transactions}
+
+	_, result, err := ExecuteBlock(block, NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}}), numChecks)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for i, tx := range result.Transactions[:numChecks+1] {
+		if !tx.Applied {
+			t.Errorf("Expected transaction %d to be applied, got %+v", i, tx)
+		}
+	}
+	if last := result.Transactions[numChecks+1]; last.Applied {
+		t.Errorf("Expected the check after the transfer to fail, got %+v", last)
+	}
+}
+
+// writingCheck is a read-only transaction that nevertheless returns updates
+type writingCheck struct{ transfer }
+
+func (writingCheck) ReadOnly() {}
+
+func TestExecuteBlock_ReadOnlyTransactionReturningUpdates(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{writingCheck{transfer{from: "A", to: "B", value: 10}}}}
+
+	if _, _, err := ExecuteBlock(block, state, 1); !errors.Is(err, ErrAccessSetViolation) {
+		t.Fatalf("Expected ErrAccessSetViolation, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100})
+}
+
+func TestExecuteBlock_CompetingWithdrawalsAreDeterministic(t *testing.T) {
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 70},
+		transfer{from: "A", to: "C", value: 70}, // always loses to the lower-indexed withdrawal
+	}}
+
+	engines := map[string]func(AccountState, int) (BlockResult, error){
+		"scheduled": func(state AccountState, numWorkers int) (BlockResult, error) {
+			_, result, err := ExecuteBlock(block, state, numWorkers)
+			return result, err
+		},
+		"occ": func(state AccountState, numWorkers int) (BlockResult, error) {
+			_, result, err := ExecuteBlockOCC(block, state, numWorkers)
+			return result, err
+		},
+	}
+	for name, execute := range engines {
+		for run := 0; run < 100; run++ {
+			numWorkers := run%8 + 1
+			result, err := execute(NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}}), numWorkers)
+			if err != nil {
+				t.Fatalf("%s: execution failed: %v", name, err)
+			}
+			if !result.Transactions[0].Applied {
+				t.Fatalf("%s run %d: expected the first withdrawal to be applied, got %+v", name, run, result.Transactions[0])
+			}
+			if r := result.Transactions[1]; r.Applied || !errors.Is(r.Err, ErrInsufficientBalance) {
+				t.Fatalf("%s run %d: expected the second withdrawal to fail with ErrInsufficientBalance, got %+v", name, run, r)
+			}
+		}
+	}
+}
+
+// executeSerially is the reference implementation: every transaction executes in order
+// against the state, skipping failed ones
+func executeSerially(t *testing.T, block Block, initialState []AccountValue) ([]AccountValue, []bool) {
+	t.Helper()
+	state := NewInMemoryAccountState(initialState)
+	applied := make([]bool, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		updates, err := tx.Updates(state)
+		if err == nil {
+			err = state.ApplyUpdates(updates)
+		}
+		applied[i] = err == nil
+	}
+	return state.GetSnapshot(), applied
+}
+
+// randomDependentBlock generates transfers among a small set of accounts so that most
+// transactions depend on earlier ones, some fail, and some don't declare access sets
+func randomDependentBlock(rng *rand.Rand, accounts []string, n int) Block {
+	var transactions []Transaction
+	for i := 0; i < n; i++ {
+		from := accounts[rng.Intn(len(accounts))]
+		to := accounts[rng.Intn(len(accounts))]
+		tx := transfer{from: from, to: to, value: rng.Intn(80)}
+		if rng.Intn(10) == 0 {
+			transactions = append(transactions, opaqueTransfer{tx})
+		} else {
+			transactions = append(transactions, tx)
+		}
+	}
+	return Block{Transactions: transactions}
+}
+
+func TestExecuteBlock_RandomDependentChainsMatchSerial(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	var initialState []AccountValue
+	for _, name := range accounts {
+		initialState = append(initialState, AccountValue{Name: name, Balance: 100})
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		block := randomDependentBlock(rng, accounts, 40)
+		expected, expectedApplied := executeSerially(t, block, initialState)
+
+		for _, numWorkers := range []int{1, 3, 8} {
+			state := NewInMemoryAccountState(initialState)
+			result, blockResult, err := ExecuteBlock(block, state, numWorkers)
+			if err != nil {
+				t.Fatalf("Round %d: ExecuteBlock failed with %d workers: %v", round, numWorkers, err)
+			}
+
+			if !SnapshotsEqual(expected, result) {
+				t.Fatalf("Round %d: results with %d workers differ from serial execution\nexpected: %+v\ngot: %+v",
+					round, numWorkers, expected, result)
+			}
+			for i, tx := range blockResult.Transactions {
+				if tx.Applied != expectedApplied[i] {
+					t.Fatalf("Round %d: transaction %d applied=%v with %d workers, serially %v",
+						round, i, tx.Applied, numWorkers, expectedApplied[i])
+				}
+			}
+		}
+	}
+}
+
+// interleavedTransaction delays a transaction by a fixed amount before it executes, as a
+// scheduling point letting other workers overtake it. It keeps the transaction's access set.
+type interleavedTransaction struct {
+	Transaction
+	delay time.Duration
+}
+
+func (t interleavedTransaction) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if t.delay > 0 {
+		time.Sleep(t.delay)
+	} else {
+		runtime.Gosched()
+	}
+	return t.Transaction.Updates(state)
+}
+
+func (t interleavedTransaction) declaredAccess() accessSet { return declaredAccessSet(t.Transaction) }
+
+// checkInterleavings executes a block without explicit dependencies or priorities under one
+// interleaving per seed and fails the test unless every run matches serial execution. Each
+// seed picks the number of workers and delays every transaction by a random amount, so many
+// seeds explore many orders in which transactions finish; a failing seed reproduces its
+// interleaving closely, though not exactly.
+func checkInterleavings(t *testing.T, block Block, initialState []AccountValue, seeds int) {
+	t.Helper()
+	expected, expectedApplied := executeSerially(t, block, initialState)
+
+	for seed := int64(0); seed < int64(seeds); seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		numWorkers := 1 + rng.Intn(8)
+		interleaved := Block{Transactions: make([]Transaction, len(block.Transactions))}
+		for i, tx := range block.Transactions {
+			delay := time.Duration(rng.Intn(3)) * time.Duration(rng.Intn(100)) * time.Microsecond
+			interleaved.Transactions[i] = interleavedTransaction{Transaction: tx, delay: delay}
+		}
+
+		snapshot, result, err := ExecuteBlock(interleaved, NewInMemoryAccountState(initialState), numWorkers)
+		if err != nil {
+			t.Fatalf("Seed %d: ExecuteBlock failed with %d workers: %v", seed, numWorkers, err)
+		}
+		if !SnapshotsEqual(expected, snapshot) {
+			t.Fatalf("Seed %d: results with %d workers differ from serial execution\nexpected: %+v\ngot: %+v",
+				seed, numWorkers, expected, snapshot)
+		}
+		for i, tx := range result.Transactions {
+			if tx.Applied != expectedApplied[i] {
+				t.Fatalf("Seed %d: transaction %d applied=%v with %d workers, serially %v",
+					seed, i, tx.Applied, numWorkers, expectedApplied[i])
+			}
+		}
+	}
+}
+
+func TestExecuteBlock_SeededInterleavingsMatchSerial(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D", "E"}
+	var initialState []AccountValue
+	for _, name := range accounts {
+		initialState = append(initialState, AccountValue{Name: name, Balance: 60})
+	}
+
+	block := randomDependentBlock(rand.New(rand.NewSource(2)), accounts, 30)
+	checkInterleavings(t, block, initialState, 100)
+}
+
+func TestExecuteBlock_OnConflict(t *testing.T) {
+	// Keep a single writer per contested account, picked pseudo-randomly but deterministically
+	var calls []string
+	keepOne := func(account string, txs []int) []int {
+		calls = append(calls, fmt.Sprintf("%s %v", account, txs))
+		rng := rand.New(rand.NewSource(int64(len(account) * 31)))
+		return []int{txs[rng.Intn(len(txs))]}
+	}
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "Pool", to: "B0", value: 10},
+		transfer{from: "Pool", to: "B1", value: 10},
+		transfer{from: "Pool", to: "B2", value: 10},
+		transfer{from: "C", to: "D", value: 5}, // uncontested
+		prioritizedTransfer{transfer{from: "Pool", to: "B3", value: 10}, 1},
+	}}
+	initialState := []AccountValue{{Name: "Pool", Balance: 100}, {Name: "C", Balance: 5}}
+
+	rng := rand.New(rand.NewSource(int64(len("Pool") * 31)))
+	writers := []int{4, 0, 1, 2} // serial order, the prioritized transfer first
+	winner := writers[rng.Intn(len(writers))]
+
+	for run := 0; run < 10; run++ {
+		calls = nil
+		opts := BlockOptions{OnConflict: keepOne}
+		_, result, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), 4, opts)
+		if err != nil {
+			t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+		}
+
+		if fmt.Sprint(calls) != "[Pool [4 0 1 2]]" {
+			t.Fatalf("Expected a single call for Pool with writers in serial order, got %v", calls)
+		}
+		for i, tx := range result.Transactions {
+			switch {
+			case i == winner || i == 3:
+				if !tx.Applied {
+					t.Errorf("Run %d: expected transaction %d to be applied, got %+v", run, i, tx)
+				}
+			case tx.Applied || !errors.Is(tx.Err, ErrConflictRejected):
+				t.Errorf("Run %d: expected transaction %d to be rejected, got %+v", run, i, tx)
+			}
+		}
+	}
+}
+
+func TestExecuteBlock_ExplicitDependencies(t *testing.T) {
+	block := Block{
+		Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 10},
+			transfer{from: "C", to: "D", value: 20}, // independent of T1 by access sets
+			transfer{from: "E", to: "F", value: 30},
+		},
+		Dependencies: map[int][]int{0: {1}},
+	}
+
+	scheduler, err := NewBlockScheduler(block)
+	if err != nil {
+		t.Fatalf("NewBlockScheduler failed: %v", err)
+	}
+	if deps := scheduler.Dependencies(0); fmt.Sprint(deps) != "[1]" {
+		t.Errorf("Expected transaction 0 to depend on 1, got %v", deps)
+	}
+	if order := scheduler.Order(); fmt.Sprint(order) != "[1 0 2]" {
+		t.Errorf("Expected order [1 0 2], got %v", order)
+	}
+
+	initialState := []AccountValue{{Name: "A", Balance: 10}, {Name: "C", Balance: 20}, {Name: "E", Balance: 30}}
+	for run := 0; run < 10; run++ {
+		observer := newRecordingObserver()
+		accounts, _, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), 3, BlockOptions{Observer: observer})
+		if err != nil {
+			t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+		}
+		verifyResults(t, accounts, map[string]uint{"A": 0, "B": 10, "C": 0, "D": 20, "E": 0, "F": 30})
+
+		position := make(map[int]int)
+		for p, i := range observer.started {
+			position[i] = p
+		}
+		if position[0] < position[1] {
+			t.Fatalf("Run %d: transaction 0 started before its dependency, start order %v", run, observer.started)
+		}
+		if observer.log[0][0].Name != "C" {
+			t.Fatalf("Run %d: expected transaction 1 to commit first, got %v", run, observer.log)
+		}
+	}
+}
+
+func TestExecuteBlock_DependencyCycle(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}, {Name: "C", Balance: 20}})
+
+	tests := []struct {
+		name         string
+		dependencies map[int][]int
+		err          error
+	}{
+		{"explicit cycle", map[int][]int{0: {2}, 2: {0}}, ErrDependencyCycle},
+		// T1 depends on T0 through account B, so T0 can't depend on T1
+		{"cycle through access sets", map[int][]int{0: {1}}, ErrDependencyCycle},
+		{"self dependency", map[int][]int{2: {2}}, ErrDependencyCycle},
+		{"unknown transaction", map[int][]int{0: {3}}, ErrInvalidDependency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := Block{
+				Transactions: []Transaction{
+					transfer{from: "A", to: "B", value: 10},
+					transfer{from: "B", to: "C", value: 5},
+					transfer{from: "C", to: "D", value: 20},
+				},
+				Dependencies: tt.dependencies,
+			}
+
+			_, _, err := ExecuteBlock(block, state, 2)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected %v, got %v", tt.err, err)
+			}
+			verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10, "C": 20})
+		})
+	}
+}
+
+// rendezvousMint credits an account once another rendezvousMint has started, failing if it
+// doesn't start in time. It doesn't implement AccessAware, so it conflicts with everything.
+type rendezvousMint struct {
+	to      string
+	value   int
+	started chan struct{} // closed when this mint starts
+	other   chan struct{}
+}
+
+func (m rendezvousMint) Updates(ReadOnlyState) ([]AccountUpdate, error) {
+	close(m.started)
+	select {
+	case <-m.other:
+		return []AccountUpdate{{Name: m.to, BalanceChange: m.value}}, nil
+	case <-time.After(time.Second):
+		return nil, errors.New("other mint didn't run concurrently")
+	}
+}
+
+func TestExecuteBlock_Groups(t *testing.T) {
+	first, second := make(chan struct{}), make(chan struct{})
+	block := Block{
+		Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 10}, // needs the mint of A
+			rendezvousMint{to: "C", value: 10, started: first, other: second},
+			transfer{from: "C", to: "D", value: 10}, // needs the mint of C
+			rendezvousMint{to: "A", value: 10, started: second, other: first},
+		},
+		// Each group runs in listed order, even against index order
+		Groups: [][]int{{3, 0}, {1, 2}},
+	}
+
+	snapshot, result, err := ExecuteBlock(block, NewInMemoryAccountState(nil), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	for _, tx := range result.Transactions {
+		if !tx.Applied {
+			t.Errorf("Transaction %d failed: %v", tx.Index, tx.Err)
+		}
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 0, "B": 10, "C": 0, "D": 10})
+	if expected := (ConflictStats{IndependentGroups: 2, LargestGroup: 2, SerializedTransactions: 2}); result.Conflicts != expected {
+		t.Errorf("Expected conflict stats %+v, got %+v", expected, result.Conflicts)
+	}
+
+	for _, groups := range [][][]int{
+		{{0, 1}, {2}},         // 3 missing
+		{{0, 1}, {2, 3, 1}},   // 1 twice
+		{{0, 1}, {2, 3}, {4}}, // 4 out of range
+	} {
+		block.Groups = groups
+		if _, _, err := ExecuteBlock(block, NewInMemoryAccountState(nil), 2); !errors.Is(err, ErrInvalidGroups) {
+			t.Errorf("Groups %v: expected ErrInvalidGroups, got %v", groups, err)
+		}
+	}
+}
+
+// sourcedTransfer is a transfer sent by the account it debits
+type sourcedTransfer struct {
+	transfer
+}
+
+func (t sourcedTransfer) Sender() string { return t.from }
+
+func TestExecuteBlock_FairScheduling(t *testing.T) {
+	// Alice's transactions all come first by index, Bob's after them
+	var transactions []Transaction
+	for i := 0; i < 10; i++ {
+		transactions = append(transactions, sourcedTransfer{transfer{from: "Alice", to: fmt.Sprintf("A%d", i), value: 1}})
+	}
+	for i := 0; i < 10; i++ {
+		transactions = append(transactions, sourcedTransfer{transfer{from: "Bob", to: fmt.Sprintf("B%d", i), value: 1}})
+	}
+	block := Block{Transactions: transactions}
+
+	scheduler, err := newBlockScheduler(block, true, nil, nil)
+	if err != nil {
+		t.Fatalf("newBlockScheduler failed: %v", err)
+	}
+	order := scheduler.Order()
+	for position, i := range order {
+		// Senders alternate, each in its own index order
+		expected := position/2 + (position%2)*10
+		if i != expected {
+			t.Fatalf("Expected senders to alternate in order, got %v", order)
+		}
+	}
+
+	initialState := []AccountValue{{Name: "Alice", Balance: 10}, {Name: "Bob", Balance: 10}}
+	observer := newRecordingObserver()
+	opts := BlockOptions{FairScheduling: true, Observer: observer}
+	accounts, _, err := ExecuteBlockWithOptions(context.Background(), block, NewInMemoryAccountState(initialState), 1, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	expected := map[string]uint{"Alice": 0, "Bob": 0}
+	for i := 0; i < 10; i++ {
+		expected[fmt.Sprintf("A%d", i)] = 1
+		expected[fmt.Sprintf("B%d", i)] = 1
+	}
+	verifyResults(t, accounts, expected)
+
+	// With a single worker transactions start in serial order: interleaved, and in order per sender
+	last := map[bool]int{false: -1, true: -1}
+	switches := 0
+	for n, i := range observer.started {
+		bob := i >= 10
+		if i <= last[bob] {
+			t.Fatalf("Sender order not preserved, start order %v", observer.started)
+		}
+		last[bob] = i
+		if n > 0 && bob != (observer.started[n-1] >= 10) {
+			switches++
+		}
+	}
+	if switches < 10 {
+		t.Errorf("Expected senders to be interleaved, start order %v", observer.started)
+	}
+}
+
+func TestSerialOrder_FairSchedulingKeepsPriority(t *testing.T) {
+	transactions := []Transaction{
+		sourcedTransfer{transfer{from: "A", to: "X", value: 1}},
+		sourcedTransfer{transfer{from: "A", to: "X", value: 1}},
+		prioritizedTransfer{transfer{from: "B", to: "X", value: 1}, 1},
+		sourcedTransfer{transfer{from: "C", to: "X", value: 1}},
+	}
+	if order := serialOrder(transactions, true); fmt.Sprint(order) != "[2 0 3 1]" {
+		t.Errorf("Expected order [2 0 3 1], got %v", order)
+	}
+	if order := serialOrder(transactions, false); fmt.Sprint(order) != "[2 0 1 3]" {
+		t.Errorf("Expected order [2 0 1 3], got %v", order)
+	}
+}
+
+func TestExecuteBlock_ConflictStats(t *testing.T) {
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10}, // group {0, 2, 3}
+		transfer{from: "C", to: "D", value: 10}, // group {1}
+		transfer{from: "B", to: "E", value: 5},  // depends on 0
+		transfer{from: "E", to: "A", value: 5},  // depends on 0 and 2
+		transfer{from: "F", to: "G", value: 10}, // group {4, 5}
+		transfer{from: "G", to: "F", value: 5},  // depends on 4
+		transfer{from: "H", to: "I", value: 10}, // group {6}
+	}}
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 100}, {Name: "C", Balance: 100}, {Name: "F", Balance: 100}, {Name: "H", Balance: 100},
+	})
+
+	_, result, err := ExecuteBlock(block, state, 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	expected := ConflictStats{IndependentGroups: 4, LargestGroup: 3, SerializedTransactions: 3}
+	if result.Conflicts != expected {
+		t.Errorf("Expected conflict stats %+v, got %+v", expected, result.Conflicts)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+)
+
+// maxRequestBytes bounds the size of request bodies accepted by Server
+const maxRequestBytes = 32 << 20
+
+// ExecuteRequest is the body accepted by Server: the blocks to execute, in order, against
+// the initial state
+type ExecuteRequest struct {
+	InitialState []AccountValue `json:"initialState"`
+	Blocks       []Block        `json:"blocks"`
+}
+
+// ExecuteResponse is the body returned by Server: either the resulting account state or an error
+type ExecuteResponse struct {
+	Accounts []AccountValue `json:"accounts,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Server is an http.Handler executing blocks posted as JSON. Transactions must be of types
+// registered with RegisterTransactionType. Request bodies larger than 32 MiB are rejected.
+// Execution is cancelled when the client disconnects.
+type Server struct {
+	numWorkers int
+}
+
+// NewServer creates a server executing blocks with numWorkers workers, or GOMAXPROCS workers if
+// numWorkers is less than 1
+func NewServer(numWorkers int) *Server {
+	if numWorkers < 1 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+	return &Server{numWorkers: numWorkers}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, ExecuteResponse{Error: "method not allowed"})
+		return
+	}
+
+	var req ExecuteRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeResponse(w, status, ExecuteResponse{Error: "decode request: " + err.Error()})
+		return
+	}
+
+	accounts, err := StartContext(r.Context(), req.Blocks, req.InitialState, s.numWorkers)
+	if err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, ExecuteResponse{Error: err.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, ExecuteResponse{Accounts: accounts})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp ExecuteResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
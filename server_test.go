@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestServer_ExecutesPostedBlock(t *testing.T) {
+	server := httptest.NewServer(NewServer(4))
+	defer server.Close()
+
+	req := ExecuteRequest{
+		InitialState: []AccountValue{
+			{Name: "A", Balance: 20},
+			{Name: "B", Balance: 30},
+		},
+		Blocks: []Block{{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 5},
+			transfer{from: "B", to: "C", value: 10},
+		}}},
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var decoded ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+
+	expected := map[string]uint{
+		"A": 15,
+		"B": 25,
+		"C": 10,
+	}
+	verifyResults(t, decoded.Accounts, expected)
+}
+
+func TestServer_RejectsInvalidRequests(t *testing.T) {
+	server := httptest.NewServer(NewServer(4))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"blocks":[{"transactions":[{"type":"bogus"}]}]}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown transaction type, got %d", resp.StatusCode)
+	}
+
+	var decoded ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+	if !strings.Contains(decoded.Error, "unknown transaction type") {
+		t.Errorf("Expected unknown transaction type error, got %q", decoded.Error)
+	}
+}
+
+func TestServer_RejectsOversizedRequests(t *testing.T) {
+	body := `{"blocks":[` + strings.Repeat(" ", maxRequestBytes) + `]}`
+	recorder := httptest.NewRecorder()
+	NewServer(4).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", recorder.Code)
+	}
+}
+
+func TestNewServer_DefaultsWorkers(t *testing.T) {
+	if server := NewServer(0); server.numWorkers != runtime.GOMAXPROCS(0) {
+		t.Errorf("Expected GOMAXPROCS workers, got %d", server.numWorkers)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"sort"
+)
+
+// DefaultShardCount is the number of shards used by NewShardedAccountState when none is given
+const DefaultShardCount = 16
+
+// ShardedAccountState implements AccountState by routing each account to one of several
+// InMemoryAccountState shards by a hash of its name, so that updates to accounts of different
+// shards don't contend for the same lock. Updates spanning several shards lock them in
+// ascending order and are validated on every shard before any is written, so they're applied
+// atomically as with a single InMemoryAccountState and concurrent updates don't deadlock.
+type ShardedAccountState struct {
+	shards []*InMemoryAccountState
+}
+
+// NewShardedAccountState creates a new account state spread across numShards shards.
+// If numShards is less than 1, DefaultShardCount is used.
+func NewShardedAccountState(initialAccounts []AccountValue, numShards int) *ShardedAccountState {
+	if numShards < 1 {
+		numShards = DefaultShardCount
+	}
+	state := &ShardedAccountState{shards: make([]*InMemoryAccountState, numShards)}
+
+	initial := make([][]AccountValue, numShards)
+	for _, acc := range initialAccounts {
+		i := state.shardIndex(acc.Name)
+		initial[i] = append(initial[i], acc)
+	}
+	for i := range state.shards {
+		state.shards[i] = NewInMemoryAccountState(initial[i])
+	}
+	return state
+}
+
+// shardIndex returns the index of the shard holding the given account
+func (s *ShardedAccountState) shardIndex(name string) int {
+	return int(nameHash(name) % uint32(len(s.shards)))
+}
+
+// GetAccount implements AccountState interface
+func (s *ShardedAccountState) GetAccount(name string) AccountValue {
+	return s.shards[s.shardIndex(name)].GetAccount(name)
+}
+
+// HasAccount implements AccountState interface
+func (s *ShardedAccountState) HasAccount(name string) bool {
+	return s.shards[s.shardIndex(name)].HasAccount(name)
+}
+
+// ApplyUpdates implements AccountState interface. Only the shards holding the updated accounts
+// are locked. On error the state is left unchanged.
+func (s *ShardedAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	shardOf := make([]int, len(updates))
+	for j, update := range updates {
+		shardOf[j] = s.shardIndex(update.Name)
+	}
+	locked := slices.Clone(shardOf)
+	sort.Ints(locked)
+	locked = slices.Compact(locked)
+	for _, i := range locked {
+		s.shards[i].mu.Lock()
+	}
+	defer func() {
+		for _, i := range locked {
+			s.shards[i].mu.Unlock()
+		}
+	}()
+
+	staged := make([]stagedUpdates, len(locked))
+	shardUpdates := make([]AccountUpdate, 0, len(updates))
+	for k, i := range locked {
+		// Each shard's updates keep their relative order
+		shardUpdates = shardUpdates[:0]
+		for j, update := range updates {
+			if shardOf[j] == i {
+				shardUpdates = append(shardUpdates, update)
+			}
+		}
+		var err error
+		if staged[k], err = s.shards[i].stageUpdates(shardUpdates, sameOrigin(-1, -1)); err != nil {
+			return err
+		}
+	}
+	for k, i := range locked {
+		s.shards[i].writeStaged(staged[k])
+	}
+	return nil
+}
+
+// GetSnapshot returns the current state of all accounts, sorted by name. Every shard is locked
+// for the duration, so the snapshot never observes a partially applied update.
+func (s *ShardedAccountState) GetSnapshot() []AccountValue {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	var result []AccountValue
+	for _, shard := range s.shards {
+		for name := range shard.accounts {
+			result = append(result, shard.accountValue(name))
+		}
+	}
+	sortAccounts(result)
+	return result
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// accountsInDistinctShards returns n account names held by different shards of state
+func accountsInDistinctShards(t *testing.T, state *ShardedAccountState, n int) []string {
+	t.Helper()
+	var names []string
+	used := make(map[int]bool)
+	for i := 0; len(names) < n; i++ {
+		if i > 1000 {
+			t.Fatalf("Found only %d accounts in distinct shards", len(names))
+		}
+		name := fmt.Sprintf("A%d", i)
+		if shard := state.shardIndex(name); !used[shard] {
+			used[shard] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestShardedAccountState_MatchesInMemoryState(t *testing.T) {
+	names := accountsInDistinctShards(t, NewShardedAccountState(nil, 4), 4)
+	a, b, c, d := names[0], names[1], names[2], names[3]
+	initialState := []AccountValue{
+		{Name: a, Balance: 100},
+		{Name: b, Balance: 50},
+		{Name: c, Balance: 10, Assets: map[string]uint{"gold": 5}},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: a, to: b, value: 30},
+		transfer{from: c, to: d, value: 20}, // fails
+		transfer{from: b, to: c, value: 60},
+		transfer{from: a, to: d, value: 5},
+	}}
+
+	expected, _, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on in-memory state failed: %v", err)
+	}
+	state := NewShardedAccountState(initialState, 4)
+	actual, _, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on sharded state failed: %v", err)
+	}
+	if !SnapshotsEqual(expected, actual) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+	if !state.HasAccount(d) || state.HasAccount("missing") {
+		t.Errorf("Unexpected HasAccount results")
+	}
+	if gold := state.GetAccount(c).AssetBalance("gold"); gold != 5 {
+		t.Errorf("Expected %s to hold 5 gold, got %d", c, gold)
+	}
+}
+
+func TestShardedAccountState_UpdatesAreAtomicAcrossShards(t *testing.T) {
+	state := NewShardedAccountState(nil, 8)
+	names := accountsInDistinctShards(t, state, 2)
+	if err := state.ApplyUpdates([]AccountUpdate{
+		{Name: names[0], BalanceChange: 10},
+		{Name: names[1], BalanceChange: 10},
+	}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: names[0], BalanceChange: 5},
+		{Name: names[1], BalanceChange: -20},
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{names[0]: 10, names[1]: 10})
+}
+
+func TestShardedAccountState_ConcurrentOverlappingUpdates(t *testing.T) {
+	const numAccounts = 8
+	var initialState []AccountValue
+	for i := 0; i < numAccounts; i++ {
+		initialState = append(initialState, AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 1000})
+	}
+	state := NewShardedAccountState(initialState, 4)
+
+	// Goroutines move funds around a ring in opposite directions, locking overlapping shards,
+	// while snapshots are taken concurrently
+	var wg sync.WaitGroup
+	for g := 0; g < numAccounts; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			from, to := fmt.Sprintf("A%d", g), fmt.Sprintf("A%d", (g+1)%numAccounts)
+			if g%2 == 1 {
+				from, to = to, from
+			}
+			for i := 0; i < 100; i++ {
+				if err := state.ApplyUpdates([]AccountUpdate{
+					{Name: from, BalanceChange: -1},
+					{Name: to, BalanceChange: 1},
+				}); err != nil {
+					t.Errorf("ApplyUpdates failed: %v", err)
+					return
+				}
+				if i%10 == 0 {
+					if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != numAccounts*1000 {
+						t.Errorf("Snapshot observed a partial update, total balance %d (%v)", total, err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != numAccounts*1000 {
+		t.Errorf("Expected total balance %d, got %d (%v)", numAccounts*1000, total, err)
+	}
+}
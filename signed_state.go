@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// SignedAccountValue is an account whose balance may be negative
+type SignedAccountValue struct {
+	Name    string
+	Balance int64
+}
+
+// SignedAccountState implements AccountState for accounts that can go negative, such as
+// liabilities or credit lines. Each account may be overdrawn down to its credit limit.
+//
+// Through the AccountState interface an account is reported with its available funds,
+// i.e. its balance plus its credit limit, so transactions checking for sufficient balance
+// naturally allow overdrafts up to the limit.
+type SignedAccountState struct {
+	balances     map[string]int64
+	limits       map[string]uint
+	defaultLimit uint
+	mu           sync.RWMutex
+}
+
+// NewSignedAccountState creates a new signed account state. Accounts without an explicit
+// credit limit may be overdrawn by up to defaultCreditLimit, capped at math.MaxInt64.
+func NewSignedAccountState(initialAccounts []SignedAccountValue, defaultCreditLimit uint) *SignedAccountState {
+	state := &SignedAccountState{
+		balances:     make(map[string]int64),
+		limits:       make(map[string]uint),
+		defaultLimit: capCreditLimit(defaultCreditLimit),
+	}
+
+	for _, acc := range initialAccounts {
+		state.balances[acc.Name] = acc.Balance
+	}
+
+	return state
+}
+
+// SetCreditLimit allows the account to be overdrawn down to -limit, capped at math.MaxInt64
+// since balances can't go lower than math.MinInt64
+func (s *SignedAccountState) SetCreditLimit(name string, limit uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits[name] = capCreditLimit(limit)
+}
+
+// capCreditLimit returns limit capped at math.MaxInt64
+func capCreditLimit(limit uint) uint {
+	return uint(min(uint64(limit), math.MaxInt64))
+}
+
+// overdraft returns how far below zero a negative balance is, computed without negating the
+// balance, which overflows for math.MinInt64
+func overdraft(balance int64) uint64 {
+	return uint64(-(balance + 1)) + 1
+}
+
+// creditLimit returns the credit limit of the account
+func (s *SignedAccountState) creditLimit(name string) uint {
+	if limit, ok := s.limits[name]; ok {
+		return limit
+	}
+	return s.defaultLimit
+}
+
+// GetSignedAccount returns the account with its signed balance
+func (s *SignedAccountState) GetSignedAccount(name string) SignedAccountValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SignedAccountValue{
+		Name:    name,
+		Balance: s.balances[name],
+	}
+}
+
+// GetAccount implements AccountState interface, reporting the account's available funds
+func (s *SignedAccountState) GetAccount(name string) AccountValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Credit limits are at most math.MaxInt64, so the sum doesn't overflow
+	balance, limit := s.balances[name], uint64(s.creditLimit(name))
+	var available uint64
+	switch {
+	case balance >= 0:
+		available = uint64(balance) + limit
+	case overdraft(balance) < limit:
+		available = limit - overdraft(balance)
+	}
+	return AccountValue{
+		Name:    name,
+		Balance: uint(min(available, math.MaxUint)),
+	}
+}
+
+// HasAccount implements AccountState interface
+func (s *SignedAccountState) HasAccount(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.balances[name]
+	return ok
+}
+
+// ApplyUpdates implements AccountState interface. Balances may go negative down to the
+// account's credit limit; a debit beyond it fails with ErrCreditLimitExceeded and no update
+// from the call is applied. Only balance changes are supported.
+func (s *SignedAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staged := make(map[string]int64, len(updates))
+	for _, update := range updates {
+		if update.Op != OpBalanceChange || update.Asset != NativeAsset {
+			return fmt.Errorf("%w: operation %d on asset %q for signed account %s", ErrUnsupportedOperation, update.Op, update.Asset, update.Name)
+		}
+
+		balance, ok := staged[update.Name]
+		if !ok {
+			balance = s.balances[update.Name]
+		}
+
+		change := int64(update.BalanceChange)
+		if (change > 0 && balance > math.MaxInt64-change) || (change < 0 && balance < math.MinInt64-change) {
+			return fmt.Errorf("%w: account %s has %d, cannot add %d", ErrOverflow, update.Name, balance, change)
+		}
+		balance += change
+
+		limit := s.creditLimit(update.Name)
+		if balance < 0 && overdraft(balance) > uint64(limit) {
+			return fmt.Errorf("%w: account %s would have %d, credit limit is %d", ErrCreditLimitExceeded, update.Name, balance, limit)
+		}
+		staged[update.Name] = balance
+	}
+
+	for name, balance := range staged {
+		s.balances[name] = balance
+	}
+	return nil
+}
+
+// GetSignedSnapshot returns the current signed balances of all accounts, sorted by name
+func (s *SignedAccountState) GetSignedSnapshot() []SignedAccountValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]SignedAccountValue, 0, len(s.balances))
+	for name, balance := range s.balances {
+		result = append(result, SignedAccountValue{
+			Name:    name,
+			Balance: balance,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
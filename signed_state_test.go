@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSignedAccountState_DebitWithinCreditLimit(t *testing.T) {
+	state := NewSignedAccountState([]SignedAccountValue{
+		{Name: "Liability", Balance: 0},
+		{Name: "Cash", Balance: 0},
+	}, 0)
+	state.SetCreditLimit("Liability", 1000)
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "Liability", BalanceChange: -500},
+		{Name: "Cash", BalanceChange: 500},
+	})
+	if err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+
+	if acc := state.GetSignedAccount("Liability"); acc.Balance != -500 {
+		t.Errorf("Expected balance -500, got %d", acc.Balance)
+	}
+	// The remaining credit is reported as available funds
+	if acc := state.GetAccount("Liability"); acc.Balance != 500 {
+		t.Errorf("Expected 500 available, got %d", acc.Balance)
+	}
+}
+
+func TestSignedAccountState_DebitExceedingCreditLimit(t *testing.T) {
+	state := NewSignedAccountState([]SignedAccountValue{{Name: "Liability", Balance: -500}}, 1000)
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "Cash", BalanceChange: 600},
+		{Name: "Liability", BalanceChange: -600},
+	})
+	if !errors.Is(err, ErrCreditLimitExceeded) {
+		t.Fatalf("Expected ErrCreditLimitExceeded, got %v", err)
+	}
+
+	if acc := state.GetSignedAccount("Liability"); acc.Balance != -500 {
+		t.Errorf("Expected balance to stay -500, got %d", acc.Balance)
+	}
+	if state.HasAccount("Cash") {
+		t.Error("Expected no update from the failed call to be applied")
+	}
+}
+
+func TestSignedAccountState_TransfersUseCredit(t *testing.T) {
+	state := NewSignedAccountState([]SignedAccountValue{{Name: "A", Balance: 100}}, 0)
+	state.SetCreditLimit("A", 1000)
+
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 600},
+		transfer{from: "A", to: "B", value: 600}, // exceeds the remaining 500 of credit
+	}}
+	_, result, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+
+	if !result.Transactions[0].Applied || result.Transactions[1].Applied {
+		t.Errorf("Expected only the first transfer to apply, got %+v", result.Transactions)
+	}
+	if acc := state.GetSignedAccount("A"); acc.Balance != -500 {
+		t.Errorf("Expected balance -500, got %d", acc.Balance)
+	}
+}
+
+func TestSignedAccountState_ExtremeCreditLimits(t *testing.T) {
+	state := NewSignedAccountState([]SignedAccountValue{
+		{Name: "A", Balance: 10},
+		{Name: "B", Balance: math.MinInt64 + 5},
+	}, math.MaxUint)
+
+	// Limits are capped at math.MaxInt64
+	if acc := state.GetAccount("A"); acc.Balance != math.MaxInt64+10 {
+		t.Errorf("Expected %d available, got %d", uint(math.MaxInt64+10), acc.Balance)
+	}
+	if acc := state.GetAccount("B"); acc.Balance != 4 {
+		t.Errorf("Expected 4 available, got %d", acc.Balance)
+	}
+
+	state.SetCreditLimit("B", math.MaxUint)
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", BalanceChange: -5}}); !errors.Is(err, ErrCreditLimitExceeded) {
+		t.Errorf("Expected ErrCreditLimitExceeded at math.MinInt64, got %v", err)
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", BalanceChange: -4}}); err != nil {
+		t.Errorf("ApplyUpdates within the capped limit failed: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+)
+
+// SimulateBlock executes a block without modifying state and returns the updates it would
+// apply, in commit order, together with the outcome of each transaction. Transactions are
+// executed against an overlay of state, so each one observes the simulated effects of the
+// transactions committed before it, exactly as in a real run.
+func SimulateBlock(block Block, state AccountState, numWorkers int) ([]AccountUpdate, []TxResult, error) {
+	overlay := newOverlayState(state)
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, overlay, numWorkers, BlockOptions{})
+	if err != nil {
+		return nil, result.Transactions, err
+	}
+	updates, _ := overlay.bufferedUpdates()
+	return updates, result.Transactions, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimulateBlock_MatchesRealRun(t *testing.T) {
+	initial := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 15},
+		transfer{from: "B", to: "C", value: 45}, // depends on the first transfer
+		transfer{from: "A", to: "C", value: 10}, // fails, A only has 5 left
+		transfer{from: "C", to: "A", value: 5},
+	}}
+
+	state := NewInMemoryAccountState(initial)
+	updates, simulated, err := SimulateBlock(block, state, 3)
+	if err != nil {
+		t.Fatalf("SimulateBlock failed: %v", err)
+	}
+	if !SnapshotsEqual(initial, state.GetSnapshot()) {
+		t.Errorf("SimulateBlock modified state: %+v", state.GetSnapshot())
+	}
+
+	real := NewInMemoryAccountState(initial)
+	snapshot, result, err := ExecuteBlock(block, real, 3)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !reflect.DeepEqual(simulated, result.Transactions) {
+		t.Errorf("Simulated results %+v differ from real results %+v", simulated, result.Transactions)
+	}
+
+	// Applying the simulated updates must produce the real final state
+	replayed := NewInMemoryAccountState(initial)
+	if err := replayed.ApplyUpdates(updates); err != nil {
+		t.Fatalf("Applying simulated updates failed: %v", err)
+	}
+	if !SnapshotsEqual(snapshot, replayed.GetSnapshot()) {
+		t.Errorf("Simulated updates produced %+v, real run produced %+v", replayed.GetSnapshot(), snapshot)
+	}
+}
+
+func TestSimulateBlock_MatchesRealRunWithFrozenAccount(t *testing.T) {
+	initial := []AccountValue{{Name: "A", Balance: 20}, {Name: "B", Balance: 30}}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 5}, // credits frozen B
+		transfer{from: "A", to: "C", value: 5},
+	}}
+	newState := func() *InMemoryAccountState {
+		state := NewInMemoryAccountState(initial)
+		state.Freeze("B")
+		return state
+	}
+
+	_, simulated, err := SimulateBlock(block, newState(), 2)
+	if err != nil {
+		t.Fatalf("SimulateBlock failed: %v", err)
+	}
+	_, result, err := ExecuteBlock(block, newState(), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if !errors.Is(result.Transactions[0].Err, ErrAccountFrozen) {
+		t.Fatalf("Expected the real run to fail the credit with ErrAccountFrozen, got %+v", result.Transactions[0])
+	}
+	if !reflect.DeepEqual(simulated, result.Transactions) {
+		t.Errorf("Simulated results %+v differ from real results %+v", simulated, result.Transactions)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// stateFile is the JSON representation of a state saved to disk
+type stateFile struct {
+	// Blocks is the number of blocks of a pipeline the state reflects, see StartWithCheckpoints
+	Blocks   int            `json:"blocks"`
+	Accounts []AccountValue `json:"accounts"`
+}
+
+// SaveState writes the balances and metadata of every account to the file at path as JSON,
+// replacing it atomically so a crash never leaves a partially written file behind. Minimum
+// balances, the ledger and other settings aren't saved.
+func (s *InMemoryAccountState) SaveState(path string) error {
+	return writeStateFile(path, stateFile{Accounts: s.getSnapshot()})
+}
+
+// LoadState replaces the balances and metadata of every account with those saved by SaveState
+// at path. Settings such as minimum balances are kept.
+func (s *InMemoryAccountState) LoadState(path string) error {
+	saved, err := readStateFile(path)
+	if err != nil {
+		return err
+	}
+	s.Restore(NewInMemoryAccountState(saved.Accounts).Checkpoint())
+	return nil
+}
+
+func writeStateFile(path string, saved stateFile) error {
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+
+	// Write to a temporary file in the same directory, then rename it over path
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	return nil
+}
+
+func readStateFile(path string) (stateFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stateFile{}, fmt.Errorf("load state: %w", err)
+	}
+	var saved stateFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return stateFile{}, fmt.Errorf("load state %s: %w", path, err)
+	}
+	return saved, nil
+}
+
+// StartWithCheckpoints is like Start but saves the state to the file at path after every
+// `every` blocks and after the last one, recording how many blocks it reflects. If a checkpoint
+// already exists at path, execution resumes from it, skipping the blocks it reflects, rather
+// than starting from initialState; the blocks must then be the same pipeline that wrote it.
+func StartWithCheckpoints(blocks []Block, initialState []AccountValue, numWorkers int, path string, every int) ([]AccountValue, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+	if every < 1 {
+		return nil, fmt.Errorf("%w: checkpoint interval must be at least 1, got %d", ErrInvalidOption, every)
+	}
+
+	state := NewInMemoryAccountState(initialState)
+	start := 0
+	saved, err := readStateFile(path)
+	switch {
+	case err == nil:
+		if saved.Blocks > len(blocks) {
+			return nil, fmt.Errorf("%w: checkpoint reflects %d blocks, pipeline has %d", ErrBlockOutOfRange, saved.Blocks, len(blocks))
+		}
+		state = NewInMemoryAccountState(saved.Accounts)
+		start = saved.Blocks
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	for i := start; i < len(blocks); i++ {
+		if _, _, err := ExecuteBlockWithOptions(context.Background(), blocks[i], state, numWorkers, BlockOptions{BlockIndex: i}); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		if done := i + 1; done%every == 0 || done == len(blocks) {
+			if err := writeStateFile(path, stateFile{Blocks: done, Accounts: state.getSnapshot()}); err != nil {
+				return nil, fmt.Errorf("block %d: %w", i, err)
+			}
+		}
+	}
+
+	return state.getSnapshot(), nil
+}
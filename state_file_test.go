@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInMemoryAccountState_SaveAndLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	blocks := []Block{
+		{Transactions: []Transaction{transfer{from: "A", to: "B", value: 30}}},
+		{Transactions: []Transaction{transfer{from: "B", to: "C", value: 10}, &mint{to: "D", value: 5}}},
+		{Transactions: []Transaction{transfer{from: "A", to: "C", value: 20}}},
+		{Transactions: []Transaction{transfer{from: "C", to: "A", value: 25}}},
+	}
+	initialState := []AccountValue{{Name: "A", Balance: 100, Metadata: map[string]string{"owner": "alice"}}}
+
+	expected, err := Start(blocks, initialState, 2)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Run half the pipeline, save, and continue from a fresh state loaded from disk
+	state := NewInMemoryAccountState(initialState)
+	for _, block := range blocks[:2] {
+		if _, _, err := ExecuteBlock(block, state, 2); err != nil {
+			t.Fatalf("ExecuteBlock failed: %v", err)
+		}
+	}
+	if err := state.SaveState(path); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	resumed := NewInMemoryAccountState([]AccountValue{{Name: "Stale", Balance: 1}})
+	if err := resumed.LoadState(path); err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if !SnapshotsEqual(resumed.GetSnapshot(), state.GetSnapshot()) {
+		t.Fatalf("Loaded state %+v doesn't match saved state %+v", resumed.GetSnapshot(), state.GetSnapshot())
+	}
+	if owner := resumed.GetAccount("A").Metadata["owner"]; owner != "alice" {
+		t.Errorf("Expected metadata to be restored, got owner %q", owner)
+	}
+	for _, block := range blocks[2:] {
+		if _, _, err := ExecuteBlock(block, resumed, 2); err != nil {
+			t.Fatalf("ExecuteBlock failed: %v", err)
+		}
+	}
+	if !SnapshotsEqual(resumed.GetSnapshot(), expected) {
+		t.Errorf("Expected %+v, got %+v", expected, resumed.GetSnapshot())
+	}
+
+	if err := resumed.LoadState(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file to be reported, got %v", err)
+	}
+}
+
+func TestStartWithCheckpoints_ResumesAfterFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.json")
+	initialState := []AccountValue{{Name: "A", Balance: 100}}
+
+	var executed []int
+	var blocks []Block
+	for i := 0; i < 5; i++ {
+		blocks = append(blocks, Block{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 10},
+			cancellingTransfer{transfer{from: "B", to: "C", value: 5}, func() { executed = append(executed, i) }},
+		}})
+	}
+	expected, err := Start(blocks, initialState, 2)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	executed = nil
+
+	// Block 3 can't be scheduled, so the first run stops there with a checkpoint after block 1
+	broken := append([]Block(nil), blocks...)
+	broken[3] = Block{Transactions: blocks[3].Transactions, Dependencies: map[int][]int{0: {0}}}
+	if _, err := StartWithCheckpoints(broken, initialState, 2, path, 2); !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("Expected the first run to fail with ErrDependencyCycle, got %v", err)
+	}
+
+	executed = nil
+	accounts, err := StartWithCheckpoints(blocks, initialState, 2, path, 2)
+	if err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	if !SnapshotsEqual(accounts, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, accounts)
+	}
+	if len(executed) != 3 || executed[0] != 2 {
+		t.Errorf("Expected the resumed run to execute blocks 2 to 4, executed %v", executed)
+	}
+
+	// The finished pipeline is checkpointed in full, so running it again executes nothing
+	executed = nil
+	if again, err := StartWithCheckpoints(blocks, initialState, 2, path, 2); err != nil || !SnapshotsEqual(again, expected) {
+		t.Errorf("Expected the completed pipeline to be restored, got %+v, %v", again, err)
+	}
+	if len(executed) != 0 {
+		t.Errorf("Expected no blocks to execute, executed %v", executed)
+	}
+}
+
+func TestStartWithCheckpoints_InvalidInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if _, err := StartWithCheckpoints(nil, nil, 1, path, 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
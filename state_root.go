@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// StateRoot returns a SHA-256 hash identifying the given account state, allowing two executors
+// to verify they reached the same state without comparing full snapshots. The root doesn't
+// depend on the order of snapshot, only on the accounts it contains and their balances.
+func StateRoot(snapshot []AccountValue) [32]byte {
+	accounts := append([]AccountValue(nil), snapshot...)
+	sortAccounts(accounts)
+
+	// Canonical encoding: each account as its length-prefixed name and native balance, followed
+	// by its nonzero asset balances sorted by asset name
+	var buf []byte
+	for _, acc := range accounts {
+		buf = appendString(buf, acc.Name)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(acc.Balance))
+
+		assets := make([]string, 0, len(acc.Assets))
+		for asset, balance := range acc.Assets {
+			if balance != 0 {
+				assets = append(assets, asset)
+			}
+		}
+		sort.Strings(assets)
+		buf = binary.AppendUvarint(buf, uint64(len(assets)))
+		for _, asset := range assets {
+			buf = appendString(buf, asset)
+			buf = binary.BigEndian.AppendUint64(buf, uint64(acc.Assets[asset]))
+		}
+	}
+	return sha256.Sum256(buf)
+}
+
+// appendString appends s to buf prefixed by its length
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
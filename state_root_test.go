@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStateRoot_OrderIndependent(t *testing.T) {
+	a := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50, Assets: map[string]uint{"gold": 3, "silver": 7}},
+		{Name: "C", Balance: 0},
+	}
+	b := []AccountValue{a[2], a[0], a[1]}
+
+	if StateRoot(a) != StateRoot(b) {
+		t.Error("Expected the same accounts in a different order to have the same root")
+	}
+}
+
+func TestStateRoot_DetectsChanges(t *testing.T) {
+	base := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50, Assets: map[string]uint{"gold": 3}},
+	}
+	root := StateRoot(base)
+
+	changed := map[string][]AccountValue{
+		"native balance": {
+			{Name: "A", Balance: 101},
+			{Name: "B", Balance: 50, Assets: map[string]uint{"gold": 3}},
+		},
+		"asset balance": {
+			{Name: "A", Balance: 100},
+			{Name: "B", Balance: 50, Assets: map[string]uint{"gold": 4}},
+		},
+		"extra account": {
+			{Name: "A", Balance: 100},
+			{Name: "B", Balance: 50, Assets: map[string]uint{"gold": 3}},
+			{Name: "C", Balance: 0},
+		},
+		"renamed account": {
+			{Name: "AB", Balance: 100},
+			{Name: "", Balance: 50, Assets: map[string]uint{"gold": 3}},
+		},
+	}
+	for name, snapshot := range changed {
+		if StateRoot(snapshot) == root {
+			t.Errorf("Expected a change in %s to alter the root", name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// StartStream executes blocks as they arrive on the blocks channel and emits the result of
+// each one on the returned channel, in order. State persists across blocks as with Start.
+// The returned channel is closed once blocks is closed and every result has been received,
+// or once ctx is done; a block interrupted by ctx leaves state reflecting a prefix of its
+// transactions and its result isn't emitted. A block that fails otherwise, e.g. with
+// ErrDependencyCycle, stops the stream: its result is emitted with Err set, the rest of blocks
+// is received and discarded until it's closed or ctx is done, so that senders aren't blocked,
+// and the returned channel is then closed. Use Executor.Stream to stop without interrupting blocks.
+func StartStream(ctx context.Context, blocks <-chan Block, initial []AccountValue, numWorkers int) (<-chan BlockResult, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidWorkerCount, numWorkers)
+	}
+
+	state := NewInMemoryAccountState(initial)
+	results := make(chan BlockResult)
+	go streamBlocks(ctx, blocks, state, numWorkers, BlockOptions{}, results, nil, newPauseGate(), ctx.Done(), ctx.Done())
+	return results, nil
+}
+
+// streamBlocks executes blocks from the blocks channel under ctx with opts and sends their
+// results, closing results when it returns, and records checkpoints in history, which may be
+// nil. It stops taking blocks once blocks or stop is closed, waits to take them while gate is
+// paused, and gives up on sending a result once abandon is closed. A block that fails stops
+// the stream: its result is sent with Err set, unless it was interrupted by ctx, and blocks is
+// drained until it or stop is closed.
+func streamBlocks(ctx context.Context, blocks <-chan Block, state AccountState, numWorkers int, opts BlockOptions,
+	results chan<- BlockResult, history *snapshotHistory, gate *pauseGate, stop, abandon <-chan struct{}) {
+	defer close(results)
+	for index := 0; ; {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		paused, resumed := gate.state()
+		select {
+		case <-resumed:
+		case <-stop:
+			return
+		}
+
+		var block Block
+		select {
+		case b, ok := <-blocks:
+			if !ok {
+				return
+			}
+			block = b
+		case <-paused:
+			continue
+		case <-stop:
+			return
+		}
+
+		// A block taken just as the gate was paused waits for it to resume, unless the stream
+		// stops, in which case it's executed rather than dropped
+		_, resumed = gate.state()
+		select {
+		case <-resumed:
+		case <-stop:
+		}
+
+		opts.BlockIndex = index
+		index++
+		snapshot, result, err := ExecuteBlockWithOptions(ctx, block, state, numWorkers, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			result.Err = err
+			select {
+			case results <- result:
+			case <-abandon:
+				return
+			}
+			drainBlocks(blocks, stop)
+			return
+		}
+		history.record(index, snapshot)
+		select {
+		case results <- result:
+		case <-abandon:
+			return
+		}
+	}
+}
+
+// drainBlocks receives and discards blocks until it or stop is closed
+func drainBlocks(blocks <-chan Block, stop <-chan struct{}) {
+	for {
+		select {
+		case _, ok := <-blocks:
+			if !ok {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStartStream_ProcessesBlocksInOrder(t *testing.T) {
+	blocks := make(chan Block)
+	results, err := StartStream(context.Background(), blocks, []AccountValue{{Name: "A", Balance: 100}}, 2)
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+
+	go func() {
+		blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 60}}}
+		blocks <- Block{Transactions: []Transaction{
+			transfer{from: "A", to: "C", value: 60}, // fails, A has 40 left
+			transfer{from: "B", to: "C", value: 60},
+		}}
+		blocks <- Block{Transactions: []Transaction{transfer{from: "C", to: "A", value: 10}}}
+		close(blocks)
+	}()
+
+	var collected []BlockResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+
+	if len(collected) != 3 {
+		t.Fatalf("Expected 3 block results, got %d", len(collected))
+	}
+	expected := [][]bool{{true}, {false, true}, {true}}
+	for i, applied := range expected {
+		for j, want := range applied {
+			if got := collected[i].Transactions[j].Applied; got != want {
+				t.Errorf("Block %d transaction %d: expected applied=%v, got %v", i, j, want, got)
+			}
+		}
+	}
+}
+
+func TestStartStream_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	blocks := make(chan Block) // never closed
+	results, err := StartStream(ctx, blocks, nil, 1)
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Error("Expected no results after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the results channel to be closed after cancellation")
+	}
+}
+
+func TestStartStream_ReportsFailedBlock(t *testing.T) {
+	blocks := make(chan Block)
+	results, err := StartStream(context.Background(), blocks, []AccountValue{{Name: "A", Balance: 100}}, 2)
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "B", value: 10}}}
+		blocks <- Block{
+			Transactions: []Transaction{transfer{from: "A", to: "C", value: 10}, transfer{from: "A", to: "D", value: 10}},
+			Dependencies: map[int][]int{0: {1}, 1: {0}},
+		}
+		// Blocks after the failed one are discarded rather than left blocking the sender
+		for i := 0; i < 3; i++ {
+			blocks <- Block{Transactions: []Transaction{transfer{from: "A", to: "E", value: 10}}}
+		}
+		close(blocks)
+	}()
+
+	var collected []BlockResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+	if len(collected) != 2 {
+		t.Fatalf("Expected 2 block results, got %d", len(collected))
+	}
+	if collected[0].Err != nil {
+		t.Errorf("Expected the first block to succeed, got %v", collected[0].Err)
+	}
+	if !errors.Is(collected[1].Err, ErrDependencyCycle) {
+		t.Errorf("Expected the second block to fail with ErrDependencyCycle, got %v", collected[1].Err)
+	}
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Sender blocked after the stream stopped")
+	}
+}
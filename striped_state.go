@@ -0,0 +1,168 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// DefaultStripeCount is the number of stripes used by NewStripedAccountState when none is given
+const DefaultStripeCount = 64
+
+// StripedAccountState implements AccountState with accounts spread across stripes, each guarded
+// by its own lock, so updates touching disjoint accounts can be applied in parallel. An update
+// spanning several stripes locks them in ascending order, which keeps concurrent multi-account
+// updates from deadlocking.
+type StripedAccountState struct {
+	stripes []accountStripe
+}
+
+// accountStripe holds the accounts whose names hash to it
+type accountStripe struct {
+	mu       sync.RWMutex
+	accounts map[string]uint            // native balances, keyed by account name
+	assets   map[string]map[string]uint // non-native balances, keyed by account then asset
+}
+
+// NewStripedAccountState creates a new account state spread across numStripes stripes.
+// If numStripes is less than 1, DefaultStripeCount is used.
+func NewStripedAccountState(initialAccounts []AccountValue, numStripes int) *StripedAccountState {
+	if numStripes < 1 {
+		numStripes = DefaultStripeCount
+	}
+	state := &StripedAccountState{stripes: make([]accountStripe, numStripes)}
+	for i := range state.stripes {
+		state.stripes[i].accounts = make(map[string]uint)
+		state.stripes[i].assets = make(map[string]map[string]uint)
+	}
+
+	for _, acc := range initialAccounts {
+		stripe := state.stripe(acc.Name)
+		stripe.accounts[acc.Name] = acc.Balance
+		if len(acc.Assets) > 0 {
+			stripe.assets[acc.Name] = copyBalances(acc.Assets)
+		}
+	}
+
+	return state
+}
+
+// stripeIndex returns the index of the stripe holding the given account
+func (s *StripedAccountState) stripeIndex(name string) int {
+	return int(nameHash(name) % uint32(len(s.stripes)))
+}
+
+// nameHash hashes an account name to spread accounts across stripes or shards
+func nameHash(name string) uint32 {
+	// FNV-1a, inlined to avoid allocating a hash.Hash per lookup
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func (s *StripedAccountState) stripe(name string) *accountStripe {
+	return &s.stripes[s.stripeIndex(name)]
+}
+
+// GetAccount implements AccountState interface
+func (s *StripedAccountState) GetAccount(name string) AccountValue {
+	stripe := s.stripe(name)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	return stripe.accountValue(name)
+}
+
+// accountValue returns the account with copies of its asset balances. The caller must hold the lock.
+func (st *accountStripe) accountValue(name string) AccountValue {
+	value := AccountValue{
+		Name:    name,
+		Balance: st.accounts[name],
+	}
+	if assets, ok := st.assets[name]; ok {
+		value.Assets = copyBalances(assets)
+	}
+	return value
+}
+
+// HasAccount implements AccountState interface
+func (s *StripedAccountState) HasAccount(name string) bool {
+	stripe := s.stripe(name)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	_, ok := stripe.accounts[name]
+	return ok
+}
+
+// ApplyUpdates implements AccountState interface. Only the stripes holding the updated accounts
+// are locked. Updates are validated before any of them is written, so on error the state is left
+// unchanged.
+func (s *StripedAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	locked := make([]int, 0, len(updates))
+	for _, update := range updates {
+		locked = append(locked, s.stripeIndex(update.Name))
+	}
+	sort.Ints(locked)
+	locked = slices.Compact(locked)
+	for _, i := range locked {
+		s.stripes[i].mu.Lock()
+	}
+	defer func() {
+		for _, i := range locked {
+			s.stripes[i].mu.Unlock()
+		}
+	}()
+
+	staged := make(map[string]accountEntry, len(updates))
+	for _, update := range updates {
+		entry, ok := staged[update.Name]
+		if !ok {
+			stripe := s.stripe(update.Name)
+			entry.balance, entry.exists = stripe.accounts[update.Name]
+			entry.assets = stripe.assets[update.Name]
+		}
+
+		entry, err := applyUpdate(entry, update, false)
+		if err != nil {
+			return err
+		}
+		staged[update.Name] = entry
+	}
+
+	for name, entry := range staged {
+		stripe := s.stripe(name)
+		if entry.exists {
+			stripe.accounts[name] = entry.balance
+		} else {
+			delete(stripe.accounts, name)
+		}
+		if entry.exists && len(entry.assets) > 0 {
+			stripe.assets[name] = entry.assets
+		} else {
+			delete(stripe.assets, name)
+		}
+	}
+	return nil
+}
+
+// GetSnapshot returns the current state of all accounts, sorted by name. Every stripe is locked for the
+// duration, so the snapshot never observes a partially applied update.
+func (s *StripedAccountState) GetSnapshot() []AccountValue {
+	for i := range s.stripes {
+		s.stripes[i].mu.RLock()
+		defer s.stripes[i].mu.RUnlock()
+	}
+
+	var result []AccountValue
+	for i := range s.stripes {
+		for name := range s.stripes[i].accounts {
+			result = append(result, s.stripes[i].accountValue(name))
+		}
+	}
+	sortAccounts(result)
+	return result
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStripedAccountState_MatchesInMemoryState(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50},
+		{Name: "C", Balance: 10},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		transfer{from: "C", to: "D", value: 20}, // fails
+		transfer{from: "B", to: "C", value: 60},
+		transfer{from: "A", to: "E", value: 5},
+	}}
+
+	expected, _, err := ExecuteBlock(block, NewInMemoryAccountState(initialState), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on in-memory state failed: %v", err)
+	}
+	actual, _, err := ExecuteBlock(block, NewStripedAccountState(initialState, 4), 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock on striped state failed: %v", err)
+	}
+	if !SnapshotsEqual(expected, actual) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestStripedAccountState_UpdatesAreAtomicAcrossStripes(t *testing.T) {
+	state := NewStripedAccountState([]AccountValue{
+		{Name: "A", Balance: 10},
+		{Name: "B", Balance: 10},
+	}, 16)
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "A", BalanceChange: 5},
+		{Name: "B", BalanceChange: -20},
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 10, "B": 10})
+}
+
+func TestStripedAccountState_ConcurrentOverlappingUpdates(t *testing.T) {
+	const numAccounts = 8
+	var initialState []AccountValue
+	for i := 0; i < numAccounts; i++ {
+		initialState = append(initialState, AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 1000})
+	}
+	state := NewStripedAccountState(initialState, 4)
+
+	// Goroutines move funds around a ring in opposite directions, locking overlapping stripes
+	var wg sync.WaitGroup
+	for g := 0; g < numAccounts; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			from, to := fmt.Sprintf("A%d", g), fmt.Sprintf("A%d", (g+1)%numAccounts)
+			if g%2 == 1 {
+				from, to = to, from
+			}
+			for i := 0; i < 100; i++ {
+				if err := state.ApplyUpdates([]AccountUpdate{
+					{Name: from, BalanceChange: -1},
+					{Name: to, BalanceChange: 1},
+				}); err != nil {
+					t.Errorf("ApplyUpdates failed: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != numAccounts*1000 {
+		t.Errorf("Expected total balance %d, got %d (%v)", numAccounts*1000, total, err)
+	}
+}
+
+func BenchmarkAccountState_DisjointUpdates(b *testing.B) {
+	const numAccounts = 1024
+	var initialState []AccountValue
+	for i := 0; i < numAccounts; i++ {
+		initialState = append(initialState, AccountValue{Name: fmt.Sprintf("A%d", i), Balance: 1 << 40})
+	}
+
+	states := []struct {
+		name  string
+		state AccountState
+	}{
+		{"single-lock", NewInMemoryAccountState(initialState)},
+		{"striped", NewStripedAccountState(initialState, DefaultStripeCount)},
+		{"sharded", NewShardedAccountState(initialState, DefaultShardCount)},
+	}
+	for _, s := range states {
+		b.Run(s.name, func(b *testing.B) {
+			var next sync.Mutex
+			goroutine := 0
+			b.RunParallel(func(pb *testing.PB) {
+				// Each goroutine updates its own pair of accounts
+				next.Lock()
+				g := goroutine
+				goroutine++
+				next.Unlock()
+				updates := []AccountUpdate{
+					{Name: fmt.Sprintf("A%d", (2*g)%numAccounts), BalanceChange: -1},
+					{Name: fmt.Sprintf("A%d", (2*g+1)%numAccounts), BalanceChange: 1},
+				}
+				for pb.Next() {
+					if err := s.state.ApplyUpdates(updates); err != nil {
+						b.Errorf("ApplyUpdates failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
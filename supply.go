@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SupplyConserving is implemented by transactions that only move funds between accounts,
+// such as transfers. When supply checking is enabled, the executor verifies that the updates
+// of such transactions net to zero for every asset.
+type SupplyConserving interface {
+	ConservesSupply() bool
+}
+
+// TotalBalance returns the sum of the native balances in snapshot. It fails with ErrOverflow
+// if the sum doesn't fit a uint.
+func TotalBalance(snapshot []AccountValue) (uint, error) {
+	var total uint
+	for _, acc := range snapshot {
+		if acc.Balance > math.MaxUint-total {
+			return 0, fmt.Errorf("%w: total balance at account %s", ErrOverflow, acc.Name)
+		}
+		total += acc.Balance
+	}
+	return total, nil
+}
+
+// checkSupply returns ErrSupplyViolation if tx declares that it conserves supply but its
+// updates create or destroy funds
+func checkSupply(tx Transaction, updates []AccountUpdate) error {
+	conserving, ok := tx.(SupplyConserving)
+	if !ok || !conserving.ConservesSupply() {
+		return nil
+	}
+
+	net := make(map[string]int)
+	for _, update := range updates {
+		if update.Op != OpBalanceChange {
+			return fmt.Errorf("%w: operation %d on account %s", ErrSupplyViolation, update.Op, update.Name)
+		}
+		net[update.Asset] += update.BalanceChange
+	}
+
+	var assets []string
+	for asset, change := range net {
+		if change != 0 {
+			assets = append(assets, asset)
+		}
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+
+	sort.Strings(assets)
+	asset := assets[0]
+	if asset == NativeAsset {
+		return fmt.Errorf("%w: net change of %d", ErrSupplyViolation, net[asset])
+	}
+	return fmt.Errorf("%w: net change of %d %s", ErrSupplyViolation, net[asset], asset)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// mintingTransfer is a buggy transfer that credits more than it debits
+type mintingTransfer struct {
+	transfer
+}
+
+func (t mintingTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	updates, err := t.transfer.Updates(state)
+	if err != nil {
+		return nil, err
+	}
+	updates[1].BalanceChange++
+	return updates, nil
+}
+
+func TestTotalBalance_Overflow(t *testing.T) {
+	snapshot := []AccountValue{{Name: "A", Balance: math.MaxUint}, {Name: "B", Balance: 1}}
+	if _, err := TotalBalance(snapshot); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+}
+
+func TestTotalBalance(t *testing.T) {
+	snapshot := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30, Assets: map[string]uint{"USD": 100}},
+		{Name: "C", Balance: 0},
+	}
+	if total, err := TotalBalance(snapshot); err != nil || total != 50 {
+		t.Errorf("Expected total 50, got %d (%v)", total, err)
+	}
+}
+
+func TestExecuteBlock_CheckSupplyFlagsMintingTransfer(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 100},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		mintingTransfer{transfer{from: "B", to: "A", value: 10}},
+	}}
+
+	// Without checking, the buggy transaction silently mints funds
+	state := NewInMemoryAccountState(initialState)
+	if _, _, err := ExecuteBlock(block, state, 2); err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != 201 {
+		t.Errorf("Expected total 201, got %d (%v)", total, err)
+	}
+
+	state = NewInMemoryAccountState(initialState)
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{CheckSupply: true})
+	if !errors.Is(err, ErrSupplyViolation) {
+		t.Fatalf("Expected ErrSupplyViolation, got %v", err)
+	}
+	if result.Transactions[1].Applied || !errors.Is(result.Transactions[1].Err, ErrSupplyViolation) {
+		t.Errorf("Expected transaction 1 to be rejected, got %+v", result.Transactions[1])
+	}
+	if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != 200 {
+		t.Errorf("Expected total supply to stay 200, got %d (%v)", total, err)
+	}
+}
+
+func TestExecuteBlock_CheckSupplyAllowsUntaggedTransactions(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		mint{to: "A", value: 50},
+		transfer{from: "A", to: "B", value: 25},
+	}}
+
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{CheckSupply: true}); err != nil {
+		t.Fatalf("ExecuteBlockWithOptions failed: %v", err)
+	}
+	if total, err := TotalBalance(state.GetSnapshot()); err != nil || total != 150 {
+		t.Errorf("Expected total 150, got %d (%v)", total, err)
+	}
+}
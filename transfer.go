@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func init() {
+	RegisterTransactionType("transfer", func() Transaction { return Transfer{} })
+}
+
+// Transfer is a transaction moving Amount of the native asset from one account to another,
+// creating the recipient if it doesn't exist. It fails with ErrAccountNotFound if the sender
+// doesn't exist and with ErrInsufficientBalance if it holds less than Amount. A transfer from
+// an account to itself is checked the same way but moves nothing, so it has no updates.
+// Amounts above math.MaxInt can't be expressed as a balance change and fail with ErrOverflow.
+type Transfer struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount uint   `json:"amount"`
+}
+
+// AccessSet implements AccessAware
+func (t Transfer) AccessSet() ([]string, []string) {
+	return []string{t.From}, []string{t.From, t.To}
+}
+
+// ConservesSupply implements SupplyConserving
+func (Transfer) ConservesSupply() bool { return true }
+
+// Hash implements Hashable
+func (t Transfer) Hash() [32]byte {
+	return contentHash("transfer", t)
+}
+
+// Validate implements Validator, checking that the sender exists and the amount fits a
+// balance change
+func (t Transfer) Validate(state ReadOnlyState) error {
+	if t.Amount > math.MaxInt {
+		return fmt.Errorf("%w: transfer amount %d", ErrOverflow, t.Amount)
+	}
+	if !state.HasAccount(t.From) {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, t.From)
+	}
+	return nil
+}
+
+// Updates implements Transaction
+func (t Transfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if err := t.Validate(state); err != nil {
+		return nil, err
+	}
+	if balance := state.GetAccount(t.From).Balance; balance < t.Amount {
+		return nil, fmt.Errorf("%w: account %s has %d, needs %d", ErrInsufficientBalance, t.From, balance, t.Amount)
+	}
+	if t.From == t.To {
+		return nil, nil
+	}
+	return []AccountUpdate{
+		{Name: t.From, BalanceChange: -int(t.Amount)},
+		{Name: t.To, BalanceChange: int(t.Amount)},
+	}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTransfer_Updates(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 50}, {Name: "B", Balance: 10}})
+
+	tests := []struct {
+		name     string
+		transfer Transfer
+		updates  []AccountUpdate
+		err      error
+	}{
+		{
+			name:     "existing recipient",
+			transfer: Transfer{From: "A", To: "B", Amount: 20},
+			updates:  []AccountUpdate{{Name: "A", BalanceChange: -20}, {Name: "B", BalanceChange: 20}},
+		},
+		{
+			name:     "new recipient",
+			transfer: Transfer{From: "A", To: "C", Amount: 50},
+			updates:  []AccountUpdate{{Name: "A", BalanceChange: -50}, {Name: "C", BalanceChange: 50}},
+		},
+		{name: "insufficient balance", transfer: Transfer{From: "B", To: "A", Amount: 11}, err: ErrInsufficientBalance},
+		{name: "missing sender", transfer: Transfer{From: "C", To: "A", Amount: 0}, err: ErrAccountNotFound},
+		{name: "self-transfer", transfer: Transfer{From: "A", To: "A", Amount: 50}},
+		{name: "self-transfer exceeding balance", transfer: Transfer{From: "A", To: "A", Amount: 51}, err: ErrInsufficientBalance},
+		{name: "amount too large", transfer: Transfer{From: "A", To: "B", Amount: math.MaxInt + 1}, err: ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := tt.transfer.Updates(state)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(updates, tt.updates) {
+				t.Errorf("Expected updates %v, got %v", tt.updates, updates)
+			}
+		})
+	}
+}
+
+func TestTransfer_Example1(t *testing.T) {
+	blocks := []Block{NewBlockBuilder().
+		AddTransfer("A", "B", 5).
+		AddTransfer("B", "C", 10).
+		AddTransfer("B", "C", 30). // fails
+		AddTransfer("D", "A", 0).  // fails, D doesn't exist
+		AddTransfer("C", "C", 50).
+		Build()}
+
+	result, err := Start(blocks, []AccountValue{{Name: "A", Balance: 20}, {Name: "B", Balance: 30}, {Name: "C", Balance: 40}}, 4)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	verifyResults(t, result, map[string]uint{"A": 15, "B": 25, "C": 50})
+}
+
+func TestTransfer_JSON(t *testing.T) {
+	block := Block{Transactions: []Transaction{Transfer{From: "A", To: "B", Amount: 5}}}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded Block
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(block, decoded) {
+		t.Errorf("Round trip mismatch: expected %#v, got %#v", block, decoded)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TwoPhaseState is implemented by states, such as remote or sharded backends, that apply
+// updates in two steps. Prepare validates updates and reserves what they need without making
+// them visible, returning an ID that is then either committed or aborted. When the state passed
+// to ExecuteBlock implements TwoPhaseState, updates are applied through it instead of ApplyUpdates.
+type TwoPhaseState interface {
+	Prepare(updates []AccountUpdate) (txID string, err error)
+	// Commit applies prepared updates. The transaction is finished whether or not it succeeds.
+	Commit(txID string) error
+	// Abort discards prepared updates, releasing anything they reserved
+	Abort(txID string) error
+}
+
+// applyTwoPhase prepares and commits updates as a single step
+func applyTwoPhase(state TwoPhaseState, updates []AccountUpdate) error {
+	txID, err := state.Prepare(updates)
+	if err != nil {
+		return err
+	}
+	return state.Commit(txID)
+}
+
+// applyUpdatesTo applies updates committed by the transaction at txIndex of the block at
+// blockIndex, using the two-phase protocol or recording ledger entries if state supports it
+func applyUpdatesTo(state AccountState, updates []AccountUpdate, blockIndex, txIndex int) error {
+	switch s := state.(type) {
+	case TwoPhaseState:
+		return applyTwoPhase(s, updates)
+	case interface {
+		applyUpdates([]AccountUpdate, int, int) error
+	}:
+		return s.applyUpdates(updates, blockIndex, txIndex)
+	default:
+		return state.ApplyUpdates(updates)
+	}
+}
+
+// TwoPhaseAccountState is an in-memory reference implementation of TwoPhaseState. Preparing
+// updates reserves the native balance they would take from each account, so later prepares
+// can't spend it before they are committed or aborted. Reads return balances including
+// reserved funds, as they haven't left the account yet.
+type TwoPhaseAccountState struct {
+	*InMemoryAccountState
+	mu       sync.Mutex
+	nextID   int
+	prepared map[string]preparedUpdates
+	reserved map[string]uint // native balance reserved by prepared updates, per account
+}
+
+// preparedUpdates are the updates of a prepared transaction and the balances they reserved
+type preparedUpdates struct {
+	updates  []AccountUpdate
+	reserved map[string]uint
+}
+
+func NewTwoPhaseAccountState(initialAccounts []AccountValue) *TwoPhaseAccountState {
+	return &TwoPhaseAccountState{
+		InMemoryAccountState: NewInMemoryAccountState(initialAccounts),
+		prepared:             make(map[string]preparedUpdates),
+		reserved:             make(map[string]uint),
+	}
+}
+
+// Prepare implements TwoPhaseState. It validates updates against balances net of existing
+// reservations, and against the constraints of the state such as frozen accounts, minimum
+// balances and its name policy, and reserves every account's resulting decrease in native
+// balance.
+func (s *TwoPhaseAccountState) Prepare(updates []AccountUpdate) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available := make(map[string]uint, len(updates))
+	staged := make(map[string]accountEntry, len(updates))
+	for _, update := range updates {
+		name := s.InMemoryAccountState.normalizeAccountName(update.Name)
+		entry, ok := staged[name]
+		if !ok {
+			acc := s.InMemoryAccountState.GetAccount(name)
+			entry = accountEntry{
+				// Balances changed around the reservations can be below them
+				balance: acc.Balance - min(acc.Balance, s.reserved[name]),
+				assets:  acc.Assets,
+				exists:  s.InMemoryAccountState.HasAccount(name),
+			}
+			available[name] = entry.balance
+		}
+
+		entry, err := applyUpdate(entry, update, s.clampsUnderflow())
+		if err != nil {
+			return "", err
+		}
+		if err := s.InMemoryAccountState.validateUpdate(update, entry.balance); err != nil {
+			return "", err
+		}
+		staged[name] = entry
+	}
+
+	reserved := make(map[string]uint)
+	for name, entry := range staged {
+		if entry.balance < available[name] {
+			reserved[name] = available[name] - entry.balance
+			s.reserved[name] += reserved[name]
+		}
+	}
+
+	s.nextID++
+	txID := fmt.Sprintf("tx-%d", s.nextID)
+	s.prepared[txID] = preparedUpdates{updates: append([]AccountUpdate(nil), updates...), reserved: reserved}
+	return txID, nil
+}
+
+// Commit implements TwoPhaseState. Only native balances are reserved, so the updates can
+// still fail if other updates committed since they were prepared conflict with them.
+func (s *TwoPhaseAccountState) Commit(txID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prepared, err := s.release(txID)
+	if err != nil {
+		return err
+	}
+	return s.InMemoryAccountState.ApplyUpdates(prepared.updates)
+}
+
+// Abort implements TwoPhaseState
+func (s *TwoPhaseAccountState) Abort(txID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.release(txID)
+	return err
+}
+
+// release removes a prepared transaction and its reservations. The caller must hold the lock.
+func (s *TwoPhaseAccountState) release(txID string) (preparedUpdates, error) {
+	prepared, ok := s.prepared[txID]
+	if !ok {
+		return preparedUpdates{}, fmt.Errorf("%w: %s", ErrNotPrepared, txID)
+	}
+	delete(s.prepared, txID)
+	for name, amount := range prepared.reserved {
+		if s.reserved[name] -= amount; s.reserved[name] == 0 {
+			delete(s.reserved, name)
+		}
+	}
+	return prepared, nil
+}
+
+// ApplyUpdates implements AccountState by preparing and immediately committing updates, so
+// they respect balances reserved by other prepared transactions
+func (s *TwoPhaseAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	return applyTwoPhase(s, updates)
+}
+
+// commitBuffered applies the buffered updates of an atomic block, recording origins[i] as the
+// transaction of updates[i] in ledger entries if state supports it. A two-phase state prepares
+// them first and, if the block was cancelled in the meantime, aborts them instead of committing.
+func commitBuffered(ctx context.Context, state AccountState, updates []AccountUpdate, origins []ledgerOrigin) error {
+	twoPhase, ok := state.(TwoPhaseState)
+	if !ok {
+		if buffered, ok := state.(interface {
+			applyBuffered([]AccountUpdate, []ledgerOrigin) error
+		}); ok {
+			return buffered.applyBuffered(updates, origins)
+		}
+		return state.ApplyUpdates(updates)
+	}
+
+	txID, err := twoPhase.Prepare(updates)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		if abortErr := twoPhase.Abort(txID); abortErr != nil {
+			return fmt.Errorf("%w (abort: %v)", err, abortErr)
+		}
+		return err
+	}
+	return twoPhase.Commit(txID)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTwoPhaseAccountState_PrepareThenAbort(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 50},
+	}
+	state := NewTwoPhaseAccountState(initialState)
+
+	txID, err := state.Prepare([]AccountUpdate{
+		{Name: "A", BalanceChange: -80},
+		{Name: "B", BalanceChange: 80},
+		{Name: "C", Op: OpCreate, Balance: 10},
+	})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100, "B": 50})
+
+	// The reserved funds can't be spent by another prepared transaction
+	if _, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -30}}); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance spending reserved funds, got %v", err)
+	}
+
+	if err := state.Abort(txID); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100, "B": 50})
+	if state.HasAccount("C") {
+		t.Error("Aborted transaction created an account")
+	}
+	if err := state.Commit(txID); !errors.Is(err, ErrNotPrepared) {
+		t.Errorf("Expected ErrNotPrepared committing an aborted transaction, got %v", err)
+	}
+
+	// Aborting released the reservation
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -30}}); err != nil {
+		t.Fatalf("ApplyUpdates after abort failed: %v", err)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 70, "B": 50})
+}
+
+func TestTwoPhaseAccountState_PrepareThenCommit(t *testing.T) {
+	state := NewTwoPhaseAccountState([]AccountValue{{Name: "A", Balance: 100}})
+
+	first, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -60}, {Name: "B", BalanceChange: 60}})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	second, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -40}, {Name: "C", BalanceChange: 40}})
+	if err != nil {
+		t.Fatalf("Prepare of remaining balance failed: %v", err)
+	}
+
+	for _, txID := range []string{second, first} {
+		if err := state.Commit(txID); err != nil {
+			t.Fatalf("Commit %s failed: %v", txID, err)
+		}
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 0, "B": 60, "C": 40})
+}
+
+// preparingState counts the two-phase calls made on the state it wraps
+type preparingState struct {
+	*TwoPhaseAccountState
+	prepares, commits, aborts int
+}
+
+func (s *preparingState) Prepare(updates []AccountUpdate) (string, error) {
+	s.prepares++
+	return s.TwoPhaseAccountState.Prepare(updates)
+}
+
+func (s *preparingState) Commit(txID string) error {
+	s.commits++
+	return s.TwoPhaseAccountState.Commit(txID)
+}
+
+func (s *preparingState) Abort(txID string) error {
+	s.aborts++
+	return s.TwoPhaseAccountState.Abort(txID)
+}
+
+func TestTwoPhaseAccountState_PrepareValidates(t *testing.T) {
+	state := NewTwoPhaseAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 50}})
+	state.Freeze("B")
+	state.SetMinimumBalance("A", 10)
+
+	if _, err := state.Prepare([]AccountUpdate{{Name: "B", BalanceChange: 10}}); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("Expected ErrAccountFrozen, got %v", err)
+	}
+	if _, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -95}}); !errors.Is(err, ErrBelowMinimum) {
+		t.Errorf("Expected ErrBelowMinimum, got %v", err)
+	}
+
+	// Reservations above a balance changed behind them leave nothing available
+	if _, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -80}}); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if err := state.InMemoryAccountState.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -50}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	if _, err := state.Prepare([]AccountUpdate{{Name: "A", BalanceChange: -5}}); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+}
+
+func TestExecuteBlock_TwoPhaseState(t *testing.T) {
+	initialState := []AccountValue{
+		{Name: "A", Balance: 100},
+		{Name: "B", Balance: 10},
+	}
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 30},
+		transfer{from: "B", to: "C", value: 50}, // fails, B has 40
+		transfer{from: "B", to: "C", value: 15},
+	}}
+
+	state := &preparingState{TwoPhaseAccountState: NewTwoPhaseAccountState(initialState)}
+	accounts, _, err := ExecuteBlock(block, state, 2)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	verifyResults(t, accounts, map[string]uint{"A": 70, "B": 25, "C": 15})
+	if state.prepares != 2 || state.commits != 2 || state.aborts != 0 {
+		t.Errorf("Expected 2 prepares and commits, got %d prepares, %d commits, %d aborts",
+			state.prepares, state.commits, state.aborts)
+	}
+
+	atomic := &preparingState{TwoPhaseAccountState: NewTwoPhaseAccountState(initialState)}
+	block.Transactions = block.Transactions[:1]
+	if _, _, err := ExecuteBlockWithOptions(context.Background(), block, atomic, 2, BlockOptions{Atomic: true}); err != nil {
+		t.Fatalf("Atomic ExecuteBlock failed: %v", err)
+	}
+	if atomic.prepares != 1 || atomic.commits != 1 {
+		t.Errorf("Expected the atomic block to be prepared and committed once, got %d prepares, %d commits",
+			atomic.prepares, atomic.commits)
+	}
+	verifyResults(t, atomic.GetSnapshot(), map[string]uint{"A": 70, "B": 40})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// AccountOp selects the operation an AccountUpdate performs
+type AccountOp int
+
+const (
+	// OpBalanceChange adjusts the balance by BalanceChange, creating the account if it doesn't exist
+	OpBalanceChange AccountOp = iota
+	// OpCreate creates the account with Balance, failing if it already exists
+	OpCreate
+	// OpDelete deletes the account, failing if it doesn't exist or, unless Force is set, if its
+	// balance is nonzero
+	OpDelete
+	// OpSetBalance sets the balance to Balance, creating the account if it doesn't exist
+	OpSetBalance
+)
+
+// UpdateTransformer rewrites the updates of a transaction before they are applied, for example
+// to append a fee or round amounts. Returning an error fails the transaction with it instead.
+// Transformers run as transactions commit, one at a time in commit order, after access set
+// and read-only checks and before supply checks and no-op detection. Accounts they add don't
+// need to be declared, but the scheduler doesn't know about them either, so transactions
+// reading such accounts may not observe the transformed updates of transactions before them.
+type UpdateTransformer func([]AccountUpdate) ([]AccountUpdate, error)
+
+// NativeAsset is the asset held in AccountValue.Balance and changed by updates without an Asset
+const NativeAsset = ""
+
+// accountEntry is the state of a single account as seen while applying updates. The assets
+// map may be shared with the state it was read from and must not be modified in place.
+type accountEntry struct {
+	balance uint
+	assets  map[string]uint
+	exists  bool
+}
+
+// applyUpdate returns the account entry resulting from applying update to entry
+func applyUpdate(entry accountEntry, update AccountUpdate, clampUnderflow bool) (accountEntry, error) {
+	if update.Asset != NativeAsset {
+		return applyAssetUpdate(entry, update, clampUnderflow)
+	}
+
+	switch update.Op {
+	case OpBalanceChange:
+		balance, err := applyBalanceChange(update.Name, entry.balance, update.BalanceChange, clampUnderflow)
+		if err != nil {
+			return entry, err
+		}
+		return accountEntry{balance: balance, assets: entry.assets, exists: true}, nil
+
+	case OpCreate:
+		if entry.exists {
+			return entry, fmt.Errorf("%w: %s", ErrAccountExists, update.Name)
+		}
+		return accountEntry{balance: update.Balance, exists: true}, nil
+
+	case OpDelete:
+		if !entry.exists {
+			return entry, fmt.Errorf("%w: %s", ErrAccountNotFound, update.Name)
+		}
+		if !update.Force {
+			if entry.balance != 0 {
+				return entry, fmt.Errorf("%w: account %s has %d", ErrNonZeroBalance, update.Name, entry.balance)
+			}
+			for asset, balance := range entry.assets {
+				if balance != 0 {
+					return entry, fmt.Errorf("%w: account %s has %d %s", ErrNonZeroBalance, update.Name, balance, asset)
+				}
+			}
+		}
+		return accountEntry{}, nil
+
+	case OpSetBalance:
+		return accountEntry{balance: update.Balance, assets: entry.assets, exists: true}, nil
+
+	default:
+		return entry, fmt.Errorf("%w: unknown operation %d for account %s", ErrUnsupportedOperation, update.Op, update.Name)
+	}
+}
+
+// applyAssetUpdate applies an update targeting a non-native asset of the account
+func applyAssetUpdate(entry accountEntry, update AccountUpdate, clampUnderflow bool) (accountEntry, error) {
+	var balance uint
+	switch update.Op {
+	case OpBalanceChange:
+		var err error
+		balance, err = applyBalanceChange(update.Name+" "+update.Asset, entry.assets[update.Asset], update.BalanceChange, clampUnderflow)
+		if err != nil {
+			return entry, err
+		}
+	case OpSetBalance:
+		balance = update.Balance
+	default:
+		return entry, fmt.Errorf("%w: operation %d for account %s does not apply to asset %s", ErrUnsupportedOperation, update.Op, update.Name, update.Asset)
+	}
+
+	assets := make(map[string]uint, len(entry.assets)+1)
+	for asset, amount := range entry.assets {
+		assets[asset] = amount
+	}
+	assets[update.Asset] = balance
+	return accountEntry{balance: entry.balance, assets: assets, exists: true}, nil
+}
+
+// isNoOp reports whether updates only change balances and sum to zero for every account and asset
+func isNoOp(updates []AccountUpdate) bool {
+	type key struct{ name, asset string }
+	net := make(map[key]int)
+	for _, update := range updates {
+		if update.Op != OpBalanceChange {
+			return false
+		}
+		net[key{update.Name, update.Asset}] += update.BalanceChange
+	}
+	for _, change := range net {
+		if change != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// applyBalanceChange returns the balance of account name resulting from applying change to current.
+// Debits exceeding the current balance fail with ErrInsufficientBalance unless clampUnderflow is set,
+// in which case the balance drops to zero.
+func applyBalanceChange(name string, current uint, change int, clampUnderflow bool) (uint, error) {
+	if change >= 0 {
+		if uint(change) > math.MaxUint-current {
+			return 0, fmt.Errorf("%w: account %s has %d, cannot add %d", ErrOverflow, name, current, change)
+		}
+		return current + uint(change), nil
+	}
+
+	// Handle negative balance changes
+	decrease := uint(-change)
+	if decrease > current {
+		if clampUnderflow {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%w: account %s has %d, cannot subtract %d", ErrInsufficientBalance, name, current, decrease)
+	}
+	return current - decrease, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestApplyUpdates_CreateAccount(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "B", Op: OpCreate, Balance: 25}}); err != nil {
+		t.Fatalf("Creating account failed: %v", err)
+	}
+	if acc, ok := state.TryGetAccount("B"); !ok || acc.Balance != 25 {
+		t.Errorf("Expected B to exist with balance 25, got (%+v, %v)", acc, ok)
+	}
+
+	err := state.ApplyUpdates([]AccountUpdate{{Name: "A", Op: OpCreate}})
+	if !errors.Is(err, ErrAccountExists) {
+		t.Errorf("Expected ErrAccountExists, got %v", err)
+	}
+}
+
+func TestApplyUpdates_DeleteAccount(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "Empty", Balance: 0},
+		{Name: "Funded", Balance: 10},
+	})
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "Empty", Op: OpDelete}}); err != nil {
+		t.Fatalf("Deleting empty account failed: %v", err)
+	}
+	if state.HasAccount("Empty") {
+		t.Error("Expected Empty to be deleted")
+	}
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "Funded", Op: OpDelete}}); !errors.Is(err, ErrNonZeroBalance) {
+		t.Errorf("Expected ErrNonZeroBalance, got %v", err)
+	}
+	if !state.HasAccount("Funded") {
+		t.Error("Expected Funded to survive a rejected delete")
+	}
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "Funded", Op: OpDelete, Force: true}}); err != nil {
+		t.Fatalf("Force deleting funded account failed: %v", err)
+	}
+	if state.HasAccount("Funded") {
+		t.Error("Expected Funded to be deleted")
+	}
+}
+
+func TestApplyUpdates_DoubleDelete(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 0}})
+
+	// Deleting twice within one call fails as a whole
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "A", Op: OpDelete},
+		{Name: "A", Op: OpDelete},
+	})
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected ErrAccountNotFound, got %v", err)
+	}
+	if !state.HasAccount("A") {
+		t.Fatal("Expected A to survive the failed call")
+	}
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", Op: OpDelete}}); err != nil {
+		t.Fatalf("Deleting A failed: %v", err)
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", Op: OpDelete}}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func TestApplyUpdates_SetBalance(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 10}})
+
+	err := state.ApplyUpdates([]AccountUpdate{
+		{Name: "A", Op: OpSetBalance, Balance: 3},
+		{Name: "A", BalanceChange: 4},
+		{Name: "B", Op: OpSetBalance, Balance: 7},
+	})
+	if err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+
+	expected := map[string]uint{
+		"A": 7,
+		"B": 7,
+	}
+	verifyResults(t, state.GetSnapshot(), expected)
+}
+
+// assetTransfer moves an amount of a single asset between two accounts
+type assetTransfer struct {
+	from  string
+	to    string
+	asset string
+	value uint
+}
+
+func (t assetTransfer) Updates(state ReadOnlyState) ([]AccountUpdate, error) {
+	if balance := state.GetAccount(t.from).AssetBalance(t.asset); balance < t.value {
+		return nil, fmt.Errorf("insufficient %s balance: account %s has %d, needs %d", t.asset, t.from, balance, t.value)
+	}
+
+	return []AccountUpdate{
+		{Name: t.from, Asset: t.asset, BalanceChange: -int(t.value)},
+		{Name: t.to, Asset: t.asset, BalanceChange: int(t.value)},
+	}, nil
+}
+
+func (t assetTransfer) AccessSet() ([]string, []string) {
+	return []string{t.from}, []string{t.from, t.to}
+}
+
+func TestExecuteBlock_MultipleAssets(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{
+		{Name: "A", Balance: 10, Assets: map[string]uint{"USD": 100}},
+		{Name: "B", Balance: 10, Assets: map[string]uint{"EUR": 50}},
+		{Name: "C", Balance: 10},
+	})
+
+	block := Block{Transactions: []Transaction{
+		assetTransfer{from: "A", to: "B", asset: "USD", value: 40},
+		assetTransfer{from: "B", to: "C", asset: "EUR", value: 20},
+		assetTransfer{from: "B", to: "C", asset: "EUR", value: 40}, // fails, B only has 30 EUR left
+		transfer{from: "C", to: "A", value: 5},                     // native asset
+	}}
+
+	_, result, err := ExecuteBlock(block, state, 4)
+	if err != nil {
+		t.Fatalf("ExecuteBlock failed: %v", err)
+	}
+	if result.Transactions[2].Applied {
+		t.Error("Expected the second EUR transfer to fail")
+	}
+
+	expected := map[string]map[string]uint{
+		"A": {NativeAsset: 15, "USD": 60, "EUR": 0},
+		"B": {NativeAsset: 10, "USD": 40, "EUR": 30},
+		"C": {NativeAsset: 5, "USD": 0, "EUR": 20},
+	}
+	for name, balances := range expected {
+		acc := state.GetAccount(name)
+		for asset, balance := range balances {
+			if got := acc.AssetBalance(asset); got != balance {
+				t.Errorf("Account %s: expected %d %q, got %d", name, balance, asset, got)
+			}
+		}
+	}
+}
+
+func TestGetAccount_AssetsAreCopies(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Assets: map[string]uint{"USD": 100}}})
+
+	acc := state.GetAccount("A")
+	acc.Assets["USD"] = 0
+
+	if balance := state.GetAccount("A").AssetBalance("USD"); balance != 100 {
+		t.Errorf("Expected USD balance to stay 100, got %d", balance)
+	}
+}
+
+func TestApplyUpdates_DeleteAccountWithAssets(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Assets: map[string]uint{"USD": 1}}})
+
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", Op: OpDelete}}); !errors.Is(err, ErrNonZeroBalance) {
+		t.Errorf("Expected ErrNonZeroBalance, got %v", err)
+	}
+}
+
+func TestExecuteBlock_UpdateTransformers(t *testing.T) {
+	// Deduct a 1-unit fee from the sender of every transfer
+	fee := func(updates []AccountUpdate) ([]AccountUpdate, error) {
+		for _, update := range updates {
+			if update.BalanceChange < 0 {
+				return append(updates,
+					AccountUpdate{Name: update.Name, BalanceChange: -1},
+					AccountUpdate{Name: "treasury", BalanceChange: 1},
+				), nil
+			}
+		}
+		return updates, nil
+	}
+	errTooLarge := errors.New("transfer too large")
+	limit := func(updates []AccountUpdate) ([]AccountUpdate, error) {
+		for _, update := range updates {
+			if update.BalanceChange > 50 {
+				return nil, errTooLarge
+			}
+		}
+		return updates, nil
+	}
+
+	executor, err := NewExecutor(WithWorkers(4), WithUpdateTransformer(fee), WithUpdateTransformer(limit))
+	if err != nil {
+		t.Fatalf("NewExecutor failed: %v", err)
+	}
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}, {Name: "B", Balance: 100}})
+	block := Block{Transactions: []Transaction{
+		transfer{from: "A", to: "B", value: 10},
+		transfer{from: "B", to: "C", value: 20},
+		transfer{from: "C", to: "D", value: 5},
+		transfer{from: "A", to: "D", value: 60}, // rejected
+		transfer{from: "D", to: "D", value: 0},  // no debit, no fee
+	}}
+	snapshot, result, err := executor.RunBlock(block, state)
+	if err != nil {
+		t.Fatalf("RunBlock failed: %v", err)
+	}
+	if r := result.Transactions[3]; r.Applied || !errors.Is(r.Err, errTooLarge) {
+		t.Errorf("Expected the large transfer to be rejected, got %+v", r)
+	}
+	if updates := result.Transactions[0].Updates; len(updates) != 4 {
+		t.Errorf("Expected the applied updates to include the fee, got %v", updates)
+	}
+	verifyResults(t, snapshot, map[string]uint{"A": 89, "B": 89, "C": 14, "D": 5, "treasury": 3})
+}
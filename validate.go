@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+// Validator is implemented by transactions that can check that they are structurally valid,
+// such as that the accounts they need exist, without computing their updates
+type Validator interface {
+	Validate(state ReadOnlyState) error
+}
+
+// ValidateBlock checks every transaction implementing Validator against state, without
+// executing any, and returns a *TransactionError for each invalid one, in index order. Each
+// transaction is validated against state as given, so one relying on accounts created by an
+// earlier transaction of the block is reported invalid. Transactions that don't implement
+// Validator are assumed valid.
+func ValidateBlock(block Block, state ReadOnlyState) []error {
+	var errs []error
+	for i, tx := range block.Transactions {
+		validator, ok := tx.(Validator)
+		if !ok {
+			continue
+		}
+		if err := validator.Validate(state); err != nil {
+			errs = append(errs, &TransactionError{Index: i, Err: fmt.Errorf("%w: %w", ErrInvalidTransaction, err)})
+		}
+	}
+	return errs
+}
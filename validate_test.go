@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestValidateBlock(t *testing.T) {
+	state := NewInMemoryAccountState([]AccountValue{{Name: "A", Balance: 100}})
+	block := NewBlockBuilder().
+		AddTransfer("A", "B", 10).
+		AddTransfer("C", "A", 0). // C doesn't exist
+		Add(Mint{To: "C", Amount: 5}).
+		Add(Mint{To: "C", Amount: math.MaxInt + 1}). // too large
+		Add(transfer{from: "D", to: "A", value: 1}). // doesn't implement Validator
+		Build()
+
+	errs := ValidateBlock(block, state)
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 invalid transactions, got %v", errs)
+	}
+	for n, expected := range []struct {
+		index int
+		err   error
+	}{{1, ErrAccountNotFound}, {3, ErrOverflow}} {
+		var txErr *TransactionError
+		if !errors.As(errs[n], &txErr) || txErr.Index != expected.index || !errors.Is(errs[n], expected.err) || !errors.Is(errs[n], ErrInvalidTransaction) {
+			t.Errorf("Expected transaction %d to be invalid with %v, got %v", expected.index, expected.err, errs[n])
+		}
+	}
+
+	// The block is rejected before anything is applied
+	_, result, err := ExecuteBlockWithOptions(context.Background(), block, state, 2, BlockOptions{ValidateFirst: true})
+	if !errors.Is(err, ErrAccountNotFound) || !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected both validation errors, got %v", err)
+	}
+	if len(result.Transactions) != 0 {
+		t.Errorf("Expected no transaction results, got %+v", result.Transactions)
+	}
+	verifyResults(t, state.GetSnapshot(), map[string]uint{"A": 100})
+
+	if errs := ValidateBlock(NewBlockBuilder().AddTransfer("A", "B", 10).Build(), state); len(errs) != 0 {
+		t.Errorf("Expected a valid block, got %v", errs)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WALAccountState wraps an AccountState with a write-ahead log: every ApplyUpdates call is
+// appended to the log and synced to disk before it is applied to the underlying state, and
+// followed by a commit marker once the state accepted it, so the state can be reconstructed
+// with RecoverFromWAL after a crash.
+type WALAccountState struct {
+	AccountState
+	mu   sync.Mutex
+	file *os.File
+}
+
+// walEntry is a single line of the log, recording the updates of one ApplyUpdates call, or
+// marking those of the entry before it as committed
+type walEntry struct {
+	Updates []AccountUpdate `json:"updates,omitempty"`
+	Commit  bool            `json:"commit,omitempty"`
+}
+
+// NewWALAccountState opens or creates the log at path and appends to it the updates applied
+// to base from now on
+func NewWALAccountState(path string, base AccountState) (*WALAccountState, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	return &WALAccountState{AccountState: base, file: file}, nil
+}
+
+// RecoverFromWAL replays every entry of the log at path onto base and returns a
+// WALAccountState that keeps appending to the log. base must hold the state the log was
+// started from, such as a fresh InMemoryAccountState with the same initial accounts.
+//
+// Only committed entries are replayed, so entries the state rejected, e.g. because an account
+// was frozen, are skipped even if base would accept them now, and so is an entry whose
+// ApplyUpdates call was interrupted by a crash. A partially written final entry is removed
+// from the log. If base rejects a committed entry, RecoverFromWAL fails.
+func RecoverFromWAL(path string, base AccountState) (*WALAccountState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read write-ahead log: %w", err)
+	}
+
+	valid := 0 // length of the prefix of complete entries
+	reader := bufio.NewReader(bytes.NewReader(data))
+	var pending *walEntry // last entry, until its commit marker
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything after the last newline is a torn write
+			break
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("decode write-ahead log entry at offset %d: %w", valid, err)
+		}
+		switch {
+		case !entry.Commit:
+			// An entry that isn't committed before the next one was rejected
+			pending = &entry
+		case pending != nil:
+			if err := base.ApplyUpdates(pending.Updates); err != nil {
+				return nil, fmt.Errorf("replay write-ahead log entry before offset %d: %w", valid, err)
+			}
+			pending = nil
+		}
+		valid += len(line)
+	}
+
+	if valid < len(data) {
+		if err := os.Truncate(path, int64(valid)); err != nil {
+			return nil, fmt.Errorf("truncate write-ahead log: %w", err)
+		}
+	}
+	return NewWALAccountState(path, base)
+}
+
+// ApplyUpdates implements AccountState interface. The updates are durably logged before
+// they are applied; if logging fails they aren't applied. Once the underlying state accepted
+// them, they are durably marked committed, and if that fails the error is returned although
+// they were applied.
+func (s *WALAccountState) ApplyUpdates(updates []AccountUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walEntry{Updates: updates}); err != nil {
+		return err
+	}
+	if err := s.AccountState.ApplyUpdates(updates); err != nil {
+		return err
+	}
+	return s.append(walEntry{Commit: true})
+}
+
+// append durably writes entry to the log. The caller must hold the lock.
+func (s *WALAccountState) append(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode write-ahead log entry: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write write-ahead log: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// GetSnapshot returns the snapshot of the underlying state, or nil if it doesn't support snapshots
+func (s *WALAccountState) GetSnapshot() []AccountValue {
+	if state, ok := s.AccountState.(interface{ GetSnapshot() []AccountValue }); ok {
+		return state.GetSnapshot()
+	}
+	return nil
+}
+
+// Close closes the log
+func (s *WALAccountState) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
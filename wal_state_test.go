@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALAccountState_RecoversAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.wal")
+	initialState := []AccountValue{
+		{Name: "A", Balance: 20},
+		{Name: "B", Balance: 30},
+		{Name: "C", Balance: 40},
+	}
+
+	state, err := NewWALAccountState(path, NewInMemoryAccountState(initialState))
+	if err != nil {
+		t.Fatalf("NewWALAccountState failed: %v", err)
+	}
+	blocks := []Block{
+		{Transactions: []Transaction{
+			transfer{from: "A", to: "B", value: 5},
+			transfer{from: "B", to: "C", value: 10},
+			transfer{from: "B", to: "C", value: 30}, // fails
+		}},
+		{Transactions: []Transaction{transfer{from: "C", to: "D", value: 25}}},
+	}
+	var expected []AccountValue
+	for _, block := range blocks {
+		if expected, _, err = ExecuteBlock(block, state, 4); err != nil {
+			t.Fatalf("ExecuteBlock failed: %v", err)
+		}
+	}
+
+	// Crash without closing the log, in the middle of writing an entry
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Opening log failed: %v", err)
+	}
+	if _, err := file.WriteString(`{"updates":[{"Name":"A","Bal`); err != nil {
+		t.Fatalf("Writing torn entry failed: %v", err)
+	}
+	file.Close()
+
+	recovered, err := RecoverFromWAL(path, NewInMemoryAccountState(initialState))
+	if err != nil {
+		t.Fatalf("RecoverFromWAL failed: %v", err)
+	}
+	defer recovered.Close()
+	if snapshot := recovered.GetSnapshot(); !SnapshotsEqual(expected, snapshot) {
+		t.Fatalf("Expected recovered state %+v, got %+v", expected, snapshot)
+	}
+
+	// The torn entry is discarded and the log keeps working
+	if err := recovered.ApplyUpdates([]AccountUpdate{{Name: "D", BalanceChange: -5}}); err != nil {
+		t.Fatalf("ApplyUpdates after recovery failed: %v", err)
+	}
+	again, err := RecoverFromWAL(path, NewInMemoryAccountState(initialState))
+	if err != nil {
+		t.Fatalf("Second RecoverFromWAL failed: %v", err)
+	}
+	defer again.Close()
+	verifyResults(t, again.GetSnapshot(), map[string]uint{"A": 15, "B": 25, "C": 25, "D": 20})
+}
+
+func TestWALAccountState_RejectedEntriesNotReplayed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.wal")
+	initialState := []AccountValue{{Name: "A", Balance: 10}}
+
+	base := NewInMemoryAccountState(initialState)
+	base.Freeze("A")
+	state, err := NewWALAccountState(path, base)
+	if err != nil {
+		t.Fatalf("NewWALAccountState failed: %v", err)
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "A", BalanceChange: -5}, {Name: "B", BalanceChange: 5}}); err == nil {
+		t.Fatal("Expected ApplyUpdates on a frozen account to fail")
+	}
+	if err := state.ApplyUpdates([]AccountUpdate{{Name: "C", BalanceChange: 1}}); err != nil {
+		t.Fatalf("ApplyUpdates failed: %v", err)
+	}
+	state.Close()
+
+	// The recovered state doesn't hold the freeze, but the rejected entry stays rejected
+	recovered, err := RecoverFromWAL(path, NewInMemoryAccountState(initialState))
+	if err != nil {
+		t.Fatalf("RecoverFromWAL failed: %v", err)
+	}
+	defer recovered.Close()
+	verifyResults(t, recovered.GetSnapshot(), map[string]uint{"A": 10, "C": 1})
+}